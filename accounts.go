@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/users"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// applyUserRequest copies the fields sent in the request to the account
+func applyUserRequest(req repository.UserRequest, user *repository.User) error {
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		user.Password = string(hash)
+	}
+	if req.Enabled != nil {
+		user.Enabled = *req.Enabled
+	}
+	if req.ExpiresAt != nil {
+		user.ExpiresAt = req.ExpiresAt
+	}
+	if req.MaxDevices != nil {
+		user.MaxDevices = *req.MaxDevices
+	}
+	if req.Collections != nil {
+		user.Collections = req.Collections
+	}
+	return nil
+}
+
+// UsersHandler handles listing and creation of accounts
+func (s *TinShop) UsersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		allUsers, err := s.Shop.Users.List()
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.Shop.API.Users(w, allUsers)
+		return
+	}
+
+	var req repository.UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" || req.Password == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, err := s.Shop.Users.Get(req.Name); err == nil {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	user := repository.User{Name: req.Name, Enabled: true}
+	if err := applyUserRequest(req, &user); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := s.Shop.Users.Save(user); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	log.Println("[API] User created", user.Name)
+	s.Shop.API.User(w, user)
+}
+
+// UserHandler handles a single account
+func (s *TinShop) UserHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	user, err := s.Shop.Users.Get(vars["name"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.Shop.API.User(w, user)
+	case http.MethodPut:
+		var req repository.UserRequest
+		if errDecode := json.NewDecoder(r.Body).Decode(&req); errDecode != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if errApply := applyUserRequest(req, &user); errApply != nil {
+			log.Println(errApply)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if errSave := s.Shop.Users.Save(user); errSave != nil {
+			log.Println(errSave)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		log.Println("[API] User updated", user.Name)
+		s.Shop.API.User(w, user)
+	case http.MethodDelete:
+		if errDelete := s.Shop.Users.Delete(user.Name); errDelete != nil {
+			log.Println(errDelete)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		log.Println("[API] User deleted", user.Name)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// UserDeviceHandler handles unbinding a device from an account
+func (s *TinShop) UserDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	err := s.Shop.Users.UnbindDevice(vars["name"], vars["uid"])
+	if err == users.ErrUnknownUser {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	log.Println("[API] Switch", vars["uid"], "unbound from", vars["name"])
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/audit"
+	"github.com/ajmandourah/tinshop-ng/config"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/stats"
+	"github.com/ajmandourah/tinshop-ng/tokens"
+	"github.com/ajmandourah/tinshop-ng/utils"
+	"github.com/gorilla/mux"
+)
+
+// bootstrapToken creates a first token with all scopes so the api can be reached,
+// its secret is written in a file only readable by the shop instead of the logs
+func bootstrapToken(store repository.Tokens, path string) {
+	token, secret, err := store.Bootstrap(repository.AllScopes())
+	if err != nil {
+		log.Println("[API] Unable to create bootstrap token", err)
+		return
+	}
+	if secret == "" {
+		return
+	}
+	log.Println("[API] No api token found, a bootstrap token with all scopes has been created with id", token.ID)
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0o600); err != nil {
+		log.Println("[API] Unable to write the bootstrap token, delete tokens.db to create a new one:", err)
+		return
+	}
+	log.Println("[API] Its secret is in", path, "store it, delete the file and revoke the token once you created your own tokens")
+}
+
+// TokensHandler handles listing and creation of api tokens
+func (s *TinShop) TokensHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		allTokens, err := s.Shop.Tokens.List()
+		if err != nil {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.Shop.API.Tokens(w, allTokens)
+		return
+	}
+
+	var req repository.TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" || len(req.Scopes) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// A token can only grant the scopes it has been granted
+	caller, _ := requestToken(r)
+	for _, scope := range req.Scopes {
+		// Unknown scopes are refused by the store
+		if utils.Contains(repository.AllScopes(), scope) && !tokens.HasScope(caller, scope) {
+			log.Println("[API] Token", caller.Name, "cannot grant the scope", scope)
+			countAuthFailure("missing_scope")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+	token, secret, err := s.Shop.Tokens.Create(req.Name, req.Scopes)
+	if errors.Is(err, tokens.ErrUnknownScope) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	log.Println("[API] Token created", token.ID, token.Name, token.Scopes)
+	s.Shop.API.NewToken(w, token, secret)
+}
+
+// TokenHandler handles revocation of an api token
+func (s *TinShop) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	allTokens, err := s.Shop.Tokens.List()
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// A token can only revoke the tokens whose scopes it has, as for creation
+	caller, _ := requestToken(r)
+	for _, token := range allTokens {
+		if token.ID != vars["id"] {
+			continue
+		}
+		for _, scope := range token.Scopes {
+			if !tokens.HasScope(caller, scope) {
+				log.Println("[API] Token", caller.Name, "cannot revoke the token", token.ID, "with the scope", scope)
+				countAuthFailure("missing_scope")
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		}
+	}
+
+	if err := s.Shop.Tokens.Revoke(vars["id"]); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	log.Println("[API] Token revoked", vars["id"])
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReloadHandler handles reloading the configuration
+func (s *TinShop) ReloadHandler(w http.ResponseWriter, _ *http.Request) {
+	if err := s.Shop.Config.Reload(); err != nil {
+		log.Println("[API] Unable to reload configuration", err)
+		var invalid *config.ValidationError
+		if errors.As(err, &invalid) {
+			s.Shop.API.ConfigProblems(w, invalid.Problems)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AuditHandler handles querying the audit log
+func (s *TinShop) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	query := repository.AuditQuery{
+		UID:  r.URL.Query().Get("uid"),
+		Type: repository.AuditType(r.URL.Query().Get("type")),
+	}
+
+	var err error
+	if from := r.URL.Query().Get("from"); from != "" {
+		if query.From, err = time.Parse(time.RFC3339, from); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if to := r.URL.Query().Get("to"); to != "" {
+		if query.To, err = time.Parse(time.RFC3339, to); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if query.Limit <= 0 {
+		query.Limit = audit.DefaultLimit
+	}
+	if query.Limit > audit.MaxLimit {
+		query.Limit = audit.MaxLimit
+	}
+
+	events, err := s.Shop.Audit.Query(query)
+	if err != nil {
+		log.Println("[API] Unable to query audit log", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.Shop.API.Audit(w, events)
+}
+
+// StatsSeriesHandler returns the hourly or daily statistics of a time range
+func (s *TinShop) StatsSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	query := repository.SeriesQuery{
+		Resolution: r.URL.Query().Get("resolution"),
+	}
+	if query.Resolution == "" {
+		query.Resolution = repository.SeriesHourly
+	}
+	if query.Resolution != repository.SeriesHourly && query.Resolution != repository.SeriesDaily {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if from := r.URL.Query().Get("from"); from != "" {
+		if query.From, err = time.Parse(time.RFC3339, from); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if to := r.URL.Query().Get("to"); to != "" {
+		if query.To, err = time.Parse(time.RFC3339, to); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	points, err := s.Shop.Stats.Series(query)
+	if err != nil {
+		log.Println("[API] Unable to query stats series", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.Shop.API.Series(w, points)
+}
+
+// StatsExportHandler exports the whole statistics as json, or one table as csv
+func (s *TinShop) StatsExportHandler(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	table := r.URL.Query().Get("table")
+	if format == "csv" && !utils.Contains(stats.CSVTables, table) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	export, err := s.Shop.Stats.Export()
+	if err != nil {
+		log.Println("[API] Unable to export stats", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if format != "csv" {
+		s.Shop.API.StatsExport(w, export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"stats-"+table+".csv\"")
+	if err := stats.WriteCSV(w, export, table); err != nil {
+		log.Println("[API] Unable to write stats csv", err)
+	}
+}
+
+// StatsImportHandler merges a json export into the statistics
+func (s *TinShop) StatsImportHandler(w http.ResponseWriter, r *http.Request) {
+	var export repository.StatsExport
+	if err := json.NewDecoder(r.Body).Decode(&export); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := s.Shop.Stats.Import(export); err != nil {
+		log.Println("[API] Unable to import stats", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	log.Println("[API] Imported stats of", len(export.Devices), "switch and", len(export.Titles), "titles")
+	w.WriteHeader(http.StatusNoContent)
+}
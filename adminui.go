@@ -0,0 +1,29 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"net/http"
+)
+
+// adminUIPolicy only allows the page to load its own files, the game icons and to query the api
+const adminUIPolicy = "default-src 'self'; img-src 'self' https: data:; frame-ancestors 'none'"
+
+// AdminUIHandler serves the embedded admin web interface.
+// The page holds no data, everything is queried from the admin api with the token entered in the page.
+func (s *TinShop) AdminUIHandler() http.Handler {
+	ui, err := fs.Sub(assetData, "assets/admin")
+	if err != nil {
+		log.Fatal("[Admin] Unable to load the web interface: ", err)
+	}
+	files := http.StripPrefix("/admin/", http.FileServer(http.FS(ui)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", adminUIPolicy)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("Cache-Control", "no-cache")
+		files.ServeHTTP(w, r)
+	})
+}
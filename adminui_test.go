@@ -0,0 +1,44 @@
+package main_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	main "github.com/ajmandourah/tinshop-ng"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AdminUI", func() {
+	var (
+		handler http.Handler
+		writer  *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		myShop := &main.TinShop{}
+		handler = myShop.AdminUIHandler()
+		writer = httptest.NewRecorder()
+	})
+
+	It("serves the page with a restrictive policy", func() {
+		handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/admin/", nil))
+
+		Expect(writer.Code).To(Equal(http.StatusOK))
+		Expect(writer.Header().Get("Content-Type")).To(HavePrefix("text/html"))
+		Expect(writer.Header().Get("Content-Security-Policy")).To(ContainSubstring("default-src 'self'"))
+		Expect(writer.Header().Get("X-Content-Type-Options")).To(Equal("nosniff"))
+		Expect(writer.Body.String()).To(ContainSubstring("admin.js"))
+	})
+	It("serves the script", func() {
+		handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/admin/admin.js", nil))
+
+		Expect(writer.Code).To(Equal(http.StatusOK))
+		Expect(writer.Header().Get("Content-Type")).To(ContainSubstring("javascript"))
+	})
+	It("does not serve the other assets", func() {
+		handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/admin/../shop.tmpl", nil))
+
+		Expect(writer.Code).NotTo(Equal(http.StatusOK))
+	})
+})
@@ -17,7 +17,104 @@ func New() repository.API {
 }
 
 func (e *endpoint) Stats(w http.ResponseWriter, stats repository.StatsSummary) {
-	jsonResponse, jsonError := json.Marshal(stats)
+	writeJSON(w, stats)
+}
+
+func (e *endpoint) Users(w http.ResponseWriter, users []repository.User) {
+	safeUsers := make([]repository.User, 0, len(users))
+	for _, user := range users {
+		user.Password = ""
+		safeUsers = append(safeUsers, user)
+	}
+	writeJSON(w, safeUsers)
+}
+
+func (e *endpoint) User(w http.ResponseWriter, user repository.User) {
+	user.Password = ""
+	writeJSON(w, user)
+}
+
+func (e *endpoint) Tokens(w http.ResponseWriter, tokens []repository.APIToken) {
+	safeTokens := make([]repository.APIToken, 0, len(tokens))
+	for _, token := range tokens {
+		token.Hash = ""
+		safeTokens = append(safeTokens, token)
+	}
+	writeJSON(w, safeTokens)
+}
+
+// NewToken is the only response containing the secret of a token
+func (e *endpoint) NewToken(w http.ResponseWriter, token repository.APIToken, secret string) {
+	token.Hash = ""
+	writeJSON(w, repository.CreatedToken{APIToken: token, Token: secret})
+}
+
+func (e *endpoint) Audit(w http.ResponseWriter, events []repository.AuditEvent) {
+	writeJSON(w, events)
+}
+
+func (e *endpoint) Series(w http.ResponseWriter, points []repository.SeriesPoint) {
+	writeJSON(w, points)
+}
+
+func (e *endpoint) Devices(w http.ResponseWriter, devices []repository.Device) {
+	writeJSON(w, devices)
+}
+
+func (e *endpoint) Device(w http.ResponseWriter, device repository.Device) {
+	writeJSON(w, device)
+}
+
+func (e *endpoint) Titles(w http.ResponseWriter, page repository.TitlesPage) {
+	writeJSON(w, page)
+}
+
+func (e *endpoint) Title(w http.ResponseWriter, title repository.LibraryTitle) {
+	writeJSON(w, title)
+}
+
+func (e *endpoint) Files(w http.ResponseWriter, page repository.FilesPage) {
+	writeJSON(w, page)
+}
+
+func (e *endpoint) Missing(w http.ResponseWriter, missing []repository.MissingContent) {
+	writeJSON(w, missing)
+}
+
+func (e *endpoint) FailedFiles(w http.ResponseWriter, files []repository.FailedFile) {
+	writeJSON(w, files)
+}
+
+func (e *endpoint) Scan(w http.ResponseWriter, status repository.ScanStatus) {
+	writeJSON(w, status)
+}
+
+func (e *endpoint) StatsExport(w http.ResponseWriter, export repository.StatsExport) {
+	w.Header().Set("Content-Disposition", "attachment; filename=\"stats.json\"")
+	writeJSON(w, export)
+}
+
+// ConfigProblems answers 422 with the problems of a configuration which was not applied
+func (e *endpoint) ConfigProblems(w http.ResponseWriter, problems []repository.ConfigProblem) {
+	writeJSONStatus(w, http.StatusUnprocessableEntity, problems)
+}
+
+// Health answers 503 when a check failed, for the probes to only rely on the status code
+func (e *endpoint) Health(w http.ResponseWriter, health repository.Health) {
+	w.Header().Set("Cache-Control", "no-store")
+	if health.Status != repository.HealthOK {
+		writeJSONStatus(w, http.StatusServiceUnavailable, health)
+		return
+	}
+	writeJSON(w, health)
+}
+
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	writeJSONStatus(w, http.StatusOK, data)
+}
+
+func writeJSONStatus(w http.ResponseWriter, status int, data interface{}) {
+	jsonResponse, jsonError := json.Marshal(data)
 
 	if jsonError != nil {
 		log.Println("[API] Unable to encode JSON")
@@ -25,6 +122,7 @@ func (e *endpoint) Stats(w http.ResponseWriter, stats repository.StatsSummary) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	_, _ = w.Write(jsonResponse)
 }
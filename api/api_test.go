@@ -39,4 +39,15 @@ var _ = Describe("Api", func() {
 			Expect(writer.Body.String()).To(Equal("{\"visit\":42}"))
 		})
 	})
+	Describe("Users", func() {
+		It("Never expose password hashes", func() {
+			users := []repository.User{{Name: "kid", Password: "$2a$hash", Enabled: true}}
+			writer = httptest.NewRecorder()
+
+			myAPI.Users(writer, users)
+			Expect(writer.Code).To(Equal(http.StatusOK))
+			Expect(writer.Body.String()).To(Equal("[{\"name\":\"kid\",\"enabled\":true,\"maxDevices\":0}]"))
+			Expect(users[0].Password).To(Equal("$2a$hash"))
+		})
+	})
 })
@@ -0,0 +1,266 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+// Version is the version of the api documented in the specification
+const Version = "1.0.0"
+
+// bearerAuth is the name of the security scheme of the api tokens
+const bearerAuth = "bearerAuth"
+
+var pathParam = regexp.MustCompile(`{([^}]+)}`) //nolint:gochecknoglobals
+
+// Document is an OpenAPI 3 specification
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Servers    []Server                         `json:"servers"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+// Info describes the api
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+// Server is the base url of the api
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Components holds the schemas and security schemes referenced by the operations
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme describes how to authenticate
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+// Operation is a method of a path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *Body                 `json:"requestBody,omitempty"`
+	Responses   map[string]*Body      `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+	// Scope is the scope the token must be granted
+	Scope string `json:"x-tinshop-scope,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Body is a request body or a response
+type Body struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the json schema of a value
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+func (e *endpoint) OpenAPI(w http.ResponseWriter, routes []repository.APIRoute) {
+	writeJSON(w, Spec(routes))
+}
+
+// Spec returns the OpenAPI specification of the routes
+func Spec(routes []repository.APIRoute) Document {
+	doc := Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "TinShop API",
+			Description: "Administration api of TinShop, the routes need a bearer token granted the scope given in x-tinshop-scope.",
+			Version:     Version,
+		},
+		Servers: []Server{{URL: "/api"}},
+		Paths:   make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: map[string]SecurityScheme{bearerAuth: {Type: "http", Scheme: "bearer"}},
+		},
+	}
+	generator := &schemaGenerator{schemas: doc.Components.Schemas}
+
+	for _, route := range routes {
+		operation := &Operation{
+			OperationID: route.ID,
+			Summary:     route.Summary,
+			Tags:        []string{strings.SplitN(strings.TrimPrefix(route.Path, "/"), "/", 2)[0]},
+			Responses:   make(map[string]*Body),
+			Security:    []map[string][]string{},
+			Scope:       route.Scope,
+		}
+		for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"},
+			})
+		}
+		for _, param := range route.Query {
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name: param.Name, In: "query", Description: param.Description, Required: param.Required,
+				Schema: &Schema{Type: param.Type, Enum: param.Enum},
+			})
+		}
+		if route.Request != nil {
+			operation.RequestBody = &Body{
+				Required: true,
+				Content:  map[string]MediaType{"application/json": {Schema: generator.schema(reflect.TypeOf(route.Request))}},
+			}
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := &Body{Description: http.StatusText(status)}
+		if route.Response != nil {
+			success.Content = map[string]MediaType{"application/json": {Schema: generator.schema(reflect.TypeOf(route.Response))}}
+		}
+		if route.ContentType != "" {
+			if success.Content == nil {
+				success.Content = make(map[string]MediaType)
+			}
+			success.Content[route.ContentType] = MediaType{Schema: &Schema{Type: "string"}}
+		}
+		operation.Responses[strconv.Itoa(status)] = success
+
+		errors := route.Errors
+		if route.Scope != "" {
+			operation.Security = []map[string][]string{{bearerAuth: {}}}
+			errors = append([]int{http.StatusUnauthorized, http.StatusForbidden}, errors...)
+		}
+		for _, code := range errors {
+			operation.Responses[strconv.Itoa(code)] = &Body{Description: http.StatusText(code)}
+		}
+
+		if doc.Paths[route.Path] == nil {
+			doc.Paths[route.Path] = make(map[string]*Operation)
+		}
+		doc.Paths[route.Path][strings.ToLower(route.Method)] = operation
+	}
+	return doc
+}
+
+// schemaGenerator builds the schemas from the go types, named structs are shared in the components
+type schemaGenerator struct {
+	schemas map[string]*Schema
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) *Schema {
+	if t == reflect.TypeOf(time.Time{}) {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := g.schema(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			// Registered before being built for the types referencing themselves
+			g.schemas[t.Name()] = &Schema{}
+			*g.schemas[t.Name()] = *g.object(t)
+		}
+		return ref
+	default:
+		// interface{}, any value
+		return &Schema{}
+	}
+}
+
+// object returns the schema of a struct with the fields encoded in json
+func (g *schemaGenerator) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+
+		// Fields of embedded structs are encoded with the other fields
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := g.object(field.Type)
+			for property, propertySchema := range embedded.Properties {
+				schema.Properties[property] = propertySchema
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = g.schema(field.Type)
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
@@ -0,0 +1,107 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/ajmandourah/tinshop-ng/api"
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+type sample struct {
+	repository.APIToken
+	Note    string            `json:"note,omitempty"`
+	Secret  string            `json:"-"`
+	When    time.Time         `json:"when"`
+	Count   *int              `json:"count"`
+	Labels  map[string]uint64 `json:"labels"`
+	Devices []repository.Device
+}
+
+var _ = Describe("OpenAPI", func() {
+	routes := []repository.APIRoute{
+		{
+			ID: "getSample", Method: http.MethodGet, Path: "/samples/{id}", Scope: repository.ScopeReadLibrary,
+			Summary:  "A sample",
+			Query:    []repository.APIParam{{Name: "sort", Type: "string", Enum: []string{"name", "size"}, Required: true}},
+			Response: sample{},
+			Errors:   []int{http.StatusNotFound},
+		},
+		{
+			ID: "deleteSample", Method: http.MethodDelete, Path: "/samples/{id}", Scope: repository.ScopeManageLibrary,
+			Summary: "Delete a sample",
+			Request: repository.ScanRequest{},
+			Status:  http.StatusNoContent,
+		},
+		{
+			ID: "getSpec", Method: http.MethodGet, Path: "/openapi.json",
+			Summary: "The specification",
+		},
+	}
+
+	It("documents each method of the paths", func() {
+		spec := api.Spec(routes)
+
+		Expect(spec.OpenAPI).To(HavePrefix("3."))
+		Expect(spec.Servers[0].URL).To(Equal("/api"))
+		Expect(spec.Paths).To(HaveLen(2))
+		Expect(spec.Paths["/samples/{id}"]).To(HaveKey("get"))
+		Expect(spec.Paths["/samples/{id}"]).To(HaveKey("delete"))
+
+		get := spec.Paths["/samples/{id}"]["get"]
+		Expect(get.OperationID).To(Equal("getSample"))
+		Expect(get.Scope).To(Equal(repository.ScopeReadLibrary))
+		Expect(get.Security).To(HaveLen(1))
+		Expect(get.Parameters).To(HaveLen(2))
+		Expect(get.Parameters[0].In).To(Equal("path"))
+		Expect(get.Parameters[0].Required).To(BeTrue())
+		Expect(get.Parameters[1].Schema.Enum).To(Equal([]string{"name", "size"}))
+		Expect(get.Responses).To(HaveKey("200"))
+		Expect(get.Responses).To(HaveKey("401"))
+		Expect(get.Responses).To(HaveKey("403"))
+		Expect(get.Responses).To(HaveKey("404"))
+		Expect(get.Responses["200"].Content["application/json"].Schema.Ref).To(Equal("#/components/schemas/sample"))
+
+		remove := spec.Paths["/samples/{id}"]["delete"]
+		Expect(remove.RequestBody.Content["application/json"].Schema.Ref).To(Equal("#/components/schemas/ScanRequest"))
+		Expect(remove.Responses).To(HaveKey("204"))
+		Expect(remove.Responses["204"].Content).To(BeEmpty())
+
+		Expect(spec.Paths["/openapi.json"]["get"].Security).To(BeEmpty())
+	})
+	It("generates the schemas from the json encoding", func() {
+		schemas := api.Spec(routes).Components.Schemas
+
+		Expect(schemas).To(HaveKey("sample"))
+		Expect(schemas).To(HaveKey("Device"))
+		Expect(schemas).To(HaveKey("ScanRequest"))
+		properties := schemas["sample"].Properties
+		// Embedded fields are flattened
+		Expect(properties).To(HaveKey("scopes"))
+		Expect(properties["scopes"].Type).To(Equal("array"))
+		Expect(properties).NotTo(HaveKey("Secret"))
+		Expect(properties["when"].Format).To(Equal("date-time"))
+		Expect(properties["count"].Nullable).To(BeTrue())
+		Expect(properties["labels"].AdditionalProperties.Format).To(Equal("int64"))
+		Expect(properties["Devices"].Items.Ref).To(Equal("#/components/schemas/Device"))
+		Expect(schemas["sample"].Required).To(ContainElements("when", "labels", "id"))
+		Expect(schemas["sample"].Required).NotTo(ContainElements("note", "count"))
+	})
+	It("writes the specification as json", func() {
+		writer := httptest.NewRecorder()
+
+		api.New().OpenAPI(writer, routes)
+
+		Expect(writer.Code).To(Equal(http.StatusOK))
+		var spec map[string]interface{}
+		Expect(json.Unmarshal(writer.Body.Bytes(), &spec)).To(Succeed())
+		Expect(spec).To(HaveKey("paths"))
+		Expect(writer.Body.String()).To(ContainSubstring(`"x-tinshop-scope":"read-library"`))
+		Expect(writer.Body.String()).To(ContainSubstring(`"$ref":"#/components/schemas/sample"`))
+	})
+})
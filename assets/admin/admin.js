@@ -0,0 +1,456 @@
+// TinShop admin interface, every information is queried from the admin api with the token of the user.
+// The token is kept in the session storage, closing the tab signs out.
+"use strict";
+
+const tokenKey = "tinshop-token";
+const pageSize = 60;
+
+const state = {
+	tab: "library",
+	offset: 0,
+	total: 0,
+	scanTimer: null,
+	scanRunning: false,
+};
+
+function $(id) {
+	return document.getElementById(id);
+}
+
+// el creates an element, text is always set as text and never parsed as html
+function el(tag, attributes, children) {
+	const node = document.createElement(tag);
+	for (const [key, value] of Object.entries(attributes || {})) {
+		if (key === "text") {
+			node.textContent = value;
+		} else {
+			node.setAttribute(key, value);
+		}
+	}
+	for (const child of children || []) {
+		node.append(child);
+	}
+	return node;
+}
+
+function setStatus(message, isError) {
+	const status = $("status");
+	status.textContent = message || "";
+	status.classList.toggle("error", Boolean(isError));
+}
+
+function formatBytes(bytes) {
+	const units = ["B", "KB", "MB", "GB", "TB"];
+	let value = Number(bytes) || 0;
+	let unit = 0;
+	while (value >= 1024 && unit < units.length - 1) {
+		value /= 1024;
+		unit++;
+	}
+	return value.toFixed(unit === 0 ? 0 : 1) + " " + units[unit];
+}
+
+function formatDate(value) {
+	const date = new Date(value);
+	return isNaN(date) || date.getFullYear() < 2000 ? "" : date.toLocaleString();
+}
+
+class APIError extends Error {
+	constructor(status, problems) {
+		super("HTTP " + status);
+		this.status = status;
+		this.problems = problems || [];
+	}
+}
+
+async function api(method, path, body) {
+	const options = {
+		method: method,
+		headers: { Authorization: "Bearer " + sessionStorage.getItem(tokenKey) },
+	};
+	if (body !== undefined) {
+		options.headers["Content-Type"] = "application/json";
+		options.body = JSON.stringify(body);
+	}
+	const response = await fetch("/api" + path, options);
+	if (response.status === 401) {
+		signOut("Invalid or revoked token");
+		throw new APIError(response.status);
+	}
+	if (response.status === 422) {
+		throw new APIError(response.status, await response.json().catch(() => []));
+	}
+	if (!response.ok) {
+		throw new APIError(response.status);
+	}
+	if (response.status === 204 || response.headers.get("Content-Length") === "0") {
+		return null;
+	}
+	return response.json();
+}
+
+function explain(error) {
+	if (error.status === 403) {
+		return "Your token is missing the scope needed for this action";
+	}
+	if (error.status === 409) {
+		return "A scan is already running";
+	}
+	if (error.status === 422) {
+		const errors = error.problems
+			.filter((problem) => problem.severity === "error")
+			.map((problem) => (problem.field ? problem.field + ": " : "") + problem.message);
+		return "Invalid configuration, the previous one is kept. " + errors.join("; ");
+	}
+	return error.message;
+}
+
+// Login
+
+function signOut(message) {
+	sessionStorage.removeItem(tokenKey);
+	stopScanPolling();
+	$("app").hidden = true;
+	$("login").hidden = false;
+	$("login-error").textContent = message || "";
+}
+
+async function signIn(token) {
+	sessionStorage.setItem(tokenKey, token);
+	try {
+		await api("GET", "/titles?limit=1");
+	} catch (error) {
+		// The token may be valid without the library scope
+		if (error.status !== 403) {
+			if (error.status !== 401) {
+				signOut(explain(error));
+			}
+			return;
+		}
+	}
+	$("login").hidden = true;
+	$("app").hidden = false;
+	showTab(state.tab);
+	followScan();
+}
+
+// Tabs
+
+const loaders = {
+	library: loadLibrary,
+	missing: loadMissing,
+	unidentified: loadUnidentified,
+	devices: loadDevices,
+	stats: loadStats,
+};
+
+function showTab(tab) {
+	state.tab = tab;
+	for (const button of document.querySelectorAll(".tab")) {
+		button.classList.toggle("active", button.dataset.tab === tab);
+	}
+	for (const panel of document.querySelectorAll(".panel")) {
+		panel.classList.toggle("active", panel.id === tab);
+	}
+	setStatus("");
+	loaders[tab]().catch((error) => setStatus(explain(error), true));
+}
+
+function icon(url, className) {
+	const img = el("img", { alt: "", loading: "lazy", class: className || "" });
+	if (url) {
+		img.src = url;
+	}
+	return img;
+}
+
+// Library
+
+async function loadLibrary() {
+	const params = new URLSearchParams({ limit: pageSize, offset: state.offset });
+	const name = $("library-name").value.trim();
+	if (name) {
+		params.set("name", name);
+	}
+	const page = await api("GET", "/titles?" + params);
+	state.total = page.total;
+
+	const list = $("library-list");
+	list.replaceChildren();
+	for (const title of page.titles || []) {
+		const details = [formatBytes(title.totalSize)];
+		if (title.updates && title.updates.length) {
+			details.push("v" + title.latestVersion);
+		}
+		if (title.dlc && title.dlc.length) {
+			details.push(title.dlc.length + " DLC");
+		}
+		list.append(el("div", { class: "title", title: title.id }, [
+			icon(title.iconUrl),
+			el("div", { class: "name", text: title.name || title.id }),
+			el("div", { class: "details", text: details.join(" · ") }),
+		]));
+	}
+
+	const last = Math.min(state.offset + pageSize, state.total);
+	$("library-total").textContent = state.total ? (state.offset + 1) + "-" + last + " of " + state.total + " titles" : "No title";
+	$("library-prev").disabled = state.offset === 0;
+	$("library-next").disabled = last >= state.total;
+}
+
+// Missing content
+
+async function loadMissing() {
+	const missing = await api("GET", "/library/missing");
+	const list = $("missing-list");
+	list.replaceChildren();
+	for (const title of missing || []) {
+		const dlc = (title.dlc || []).map((content) => content.name || content.id);
+		list.append(el("tr", {}, [
+			el("td", {}, [icon(title.iconUrl, "icon")]),
+			el("td", { text: title.name || title.id, title: title.id }),
+			el("td", { text: "v" + title.version }),
+			el("td", { text: title.latestVersion ? "v" + title.latestVersion : "" }),
+			el("td", { text: dlc.join(", ") }),
+		]));
+	}
+	if (!list.children.length) {
+		setStatus("Nothing is missing from your library");
+	}
+}
+
+// Unidentified files
+
+async function loadUnidentified() {
+	const files = await api("GET", "/files/unidentified");
+	const list = $("unidentified-list");
+	list.replaceChildren();
+	for (const file of files || []) {
+		list.append(el("tr", {}, [
+			el("td", { class: "path", text: file.path }),
+			el("td", { text: file.source }),
+			el("td", { text: file.reason }),
+		]));
+	}
+	if (!list.children.length) {
+		setStatus("Every file has been identified");
+	}
+}
+
+// Devices
+
+async function loadDevices() {
+	const devices = await api("GET", "/devices");
+	const list = $("devices-list");
+	list.replaceChildren();
+	for (const device of devices || []) {
+		const rename = el("button", { type: "button", text: "Rename" });
+		rename.addEventListener("click", () => {
+			const name = prompt("Name of " + device.uid, device.name || "");
+			if (name !== null) {
+				updateDevice(device.uid, { name: name.trim() });
+			}
+		});
+		const block = el("button", { type: "button", text: device.blocked ? "Unblock" : "Block" });
+		block.addEventListener("click", () => updateDevice(device.uid, { blocked: !device.blocked }));
+
+		list.append(el("tr", { class: device.blocked ? "blocked" : "" }, [
+			el("td", { text: device.name || "" }),
+			el("td", { class: "path", text: device.uid }),
+			el("td", { text: device.ip || "" }),
+			el("td", { text: device.version || "" }),
+			el("td", { text: formatDate(device.lastSeen) }),
+			el("td", {}, [rename, " ", block]),
+		]));
+	}
+}
+
+async function updateDevice(uid, changes) {
+	try {
+		await api("PUT", "/devices/" + encodeURIComponent(uid), changes);
+		await loadDevices();
+	} catch (error) {
+		setStatus(explain(error), true);
+	}
+}
+
+// Statistics
+
+async function loadStats() {
+	const [summary, series] = await Promise.all([
+		api("GET", "/stats"),
+		api("GET", "/stats/series?resolution=daily"),
+	]);
+
+	const cards = [
+		["Visits", summary.visit || 0],
+		["Switches", summary.uniqueSwitch || 0],
+		["Downloads asked", summary.downloadAsked || 0],
+		["Completed", summary.downloadCompleted || 0],
+		["Aborted", summary.downloadAborted || 0],
+		["Data served", formatBytes(summary.bytesServed)],
+	];
+	$("stats-summary").replaceChildren(...cards.map(([label, value]) => el("div", { class: "card" }, [
+		el("div", { class: "value", text: String(value) }),
+		el("div", { class: "label", text: label }),
+	])));
+
+	const points = (series || []).slice(-30);
+	drawChart($("chart-visits"), points, [(point) => point.visits], String);
+	drawChart($("chart-downloads"), points, [(point) => point.downloads, (point) => point.completed], String);
+	drawChart($("chart-bytes"), points, [(point) => point.bytes], formatBytes);
+
+	const top = $("stats-top");
+	top.replaceChildren();
+	for (const title of summary.topTitles || []) {
+		top.append(el("tr", {}, [
+			el("td", { text: title.titleId }),
+			el("td", { text: String(title.asked) }),
+			el("td", { text: String(title.completed) }),
+			el("td", { text: formatBytes(title.bytes) }),
+		]));
+	}
+}
+
+// drawChart draws one bar per point, each value function after the first draws a narrower bar in front
+function drawChart(container, points, values, format) {
+	const ns = "http://www.w3.org/2000/svg";
+	const width = 600;
+	const height = 160;
+	const bottom = 18;
+	const svg = document.createElementNS(ns, "svg");
+	svg.setAttribute("viewBox", "0 0 " + width + " " + height);
+	svg.setAttribute("preserveAspectRatio", "none");
+
+	const max = Math.max(1, ...points.map(values[0]));
+	const step = width / Math.max(points.length, 1);
+	points.forEach((point, i) => {
+		values.forEach((value, serie) => {
+			const barHeight = (value(point) / max) * (height - bottom - 14);
+			const rect = document.createElementNS(ns, "rect");
+			const inset = step * (0.1 + serie * 0.15);
+			rect.setAttribute("x", i * step + inset);
+			rect.setAttribute("y", height - bottom - barHeight);
+			rect.setAttribute("width", Math.max(step - 2 * inset, 1));
+			rect.setAttribute("height", barHeight);
+			if (serie > 0) {
+				rect.setAttribute("class", "completed");
+			}
+			const label = document.createElementNS(ns, "title");
+			label.textContent = new Date(point.time).toLocaleDateString() + ": " + format(value(point));
+			rect.append(label);
+			svg.append(rect);
+		});
+		if (i % Math.ceil(points.length / 10) === 0) {
+			const text = document.createElementNS(ns, "text");
+			text.setAttribute("x", i * step + 2);
+			text.setAttribute("y", height - 4);
+			text.textContent = new Date(point.time).toLocaleDateString(undefined, { month: "short", day: "numeric" });
+			svg.append(text);
+		}
+	});
+	const maxLabel = document.createElementNS(ns, "text");
+	maxLabel.setAttribute("x", 4);
+	maxLabel.setAttribute("y", 12);
+	maxLabel.textContent = points.length ? format(max) : "No data yet";
+	svg.append(maxLabel);
+
+	container.replaceChildren(svg);
+}
+
+// Actions
+
+function stopScanPolling() {
+	clearTimeout(state.scanTimer);
+	state.scanTimer = null;
+}
+
+function showScan(scan) {
+	const running = scan && scan.state === "running";
+	$("rescan").disabled = running;
+	$("cancel-scan").hidden = !running;
+	if (scan && scan.state) {
+		setStatus("Scan " + scan.state + ": " + scan.seen + " files seen, " + scan.identified + " identified, " + scan.failed + " failed");
+	}
+	return running;
+}
+
+// followScan polls the scan progress until it is over, then refreshes the current tab
+async function followScan() {
+	stopScanPolling();
+	let scan;
+	try {
+		scan = await api("GET", "/scan");
+	} catch (error) {
+		// Tokens without the manage-library scope cannot follow the scan
+		return;
+	}
+	const wasRunning = state.scanRunning;
+	state.scanRunning = showScan(scan);
+	if (state.scanRunning) {
+		state.scanTimer = setTimeout(followScan, 1000);
+	} else if (wasRunning && scan.state === "finished") {
+		loaders[state.tab]().catch(() => {});
+	}
+}
+
+async function rescan() {
+	try {
+		showScan(await api("POST", "/scan", {}));
+		followScan();
+	} catch (error) {
+		setStatus(explain(error), true);
+	}
+}
+
+async function cancelScan() {
+	try {
+		await api("DELETE", "/scan");
+		followScan();
+	} catch (error) {
+		setStatus(explain(error), true);
+	}
+}
+
+async function reloadConfig() {
+	try {
+		await api("POST", "/reload");
+		setStatus("Configuration reloaded");
+		loaders[state.tab]().catch(() => {});
+	} catch (error) {
+		setStatus(explain(error), true);
+	}
+}
+
+document.addEventListener("DOMContentLoaded", () => {
+	$("login-form").addEventListener("submit", (event) => {
+		event.preventDefault();
+		signIn($("token").value.trim());
+		$("token").value = "";
+	});
+	$("logout").addEventListener("click", () => signOut());
+	for (const button of document.querySelectorAll(".tab")) {
+		button.addEventListener("click", () => showTab(button.dataset.tab));
+	}
+	$("library-search").addEventListener("submit", (event) => {
+		event.preventDefault();
+		state.offset = 0;
+		loadLibrary().catch((error) => setStatus(explain(error), true));
+	});
+	$("library-prev").addEventListener("click", () => {
+		state.offset = Math.max(0, state.offset - pageSize);
+		loadLibrary().catch((error) => setStatus(explain(error), true));
+	});
+	$("library-next").addEventListener("click", () => {
+		state.offset += pageSize;
+		loadLibrary().catch((error) => setStatus(explain(error), true));
+	});
+	$("rescan").addEventListener("click", rescan);
+	$("cancel-scan").addEventListener("click", cancelScan);
+	$("reload").addEventListener("click", reloadConfig);
+
+	const token = sessionStorage.getItem(tokenKey);
+	if (token) {
+		signIn(token);
+	}
+});
@@ -0,0 +1,252 @@
+// @title tinshop Audit
+
+// @BasePath /audit/
+
+// Package audit provides an append-only log of security and download events
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+const (
+	// DefaultLimit is the number of events returned when none is asked
+	DefaultLimit = 100
+	// MaxLimit is the biggest number of events returned
+	MaxLimit = 1000
+	// maxLineSize is the longest line read, longer ones are skipped
+	maxLineSize = 64 * 1024
+)
+
+type auditLog struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+// New create a new audit log rotated once maxSize (in MB) is reached
+func New(path string, maxSize int, maxBackups int) repository.Audit {
+	a := &auditLog{
+		path:       path,
+		maxSize:    int64(maxSize) * 1024 * 1024,
+		maxBackups: maxBackups,
+	}
+	if err := a.open(); err != nil {
+		log.Println("[Audit] Unable to open audit log", err)
+	}
+	return a
+}
+
+func (a *auditLog) open() error {
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	a.file = f
+	a.size = fi.Size()
+	return nil
+}
+
+// backupPath returns the path of the nth rotated file
+func (a *auditLog) backupPath(index int) string {
+	return fmt.Sprintf("%s.%d", a.path, index)
+}
+
+func (a *auditLog) rotate() error {
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+	a.file = nil
+
+	if a.maxBackups <= 0 {
+		if err := os.Remove(a.path); err != nil {
+			return err
+		}
+		return a.open()
+	}
+
+	_ = os.Remove(a.backupPath(a.maxBackups))
+	for index := a.maxBackups - 1; index >= 1; index-- {
+		_ = os.Rename(a.backupPath(index), a.backupPath(index+1))
+	}
+	if err := os.Rename(a.path, a.backupPath(1)); err != nil {
+		return err
+	}
+	return a.open()
+}
+
+// Record appends an event to the audit log
+func (a *auditLog) Record(event repository.AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Println("[Audit] Unable to encode event", err)
+		return
+	}
+	line = append(line, '\n')
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.file == nil {
+		return
+	}
+	if a.maxSize > 0 && a.size+int64(len(line)) > a.maxSize {
+		if errRotate := a.rotate(); errRotate != nil {
+			log.Println("[Audit] Unable to rotate audit log", errRotate)
+			if a.file == nil {
+				return
+			}
+		}
+	}
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	if err != nil {
+		log.Println("[Audit] Unable to write event", err)
+	}
+}
+
+// Query returns the events matching the query, oldest first.
+// The files are opened under the lock and read without it, so the events are recorded meanwhile.
+func (a *auditLog) Query(query repository.AuditQuery) ([]repository.AuditEvent, error) {
+	files, err := a.openFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, f := range files {
+			f.file.Close()
+		}
+	}()
+
+	events := make([]repository.AuditEvent, 0)
+	for _, f := range files {
+		events, err = readEvents(io.LimitReader(f.file, f.size), query, events)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if query.Limit > 0 && len(events) > query.Limit {
+		events = events[len(events)-query.Limit:]
+	}
+	return events, nil
+}
+
+// openedFile is a file of the log with its size when opened, the lines appended afterwards are not read
+type openedFile struct {
+	file *os.File
+	size int64
+}
+
+// openFiles opens the existing files of the log, oldest first
+func (a *auditLog) openFiles() ([]openedFile, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	paths := make([]string, 0, a.maxBackups+1)
+	for index := a.maxBackups; index >= 1; index-- {
+		paths = append(paths, a.backupPath(index))
+	}
+	paths = append(paths, a.path)
+
+	files := make([]openedFile, 0, len(paths))
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err == nil {
+			var fi os.FileInfo
+			if fi, err = f.Stat(); err == nil {
+				files = append(files, openedFile{file: f, size: fi.Size()})
+				continue
+			}
+			f.Close()
+		}
+		for _, opened := range files {
+			opened.file.Close()
+		}
+		return nil, err
+	}
+	return files, nil
+}
+
+// readEvents appends the events of the reader matching the query, only the last query.Limit ones are kept
+func readEvents(r io.Reader, query repository.AuditQuery, events []repository.AuditEvent) ([]repository.AuditEvent, error) {
+	reader := bufio.NewReaderSize(r, maxLineSize)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// Skip the rest of a line too long to be an event
+			for err == bufio.ErrBufferFull {
+				_, err = reader.ReadSlice('\n')
+			}
+			continue
+		}
+		if len(line) > 0 {
+			var event repository.AuditEvent
+			if json.Unmarshal(line, &event) == nil && matches(event, query) {
+				events = append(events, event)
+			}
+			if query.Limit > 0 && len(events) >= 2*query.Limit {
+				events = append(events[:0], events[len(events)-query.Limit:]...)
+			}
+		}
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func matches(event repository.AuditEvent, query repository.AuditQuery) bool {
+	if !query.From.IsZero() && event.Time.Before(query.From) {
+		return false
+	}
+	if !query.To.IsZero() && event.Time.After(query.To) {
+		return false
+	}
+	if query.UID != "" && event.UID != query.UID {
+		return false
+	}
+	if query.Type != "" && event.Type != query.Type {
+		return false
+	}
+	return true
+}
+
+// Close closes the audit log
+func (a *auditLog) Close() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
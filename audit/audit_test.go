@@ -0,0 +1,94 @@
+package audit_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/ajmandourah/tinshop-ng/audit"
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+var _ = Describe("Audit", func() {
+	var (
+		path     string
+		auditLog repository.Audit
+		start    time.Time
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "audit.log")
+		start = time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	})
+	AfterEach(func() {
+		Expect(auditLog.Close()).To(Succeed())
+	})
+
+	Context("Without rotation", func() {
+		BeforeEach(func() {
+			auditLog = audit.New(path, 10, 2)
+			auditLog.Record(repository.AuditEvent{Time: start, Type: repository.AuditBlockedDevice, UID: "UID1"})
+			auditLog.Record(repository.AuditEvent{Time: start.Add(time.Hour), Type: repository.AuditDownload, UID: "UID1", TitleID: "0100000000010000", Bytes: 42})
+			auditLog.Record(repository.AuditEvent{Time: start.Add(2 * time.Hour), Type: repository.AuditDownload, UID: "UID2"})
+		})
+		It("Query everything", func() {
+			events, err := auditLog.Query(repository.AuditQuery{})
+			Expect(err).To(BeNil())
+			Expect(events).To(HaveLen(3))
+			Expect(events[1].Bytes).To(Equal(int64(42)))
+		})
+		It("Query by device and time range", func() {
+			events, err := auditLog.Query(repository.AuditQuery{UID: "UID1", From: start.Add(30 * time.Minute)})
+			Expect(err).To(BeNil())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].TitleID).To(Equal("0100000000010000"))
+
+			events, err = auditLog.Query(repository.AuditQuery{To: start.Add(30 * time.Minute)})
+			Expect(err).To(BeNil())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].Type).To(Equal(repository.AuditBlockedDevice))
+		})
+		It("Query by type with a limit", func() {
+			events, err := auditLog.Query(repository.AuditQuery{Type: repository.AuditDownload, Limit: 1})
+			Expect(err).To(BeNil())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].UID).To(Equal("UID2"))
+		})
+	})
+	Context("With a line too long", func() {
+		It("Skips the line and reads the next events", func() {
+			Expect(os.WriteFile(path, append(bytes.Repeat([]byte("x"), 1024*1024), '\n'), 0600)).To(Succeed())
+			auditLog = audit.New(path, 10, 2)
+			auditLog.Record(repository.AuditEvent{Time: start, Type: repository.AuditDownload, UID: "UID1"})
+
+			events, err := auditLog.Query(repository.AuditQuery{})
+			Expect(err).To(BeNil())
+			Expect(events).To(HaveLen(1))
+			Expect(events[0].UID).To(Equal("UID1"))
+		})
+	})
+	Context("With rotation", func() {
+		It("Keep only the configured backups", func() {
+			for i := 0; i < 3; i++ {
+				// Fill the log so the next event rotates it
+				Expect(os.WriteFile(path, bytes.Repeat([]byte("\n"), 1024*1024), 0600)).To(Succeed())
+				auditLog = audit.New(path, 1, 2)
+				auditLog.Record(repository.AuditEvent{Time: start, Type: repository.AuditDownload})
+				Expect(auditLog.Close()).To(Succeed())
+			}
+			auditLog = audit.New(path, 1, 2)
+
+			Expect(path + ".1").To(BeAnExistingFile())
+			Expect(path + ".2").To(BeAnExistingFile())
+			Expect(path + ".3").NotTo(BeAnExistingFile())
+
+			events, err := auditLog.Query(repository.AuditQuery{})
+			Expect(err).To(BeNil())
+			Expect(events).To(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/library"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/users"
+	"github.com/dustin/go-humanize"
+	"github.com/gorilla/mux"
+)
+
+// catalogPageSize is the number of titles on a page of the catalog
+const catalogPageSize = 60
+
+// catalogPolicy forbids any script, the page only shows the box arts hosted by the titledb
+const catalogPolicy = "default-src 'none'; img-src https: data:; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'"
+
+// catalogTitle holds what a visitor can see of a title, never the files nor their url
+type catalogTitle struct {
+	ID          string
+	Name        string
+	BoxArt      string
+	Description string
+	Publisher   string
+	ReleaseDate string
+	Languages   string
+	Players     int
+	Size        string
+	Version     int
+	DLC         int
+}
+
+type catalogPage struct {
+	ShopTitle string
+	Query     string
+	Sort      string
+	Order     string
+	Total     int
+	First     int
+	Last      int
+	Previous  string
+	Next      string
+	Titles    []catalogTitle
+}
+
+// CatalogMatcher matches the catalog path when the catalog is enabled
+func (s *TinShop) CatalogMatcher(r *http.Request, _ *mux.RouteMatch) bool {
+	if s.Shop.Config == nil || !s.Shop.Config.CatalogEnabled() {
+		return false
+	}
+	requested := strings.TrimSuffix(r.URL.Path, "/")
+	return requested != "" && requested == s.Shop.Config.CatalogPath()
+}
+
+// CatalogHandler serves a read-only page listing the games of the library to browsers
+func (s *TinShop) CatalogHandler() http.Handler {
+	catalogTemplate, err := template.ParseFS(assetData, "assets/catalog.tmpl")
+	if err != nil {
+		log.Fatal("[Catalog] Unable to load the catalog template: ", err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values := r.URL.Query()
+		query := repository.TitleQuery{
+			Name: strings.TrimSpace(values.Get("q")),
+			Sort: values.Get("sort"),
+			Desc: values.Get("order") == "desc",
+			// Only the owned games, not the updates or dlc without their base
+			Type:  repository.ContentBase,
+			Limit: catalogPageSize,
+		}
+		if query.Sort == library.SortID || !library.IsValidSort(query.Sort) {
+			query.Sort = library.SortName
+		}
+		if offset, errOffset := strconv.Atoi(values.Get("offset")); errOffset == nil && offset > 0 {
+			query.Offset = offset
+		}
+
+		result := library.Query(s.catalogTitles(r), query)
+		page := catalogPage{
+			ShopTitle: s.Shop.Config.ShopTemplateData().ShopTitle,
+			Query:     query.Name,
+			Sort:      query.Sort,
+			Order:     values.Get("order"),
+			Total:     result.Total,
+			First:     result.Offset + 1,
+			Last:      result.Offset + len(result.Titles),
+			Titles:    make([]catalogTitle, 0, len(result.Titles)),
+		}
+		if result.Offset > 0 {
+			page.Previous = catalogURL(values, result.Offset-catalogPageSize)
+		}
+		if page.Last < result.Total {
+			page.Next = catalogURL(values, page.Last)
+		}
+		for _, title := range result.Titles {
+			page.Titles = append(page.Titles, newCatalogTitle(title))
+		}
+
+		w.Header().Set("Content-Security-Policy", catalogPolicy)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if errExecute := catalogTemplate.Execute(w, page); errExecute != nil {
+			log.Println("[Catalog] Unable to render the catalog", errExecute)
+		}
+	})
+}
+
+// catalogTitles returns the titles of the collections the account of the request can browse
+func (s *TinShop) catalogTitles(r *http.Request) []repository.LibraryTitle {
+	titles := s.libraryTitles()
+	account, ok := s.requestAccount(r)
+	if !ok || users.CanAccess(account, "world") {
+		return titles
+	}
+
+	allowed := make(map[string]bool)
+	for _, collection := range account.Collections {
+		for gameID := range s.Shop.Collection.Filter(collection).Titledb {
+			allowed[gameID] = true
+		}
+	}
+	visible := make([]repository.LibraryTitle, 0, len(titles))
+	for _, title := range titles {
+		if allowed[title.ID] {
+			visible = append(visible, title)
+		}
+	}
+	return visible
+}
+
+func newCatalogTitle(title repository.LibraryTitle) catalogTitle {
+	catalog := catalogTitle{
+		ID:          title.ID,
+		Name:        title.Name,
+		BoxArt:      title.FrontBoxArt,
+		Description: title.Description,
+		Publisher:   title.Publisher,
+		Languages:   strings.ToUpper(strings.Join(title.Languages, ", ")),
+		Players:     title.NumberOfPlayers,
+		Size:        humanize.Bytes(uint64(title.TotalSize)),
+		Version:     title.LatestVersion,
+		DLC:         len(title.DLC),
+	}
+	if catalog.Name == "" {
+		catalog.Name = title.ID
+	}
+	if catalog.BoxArt == "" {
+		catalog.BoxArt = title.IconURL
+	}
+	if releaseDate, err := time.Parse("20060102", strconv.Itoa(title.ReleaseDate)); err == nil {
+		catalog.ReleaseDate = releaseDate.Format("2006-01-02")
+	}
+	return catalog
+}
+
+// catalogURL returns the relative url of another page of the catalog with the same search
+func catalogURL(values url.Values, offset int) string {
+	page := url.Values{}
+	for _, key := range []string{"q", "sort", "order"} {
+		if value := values.Get(key); value != "" {
+			page.Set(key, value)
+		}
+	}
+	if offset > 0 {
+		page.Set("offset", strconv.Itoa(offset))
+	}
+	return "?" + page.Encode()
+}
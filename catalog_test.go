@@ -0,0 +1,103 @@
+package main_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	main "github.com/ajmandourah/tinshop-ng"
+	"github.com/ajmandourah/tinshop-ng/mock_repository"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Catalog", func() {
+	var (
+		handler          http.Handler
+		writer           *httptest.ResponseRecorder
+		myMockConfig     *mock_repository.MockConfig
+		myMockSources    *mock_repository.MockSources
+		myMockCollection *mock_repository.MockCollection
+		myMockUsers      *mock_repository.MockUsers
+	)
+
+	BeforeEach(func() {
+		ctrl := gomock.NewController(GinkgoT())
+		myMockConfig = mock_repository.NewMockConfig(ctrl)
+		myMockSources = mock_repository.NewMockSources(ctrl)
+		myMockCollection = mock_repository.NewMockCollection(ctrl)
+		myMockUsers = mock_repository.NewMockUsers(ctrl)
+		myShop := &main.TinShop{}
+		myShop.Shop = repository.Shop{Config: myMockConfig, Sources: myMockSources, Collection: myMockCollection, Users: myMockUsers}
+
+		r := mux.NewRouter()
+		r.MatcherFunc(myShop.CatalogMatcher).Handler(myShop.CatalogHandler())
+		handler = r
+		writer = httptest.NewRecorder()
+
+		myMockConfig.EXPECT().CatalogPath().Return("/catalog").AnyTimes()
+		myMockConfig.EXPECT().ShopTemplateData().Return(repository.ShopTemplate{ShopTitle: "Family Shop"}).AnyTimes()
+		myMockSources.EXPECT().GetFiles().Return([]repository.FileDesc{
+			{GameID: "0100000000010000", GameInfo: "[0100000000010000][v0].nsp", Size: 2048, Path: "/games/secret/mario.nsp", HostType: repository.LocalFile},
+			{GameID: "0100000000020000", GameInfo: "[0100000000020000][v0].nsp", Size: 4096, Path: "/games/secret/zelda.nsp", HostType: repository.LocalFile},
+		}).AnyTimes()
+		myMockCollection.EXPECT().Library().Return(map[string]repository.TitleDBEntry{
+			"0100000000010000": {ID: "0100000000010000", Name: "Super Mario", Description: "Jump <b>high</b>", NumberOfPlayers: 2, FrontBoxArt: "https://img.example.com/mario.jpg", Languages: []string{"en", "fr"}},
+			"0100000000020000": {ID: "0100000000020000", Name: "Zelda"},
+		}).AnyTimes()
+	})
+
+	It("is not served when disabled", func() {
+		myMockConfig.EXPECT().CatalogEnabled().Return(false).AnyTimes()
+
+		handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/catalog", nil))
+
+		Expect(writer.Code).To(Equal(http.StatusNotFound))
+	})
+	It("lists the games without their files", func() {
+		myMockConfig.EXPECT().CatalogEnabled().Return(true).AnyTimes()
+
+		handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/catalog/", nil))
+
+		Expect(writer.Code).To(Equal(http.StatusOK))
+		Expect(writer.Header().Get("Content-Security-Policy")).To(ContainSubstring("default-src 'none'"))
+		body := writer.Body.String()
+		Expect(body).To(ContainSubstring("Family Shop"))
+		Expect(body).To(ContainSubstring("Super Mario"))
+		Expect(body).To(ContainSubstring("https://img.example.com/mario.jpg"))
+		Expect(body).To(ContainSubstring("2 players"))
+		Expect(body).To(ContainSubstring("EN, FR"))
+		Expect(body).To(ContainSubstring("Jump &lt;b&gt;high&lt;/b&gt;"))
+		Expect(body).To(ContainSubstring("Zelda"))
+		Expect(body).NotTo(ContainSubstring("/games/"))
+		Expect(body).NotTo(ContainSubstring(".nsp"))
+	})
+	It("lists only the collections of the account", func() {
+		myMockConfig.EXPECT().CatalogEnabled().Return(true).AnyTimes()
+		myMockConfig.EXPECT().Get_Httpauth().Return(nil).AnyTimes()
+		myMockUsers.EXPECT().Get("kid").Return(repository.User{Name: "kid", Collections: []string{"fr"}}, nil)
+		myMockCollection.EXPECT().Filter("fr").Return(repository.GameType{Titledb: map[string]repository.TitleDBEntry{
+			"0100000000010000": {ID: "0100000000010000"},
+		}})
+
+		req := httptest.NewRequest(http.MethodGet, "/catalog/", nil)
+		req.SetBasicAuth("kid", "secret")
+		handler.ServeHTTP(writer, req)
+
+		Expect(writer.Code).To(Equal(http.StatusOK))
+		Expect(writer.Body.String()).To(ContainSubstring("Super Mario"))
+		Expect(writer.Body.String()).NotTo(ContainSubstring("Zelda"))
+	})
+	It("searches and sorts the games", func() {
+		myMockConfig.EXPECT().CatalogEnabled().Return(true).AnyTimes()
+
+		handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/catalog?q=zel&sort=size&order=desc", nil))
+
+		Expect(writer.Code).To(Equal(http.StatusOK))
+		Expect(writer.Body.String()).To(ContainSubstring("Zelda"))
+		Expect(writer.Body.String()).NotTo(ContainSubstring("Super Mario"))
+		Expect(writer.Body.String()).To(ContainSubstring(`<option value="size" selected>`))
+	})
+})
@@ -0,0 +1,114 @@
+// @title tinshop Certificates
+
+// @BasePath /certs/
+
+// Package certs provides a tls certificate reloaded when its files change
+package certs
+
+import (
+	"crypto/tls"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Reloader serves the latest valid certificate found on disk
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mutex   sync.RWMutex
+	cert    *tls.Certificate
+	watcher *fsnotify.Watcher
+}
+
+// New loads the certificate and starts watching its files
+func New(certFile string, keyFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	r.watcher = watcher
+
+	// Watch directories rather than files to survive atomic replacement (certbot, kubernetes secrets...)
+	directories := map[string]bool{
+		filepath.Dir(certFile): true,
+		filepath.Dir(keyFile):  true,
+	}
+	for directory := range directories {
+		if errAdd := watcher.Add(directory); errAdd != nil {
+			watcher.Close()
+			return nil, errAdd
+		}
+	}
+	go r.watch()
+
+	return r, nil
+}
+
+func (r *Reloader) watch() {
+	for {
+		select {
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if !r.isWatched(event.Name) || event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				log.Println("[TLS] Unable to reload certificate, keeping the previous one:", err)
+				continue
+			}
+			log.Println("[TLS] Certificate reloaded")
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("[TLS] Watcher error:", err)
+		}
+	}
+}
+
+func (r *Reloader) isWatched(name string) bool {
+	name = filepath.Clean(name)
+	return name == filepath.Clean(r.certFile) || name == filepath.Clean(r.keyFile)
+}
+
+// Reload reads the certificate files again, the current certificate is kept on error
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	r.cert = &cert
+	r.mutex.Unlock()
+	return nil
+}
+
+// GetCertificate returns the current certificate, to be used in tls.Config
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert, nil
+}
+
+// Close stops watching the certificate files
+func (r *Reloader) Close() error {
+	if r.watcher == nil {
+		return nil
+	}
+	return r.watcher.Close()
+}
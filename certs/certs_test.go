@@ -0,0 +1,97 @@
+package certs_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/ajmandourah/tinshop-ng/certs"
+)
+
+func writeCertificate(certFile, keyFile, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(BeNil())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).To(BeNil())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Expect(err).To(BeNil())
+
+	Expect(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)).To(Succeed())
+	Expect(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)).To(Succeed())
+}
+
+func commonName(reloader *certs.Reloader) string {
+	cert, err := reloader.GetCertificate(nil)
+	Expect(err).To(BeNil())
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	Expect(err).To(BeNil())
+	return leaf.Subject.CommonName
+}
+
+var _ = Describe("Certs", func() {
+	var (
+		certFile string
+		keyFile  string
+		reloader *certs.Reloader
+	)
+
+	BeforeEach(func() {
+		dir := GinkgoT().TempDir()
+		certFile = filepath.Join(dir, "cert.pem")
+		keyFile = filepath.Join(dir, "key.pem")
+		reloader = nil
+	})
+	AfterEach(func() {
+		if reloader != nil {
+			Expect(reloader.Close()).To(Succeed())
+		}
+	})
+
+	It("Fails with missing files", func() {
+		var err error
+		reloader, err = certs.New(certFile, keyFile)
+		Expect(err).To(HaveOccurred())
+	})
+	It("Loads the certificate", func() {
+		writeCertificate(certFile, keyFile, "first")
+		var err error
+		reloader, err = certs.New(certFile, keyFile)
+		Expect(err).To(BeNil())
+		Expect(commonName(reloader)).To(Equal("first"))
+	})
+	It("Reloads the certificate when files change", func() {
+		writeCertificate(certFile, keyFile, "first")
+		var err error
+		reloader, err = certs.New(certFile, keyFile)
+		Expect(err).To(BeNil())
+
+		writeCertificate(certFile, keyFile, "second")
+		Eventually(func() string { return commonName(reloader) }, 5*time.Second).Should(Equal("second"))
+	})
+	It("Keeps the previous certificate on invalid files", func() {
+		writeCertificate(certFile, keyFile, "first")
+		var err error
+		reloader, err = certs.New(certFile, keyFile)
+		Expect(err).To(BeNil())
+
+		Expect(os.WriteFile(certFile, []byte("garbage"), 0600)).To(Succeed())
+		Expect(reloader.Reload()).To(HaveOccurred())
+		Expect(commonName(reloader)).To(Equal("first"))
+	})
+})
@@ -0,0 +1,334 @@
+// @title tinshop Client
+
+// @BasePath /client/
+
+// Package client provides a Go client of the tinshop admin api
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+// Client queries the api of a shop with a token
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// StatusError is returned when the api answers with an error status
+type StatusError struct {
+	Method     string
+	Path       string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// New returns a client of the shop at baseURL (ie http://tinshop.example.com:3000) using the api token
+func New(baseURL string, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/") + "/api",
+		token:      token,
+		httpClient: &http.Client{Timeout: time.Minute},
+	}
+}
+
+// WithHTTPClient replaces the http client used to query the api
+func (c *Client) WithHTTPClient(httpClient *http.Client) *Client {
+	c.httpClient = httpClient
+	return c
+}
+
+// Stats returns the statistics summary with the top most downloaded titles
+func (c *Client) Stats(ctx context.Context, top int) (repository.StatsSummary, error) {
+	var summary repository.StatsSummary
+	err := c.do(ctx, http.MethodGet, "/stats"+query("top", intValue(top)), nil, &summary)
+	return summary, err
+}
+
+// StatsSeries returns the hourly or daily statistics of a time range
+func (c *Client) StatsSeries(ctx context.Context, series repository.SeriesQuery) ([]repository.SeriesPoint, error) {
+	var points []repository.SeriesPoint
+	err := c.do(ctx, http.MethodGet, "/stats/series"+query(
+		"resolution", series.Resolution,
+		"from", timeValue(series.From),
+		"to", timeValue(series.To),
+	), nil, &points)
+	return points, err
+}
+
+// ExportStats returns the whole statistics
+func (c *Client) ExportStats(ctx context.Context) (repository.StatsExport, error) {
+	var export repository.StatsExport
+	err := c.do(ctx, http.MethodGet, "/stats/export", nil, &export)
+	return export, err
+}
+
+// ImportStats merges an export into the statistics
+func (c *Client) ImportStats(ctx context.Context, export repository.StatsExport) error {
+	return c.do(ctx, http.MethodPost, "/stats/import", export, nil)
+}
+
+// Titles returns a page of the titles of the library
+func (c *Client) Titles(ctx context.Context, titles repository.TitleQuery) (repository.TitlesPage, error) {
+	order := ""
+	if titles.Desc {
+		order = "desc"
+	}
+	var page repository.TitlesPage
+	err := c.do(ctx, http.MethodGet, "/titles"+query(
+		"offset", intValue(titles.Offset),
+		"limit", intValue(titles.Limit),
+		"sort", titles.Sort,
+		"order", order,
+		"name", titles.Name,
+		"language", titles.Language,
+		"publisher", titles.Publisher,
+		"type", titles.Type,
+	), nil, &page)
+	return page, err
+}
+
+// Title returns a title from its base, update or dlc id
+func (c *Client) Title(ctx context.Context, titleID string) (repository.LibraryTitle, error) {
+	var title repository.LibraryTitle
+	err := c.do(ctx, http.MethodGet, "/titles/"+url.PathEscape(titleID), nil, &title)
+	return title, err
+}
+
+// Search returns the titles whose name, or the name of one of their dlc, contains the text
+func (c *Client) Search(ctx context.Context, text string, limit int) ([]repository.LibraryTitle, error) {
+	var page repository.TitlesPage
+	err := c.do(ctx, http.MethodGet, "/search"+query("q", text, "limit", intValue(limit)), nil, &page)
+	return page.Titles, err
+}
+
+// Files returns a page of the files, of a single source type when not empty
+func (c *Client) Files(ctx context.Context, source repository.HostType, offset int, limit int) (repository.FilesPage, error) {
+	var page repository.FilesPage
+	err := c.do(ctx, http.MethodGet, "/files"+query(
+		"source", string(source),
+		"offset", intValue(offset),
+		"limit", intValue(limit),
+	), nil, &page)
+	return page, err
+}
+
+// UnidentifiedFiles returns the files of the sources which could not be added to the library
+func (c *Client) UnidentifiedFiles(ctx context.Context) ([]repository.FailedFile, error) {
+	var files []repository.FailedFile
+	err := c.do(ctx, http.MethodGet, "/files/unidentified", nil, &files)
+	return files, err
+}
+
+// Missing returns the updates and dlc of the titledb missing in the library
+func (c *Client) Missing(ctx context.Context) ([]repository.MissingContent, error) {
+	var missing []repository.MissingContent
+	err := c.do(ctx, http.MethodGet, "/library/missing", nil, &missing)
+	return missing, err
+}
+
+// Rescan starts a scan of the library in background
+func (c *Client) Rescan(ctx context.Context, scan repository.ScanRequest) (repository.ScanStatus, error) {
+	var status repository.ScanStatus
+	err := c.do(ctx, http.MethodPost, "/scan", scan, &status)
+	return status, err
+}
+
+// ScanStatus returns the progress of the running or last scan
+func (c *Client) ScanStatus(ctx context.Context) (repository.ScanStatus, error) {
+	var status repository.ScanStatus
+	err := c.do(ctx, http.MethodGet, "/scan", nil, &status)
+	return status, err
+}
+
+// CancelScan cancels the running scan
+func (c *Client) CancelScan(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/scan", nil, nil)
+}
+
+// Devices returns the switches seen by the shop
+func (c *Client) Devices(ctx context.Context) ([]repository.Device, error) {
+	var devices []repository.Device
+	err := c.do(ctx, http.MethodGet, "/devices", nil, &devices)
+	return devices, err
+}
+
+// Device returns a switch of the registry
+func (c *Client) Device(ctx context.Context, uid string) (repository.Device, error) {
+	var device repository.Device
+	err := c.do(ctx, http.MethodGet, "/devices/"+url.PathEscape(uid), nil, &device)
+	return device, err
+}
+
+// UpdateDevice names or blocks a switch
+func (c *Client) UpdateDevice(ctx context.Context, uid string, update repository.DeviceRequest) (repository.Device, error) {
+	var device repository.Device
+	err := c.do(ctx, http.MethodPut, "/devices/"+url.PathEscape(uid), update, &device)
+	return device, err
+}
+
+// DeleteDevice removes a switch from the registry
+func (c *Client) DeleteDevice(ctx context.Context, uid string) error {
+	return c.do(ctx, http.MethodDelete, "/devices/"+url.PathEscape(uid), nil, nil)
+}
+
+// Users returns the accounts of the shop
+func (c *Client) Users(ctx context.Context) ([]repository.User, error) {
+	var users []repository.User
+	err := c.do(ctx, http.MethodGet, "/users", nil, &users)
+	return users, err
+}
+
+// User returns an account
+func (c *Client) User(ctx context.Context, name string) (repository.User, error) {
+	var user repository.User
+	err := c.do(ctx, http.MethodGet, "/users/"+url.PathEscape(name), nil, &user)
+	return user, err
+}
+
+// CreateUser creates an account
+func (c *Client) CreateUser(ctx context.Context, create repository.UserRequest) (repository.User, error) {
+	var user repository.User
+	err := c.do(ctx, http.MethodPost, "/users", create, &user)
+	return user, err
+}
+
+// UpdateUser updates the fields set of an account
+func (c *Client) UpdateUser(ctx context.Context, name string, update repository.UserRequest) (repository.User, error) {
+	var user repository.User
+	err := c.do(ctx, http.MethodPut, "/users/"+url.PathEscape(name), update, &user)
+	return user, err
+}
+
+// DeleteUser deletes an account
+func (c *Client) DeleteUser(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/users/"+url.PathEscape(name), nil, nil)
+}
+
+// UnbindUserDevice unbinds a switch from an account
+func (c *Client) UnbindUserDevice(ctx context.Context, name string, uid string) error {
+	return c.do(ctx, http.MethodDelete, "/users/"+url.PathEscape(name)+"/devices/"+url.PathEscape(uid), nil, nil)
+}
+
+// Tokens returns the api tokens, without their secret
+func (c *Client) Tokens(ctx context.Context) ([]repository.APIToken, error) {
+	var tokens []repository.APIToken
+	err := c.do(ctx, http.MethodGet, "/tokens", nil, &tokens)
+	return tokens, err
+}
+
+// CreateToken creates an api token, its secret is only returned once
+func (c *Client) CreateToken(ctx context.Context, create repository.TokenRequest) (repository.CreatedToken, error) {
+	var token repository.CreatedToken
+	err := c.do(ctx, http.MethodPost, "/tokens", create, &token)
+	return token, err
+}
+
+// RevokeToken revokes an api token
+func (c *Client) RevokeToken(ctx context.Context, tokenID string) error {
+	return c.do(ctx, http.MethodDelete, "/tokens/"+url.PathEscape(tokenID), nil, nil)
+}
+
+// Reload reloads the configuration of the shop
+func (c *Client) Reload(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/reload", nil, nil)
+}
+
+// Audit returns the security events of the audit log
+func (c *Client) Audit(ctx context.Context, audit repository.AuditQuery) ([]repository.AuditEvent, error) {
+	var events []repository.AuditEvent
+	err := c.do(ctx, http.MethodGet, "/audit"+query(
+		"from", timeValue(audit.From),
+		"to", timeValue(audit.To),
+		"uid", audit.UID,
+		"type", string(audit.Type),
+		"limit", intValue(audit.Limit),
+	), nil, &events)
+	return events, err
+}
+
+// OpenAPI returns the OpenAPI specification of the shop
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var spec json.RawMessage
+	err := c.do(ctx, http.MethodGet, "/openapi.json", nil, &spec)
+	return spec, err
+}
+
+// do sends the request body as json and decodes the json response into result
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &StatusError{Method: method, Path: strings.SplitN(path, "?", 2)[0], StatusCode: resp.StatusCode}
+	}
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// query returns the query string of the key and value pairs not empty
+func query(pairs ...string) string {
+	values := url.Values{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			values.Set(pairs[i], pairs[i+1])
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "?" + values.Encode()
+}
+
+func intValue(value int) string {
+	if value <= 0 {
+		return ""
+	}
+	return strconv.Itoa(value)
+}
+
+func timeValue(value time.Time) string {
+	if value.IsZero() {
+		return ""
+	}
+	return value.Format(time.RFC3339)
+}
@@ -0,0 +1,136 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/ajmandourah/tinshop-ng/client"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		server    *httptest.Server
+		myClient  *client.Client
+		requests  []*http.Request
+		bodies    []string
+		responses map[string]string
+		ctx       = context.Background()
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		bodies = nil
+		responses = make(map[string]string)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			requests = append(requests, r)
+			bodies = append(bodies, string(body))
+			if r.Header.Get("Authorization") != "Bearer tsk_test" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			response, ok := responses[r.Method+" "+r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if response == "" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(response))
+		}))
+		myClient = client.New(server.URL+"/", "tsk_test")
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("queries the titles with the filters set", func() {
+		responses["GET /api/titles"] = `{"total":1,"offset":0,"limit":10,"titles":[{"id":"0100000000010000","name":"Mario","files":[],"updates":[],"dlc":[]}]}`
+
+		page, err := myClient.Titles(ctx, repository.TitleQuery{Limit: 10, Sort: "size", Desc: true, Name: "mar"})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(page.Total).To(Equal(1))
+		Expect(page.Titles[0].Name).To(Equal("Mario"))
+		Expect(requests[0].URL.Query()).To(Equal(url.Values{
+			"limit": {"10"}, "sort": {"size"}, "order": {"desc"}, "name": {"mar"},
+		}))
+	})
+	It("escapes the path parameters", func() {
+		responses["PUT /api/devices/my switch"] = `{"uid":"my switch","name":"Living room","blocked":true}`
+		name := "Living room"
+		blocked := true
+
+		device, err := myClient.UpdateDevice(ctx, "my switch", repository.DeviceRequest{Name: &name, Blocked: &blocked})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(device.Name).To(Equal("Living room"))
+		Expect(requests[0].URL.EscapedPath()).To(Equal("/api/devices/my%20switch"))
+		Expect(requests[0].Header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(bodies[0]).To(MatchJSON(`{"name":"Living room","blocked":true}`))
+	})
+	It("sends the scan request", func() {
+		responses["POST /api/scan"] = `{"id":3,"state":"running","target":"path","path":"/games/new"}`
+
+		status, err := myClient.Rescan(ctx, repository.ScanRequest{Source: repository.ScanPath, Path: "/games/new"})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.ID).To(Equal(3))
+		Expect(status.State).To(Equal(repository.ScanRunning))
+		Expect(bodies[0]).To(MatchJSON(`{"source":"path","path":"/games/new"}`))
+	})
+	It("accepts the responses without content", func() {
+		responses["POST /api/reload"] = ""
+
+		Expect(myClient.Reload(ctx)).To(Succeed())
+		Expect(requests[0].Method).To(Equal(http.MethodPost))
+		Expect(bodies[0]).To(BeEmpty())
+	})
+	It("returns the created token with its secret", func() {
+		responses["POST /api/tokens"] = `{"id":"abcd","name":"backup","scopes":["read-stats"],"createdAt":"2024-01-02T03:04:05Z","token":"tsk_abcd_secret"}`
+
+		token, err := myClient.CreateToken(ctx, repository.TokenRequest{Name: "backup", Scopes: []string{repository.ScopeReadStats}})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(token.ID).To(Equal("abcd"))
+		Expect(token.Token).To(Equal("tsk_abcd_secret"))
+	})
+	It("returns the status of the errors", func() {
+		_, err := myClient.Title(ctx, "0100000000010000")
+
+		var statusError *client.StatusError
+		Expect(errors.As(err, &statusError)).To(BeTrue())
+		Expect(statusError.StatusCode).To(Equal(http.StatusNotFound))
+		Expect(statusError.Path).To(Equal("/titles/0100000000010000"))
+	})
+	It("refuses an invalid token", func() {
+		_, err := client.New(server.URL, "tsk_bad").Stats(ctx, 0)
+
+		var statusError *client.StatusError
+		Expect(errors.As(err, &statusError)).To(BeTrue())
+		Expect(statusError.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(requests[0].URL.RawQuery).To(BeEmpty())
+	})
+	It("returns the raw specification", func() {
+		responses["GET /api/openapi.json"] = `{"openapi":"3.0.3"}`
+
+		spec, err := myClient.OpenAPI(ctx)
+
+		Expect(err).NotTo(HaveOccurred())
+		var decoded map[string]interface{}
+		Expect(json.Unmarshal(spec, &decoded)).To(Succeed())
+		Expect(decoded["openapi"]).To(Equal("3.0.3"))
+	})
+})
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ajmandourah/tinshop-ng/client"
+	"github.com/ajmandourah/tinshop-ng/config"
+	collection "github.com/ajmandourah/tinshop-ng/gamescollection"
+	"github.com/ajmandourah/tinshop-ng/library"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/sources"
+	"github.com/ajmandourah/tinshop-ng/stats"
+	"github.com/dustin/go-humanize"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	configFlagUsage = "path of the config file (default config.yaml in the data directory, /data or the working directory)"
+	shopFlagUsage   = "url of the running shop whose api is called"
+	tokenFlagUsage  = "api token of the shop (default $TINSHOP_TOKEN)"
+	dbFlagUsage     = "path of the stats database, opened instead of calling the shop while it is stopped"
+	dataFlagUsage   = "directory of the state files of the shop: titledb, databases, audit log and keys (default dataDir of the config, /data when it exists or the working directory)"
+)
+
+const usage = `usage: tinshop [command] [options]
+
+Commands:
+  serve          serve the shop, the default command
+  scan           scan the sources once and print a report
+  hash-password  hash a password for security.httpauth
+  check-config   check the config file
+  titledb update download the titles library again
+  stats          export or import the statistics
+  version        print the version
+
+Run 'tinshop <command> -h' for the options of a command.
+`
+
+// run runs the command of the command line and returns the exit code, the shop is served without command
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return serveCommand(args, stderr)
+	}
+
+	switch args[0] {
+	case "serve":
+		return serveCommand(args[1:], stderr)
+	case "scan":
+		return scanCommand(args[1:], stdout, stderr)
+	case "hash-password":
+		return hashPasswordCommand(args[1:], os.Stdin, stdout, stderr)
+	case "check-config":
+		return checkConfigCommand(args[1:], stdout, stderr)
+	case "titledb":
+		return titledbCommand(args[1:], stdout, stderr)
+	case "stats":
+		return statsCommand(args[1:], stdout, stderr)
+	case "version":
+		fmt.Fprintln(stdout, "tinshop", version)
+		return 0
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+	fmt.Fprintf(stderr, "unknown command '%s'\n%s", args[0], usage)
+	return 2
+}
+
+// useLocation makes the shop use the config file and the data directory given on the command line
+func useLocation(file string, dataDir string) error {
+	if file != "" {
+		// The path is relative to the directory the command is run from
+		absolute, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		config.UseFile(absolute)
+	}
+	if dataDir != "" {
+		absolute, err := filepath.Abs(dataDir)
+		if err != nil {
+			return err
+		}
+		config.SetDataDir(absolute)
+	}
+	return nil
+}
+
+// statsCommand runs `tinshop stats export|import` and returns the exit code
+func statsCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: tinshop stats export|import [options]")
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "export":
+		err = statsExportCommand(args[1:], stdout, stderr)
+	case "import":
+		err = statsImportCommand(args[1:], stderr)
+	default:
+		err = fmt.Errorf("unknown stats command '%s'", args[0])
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+func statsExportCommand(args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("stats export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	shop := flags.String("shop", "http://localhost:3000", shopFlagUsage)
+	token := flags.String("token", os.Getenv("TINSHOP_TOKEN"), tokenFlagUsage)
+	dbPath := flags.String("db", "", dbFlagUsage)
+	format := flags.String("format", "json", "export format: json or csv")
+	table := flags.String("table", "devices", "table exported in csv: titles, devices, hourly or daily")
+	output := flags.String("o", "", "output file (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format != "json" && *format != "csv" {
+		return fmt.Errorf("unknown format '%s'", *format)
+	}
+
+	var export repository.StatsExport
+	var err error
+	if *dbPath != "" {
+		export, err = exportStatsFile(*dbPath)
+	} else if *token == "" {
+		return errors.New("an api token with the read-stats scope is needed, set -token or TINSHOP_TOKEN")
+	} else {
+		export, err = client.New(*shop, *token).ExportStats(context.Background())
+	}
+	if err != nil {
+		return err
+	}
+
+	w := stdout
+	if *output != "" {
+		file, errCreate := os.Create(*output)
+		if errCreate != nil {
+			return errCreate
+		}
+		defer file.Close()
+		w = file
+	}
+	if *format == "csv" {
+		return stats.WriteCSV(w, export, *table)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
+
+func statsImportCommand(args []string, stderr io.Writer) error {
+	flags := flag.NewFlagSet("stats import", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	shop := flags.String("shop", "http://localhost:3000", shopFlagUsage)
+	token := flags.String("token", os.Getenv("TINSHOP_TOKEN"), tokenFlagUsage)
+	dbPath := flags.String("db", "", dbFlagUsage)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: tinshop stats import [-shop url -token token | -db stats.db] export.json")
+	}
+	if *dbPath == "" && *token == "" {
+		return errors.New("an api token with the manage-stats scope is needed, set -token or TINSHOP_TOKEN")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var export repository.StatsExport
+	if err := json.NewDecoder(file).Decode(&export); err != nil {
+		return fmt.Errorf("read export: %w", err)
+	}
+
+	if *dbPath != "" {
+		err = importStatsFile(*dbPath, export)
+	} else {
+		err = client.New(*shop, *token).ImportStats(context.Background(), export)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stderr, "Imported stats of", len(export.Devices), "switch and", len(export.Titles), "titles")
+	return nil
+}
+
+// exportStatsFile exports a stats database of a stopped shop, it is locked while the shop runs
+func exportStatsFile(path string) (repository.StatsExport, error) {
+	store := stats.New(path)
+	if err := store.Load(); err != nil {
+		return repository.StatsExport{}, err
+	}
+	defer store.Close()
+	return store.Export()
+}
+
+// importStatsFile merges an export into a stats database of a stopped shop
+func importStatsFile(path string, export repository.StatsExport) error {
+	store := stats.New(path)
+	if err := store.Load(); err != nil {
+		return err
+	}
+	if err := store.Import(export); err != nil {
+		_ = store.Close()
+		return err
+	}
+	return store.Close()
+}
+
+// checkConfigCommand runs `tinshop check-config` and returns the exit code
+func checkConfigCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check-config", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	file := flags.String("config", "", configFlagUsage)
+	dataDir := flags.String("data", "", dataFlagUsage)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	// The file is read as given, only the data directory where it is searched is set
+	if err := useLocation("", *dataDir); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	used, problems := config.Check(*file)
+	warnings := 0
+	for _, problem := range problems {
+		if problem.Severity == repository.ConfigWarning {
+			warnings++
+		}
+		if problem.Field == "" {
+			fmt.Fprintf(stdout, "%-7s %s\n", problem.Severity, problem.Message)
+		} else {
+			fmt.Fprintf(stdout, "%-7s %s: %s\n", problem.Severity, problem.Field, problem.Message)
+		}
+	}
+	if config.HasErrors(problems) {
+		fmt.Fprintln(stderr, "The configuration is invalid, the shop will not use it")
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s is valid (%d warnings)\n", used, warnings)
+	return 0
+}
+
+// scanCommand runs `tinshop scan` and returns the exit code.
+// The files are never renamed, the scan only reports what the shop would serve.
+func scanCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	file := flags.String("config", "", configFlagUsage)
+	dataDir := flags.String("data", "", dataFlagUsage)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if err := useLocation(*file, *dataDir); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	cfg := config.New()
+	if err := cfg.ReadConfig(); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	if err := prepareDataDir(cfg); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	loadKeys(cfg)
+	collection.Rename = false
+
+	games := collection.New(cfg)
+	games.Load()
+	games.OnConfigUpdate(cfg)
+	allSources := sources.New(games)
+	allSources.OnConfigUpdate(cfg)
+
+	writeScanReport(stdout, cfg, games, allSources)
+	return 0
+}
+
+// writeScanReport prints the files found by the sources, by content type, and the files which failed
+func writeScanReport(w io.Writer, cfg repository.Config, games repository.Collection, allSources repository.Sources) {
+	files := allSources.GetFiles()
+	counts := map[string]int{}
+	var size int64
+	unknown := make([]repository.FileDesc, 0)
+	for _, file := range files {
+		contentType := library.ContentType(file.GameID)
+		counts[contentType]++
+		size += file.Size
+
+		// The titles library has no entry for the updates
+		titleID := file.GameID
+		if contentType == repository.ContentUpdate {
+			titleID = library.BaseID(titleID)
+		}
+		if !games.HasGameIDInLibrary(titleID) {
+			unknown = append(unknown, file)
+		}
+	}
+
+	fmt.Fprintf(w, "Sources: %d directories, %d nfs shares\n", len(cfg.Directories()), len(cfg.NfsShares()))
+	fmt.Fprintf(w, "Files:   %d (%d base, %d update, %d dlc), %s\n", len(files),
+		counts[repository.ContentBase], counts[repository.ContentUpdate], counts[repository.ContentDLC], humanize.Bytes(uint64(size)))
+	fmt.Fprintf(w, "Games:   %d\n", games.CountGames())
+
+	fmt.Fprintf(w, "Unknown: %d\n", len(unknown))
+	for _, file := range unknown {
+		fmt.Fprintf(w, "  %s %s\n", file.GameID, file.Path)
+	}
+	failed := allSources.FailedFiles()
+	fmt.Fprintf(w, "Failed:  %d\n", len(failed))
+	for _, file := range failed {
+		fmt.Fprintf(w, "  %s: %s\n", file.Path, file.Reason)
+	}
+}
+
+// hashPasswordCommand runs `tinshop hash-password`, the password is read from stdin
+// to stay out of the shell history
+func hashPasswordCommand(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("hash-password", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	user := flags.String("user", "", "print the whole security.httpauth entry of this user")
+	cost := flags.Int("cost", bcrypt.DefaultCost, "bcrypt cost")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	fmt.Fprint(stderr, "Password: ")
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		fmt.Fprintln(stderr, "Error: the password is empty")
+		return 1
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), *cost)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	if *user != "" {
+		fmt.Fprintf(stdout, "%s:%s\n", *user, hash)
+	} else {
+		fmt.Fprintln(stdout, string(hash))
+	}
+	return 0
+}
+
+// titledbCommand runs `tinshop titledb update` and returns the exit code
+func titledbCommand(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "update" {
+		fmt.Fprintln(stderr, "usage: tinshop titledb update [options]")
+		return 2
+	}
+
+	flags := flag.NewFlagSet("titledb update", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	file := flags.String("config", "", configFlagUsage)
+	dataDir := flags.String("data", "", dataFlagUsage)
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if err := useLocation(*file, *dataDir); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	cfg := config.New()
+	if err := cfg.ReadConfig(); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	if err := prepareDataDir(cfg); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	titles, err := collection.UpdateTitleDB(cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "%s updated with %d titles, restart the shop to use it\n", cfg.DataPath(collection.TitleDBFile), titles)
+	return 0
+}
@@ -6,9 +6,11 @@
 package config
 
 import (
-	"fmt"
 	"log"
 	"net"
+	"os"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -25,13 +27,66 @@ type debug struct {
 }
 
 type security struct {
-	Whitelist   []string `mapstructure:"whitelist"`
-	Blacklist   []string `mapstructure:"blacklist"`
-	BannedTheme []string `mapstructure:"bannedTheme"`
-	ForwardAuth string   `mapstructure:"forwardAuth"`
-	Hauth       string   `mapstructure:"hauth"`
-	Httpauth    []string `mapstructure:"httpauth"`
+	Whitelist   []string           `mapstructure:"whitelist"`
+	Blacklist   []string           `mapstructure:"blacklist"`
+	BannedTheme []string           `mapstructure:"bannedTheme"`
+	ForwardAuth string             `mapstructure:"forwardAuth"`
+	Hauth       string             `mapstructure:"hauth"`
+	Uauth       []repository.Uauth `mapstructure:"uauth"`
+	Httpauth    []string           `mapstructure:"httpauth"`
+	// TrustedProxies lists the networks allowed to send the client ip in forwarding headers
+	TrustedProxies []string `mapstructure:"trustedProxies"`
+	IPFilter       ipFilter `mapstructure:"ipFilter"`
+}
+
+type ipFilter struct {
+	GeoIPDatabase string              `mapstructure:"geoipDatabase"`
+	Index         repository.IPPolicy `mapstructure:"index"`
+	Download      repository.IPPolicy `mapstructure:"download"`
+}
+
+type admin struct {
+	AllowedOrigins []string `mapstructure:"allowedOrigins"`
+}
+
+type audit struct {
+	MaxSize    int `mapstructure:"maxSize"`
+	MaxBackups int `mapstructure:"maxBackups"`
+}
+
+type statsRetention struct {
+	HourlyDays int `mapstructure:"hourlyDays"`
+	DailyDays  int `mapstructure:"dailyDays"`
+}
 
+type statsConfig struct {
+	Path      string         `mapstructure:"path"`
+	Retention statsRetention `mapstructure:"retention"`
+}
+
+type metricsConfig struct {
+	Token string `mapstructure:"token"`
+}
+
+// reservedPaths are the first parts of the paths used by the shop
+var reservedPaths = []string{"api", "admin", "games", "healthz", "metrics", "readyz"} //nolint:gochecknoglobals
+
+// configFile is the file given on the command line, config.yaml is searched when empty
+var configFile string //nolint:gochecknoglobals
+
+// dataDirFlag is the data directory given on the command line, it overrides the environment and the file
+var dataDirFlag string //nolint:gochecknoglobals
+
+type catalogConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`
+}
+
+type tlsConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	Cert             string `mapstructure:"cert"`
+	Key              string `mapstructure:"key"`
+	RedirectHTTPPort int    `mapstructure:"redirectHttpPort"`
 }
 
 type nsp struct {
@@ -44,17 +99,25 @@ type Configuration struct {
 	ShopHost             string                             `mapstructure:"host"`
 	ShopProtocol         string                             `mapstructure:"protocol"`
 	Keys                 string                             `mapstructure:"keys"`
+	DataDirectory        string                             `mapstructure:"dataDir"`
 	RenameFiles          bool                               `mapstructure:"renameFiles"`
 	ShopWelcomeMessage   string                             `mapstructure:"welcomeMessage"`
 	ShopNoWelcomeMessage bool                               `mapstructure:"noWelcomeMessage"`
 	ShopPort             int                                `mapstructure:"port"`
+	TLS                  tlsConfig                          `mapstructure:"tls"`
 	Debug                debug                              `mapstructure:"debug"`
 	Proxy                bool                               `mapstructure:"reverseProxy"`
 	AllSources           repository.ConfigSources           `mapstructure:"sources"`
 	Name                 string                             `mapstructure:"name"`
 	Security             security                           `mapstructure:"security"`
+	Admin                admin                              `mapstructure:"admin"`
+	Audit                audit                              `mapstructure:"audit"`
+	Stats                statsConfig                        `mapstructure:"stats"`
+	Metrics              metricsConfig                      `mapstructure:"metrics"`
 	CustomTitleDB        map[string]repository.TitleDBEntry `mapstructure:"customTitledb"`
 	NSP                  nsp                                `mapstructure:"nsp"`
+	AllWebhooks          []repository.Webhook               `mapstructure:"webhooks"`
+	Catalog              catalogConfig                      `mapstructure:"catalog"`
 	shopTemplateData     repository.ShopTemplate
 
 	allHooks       []func(repository.Config)
@@ -66,78 +129,221 @@ func New() repository.Config {
 	return &Configuration{}
 }
 
-// LoadConfig handles viper under the hood
-func (cfg *Configuration) LoadConfig() {
-	viper.SetConfigName("config")     // name of config file (without extension)
-	viper.SetConfigType("yaml")       // REQUIRED if the config file does not have the extension in the name
-	viper.AddConfigPath("/data")      // for the docker image
-	viper.AddConfigPath(".")          // optionally look for config in the working directory
-	viper.SetTypeByDefaultValue(true) // Allows []string to be parsed from Env Vars
-
-	viper.SetDefault("host", "")
-	viper.SetDefault("protocol", "http")
-	viper.SetDefault("keys", "prod.keys")
-	viper.SetDefault("renameFiles", "false")
-	viper.SetDefault("name", "TinShop")
-	viper.SetDefault("reverseProxy", false)
-	viper.SetDefault("welcomeMessage", "Welcome to your own TinShop!")
-	viper.SetDefault("noWelcomeMessage", false)
-
-	viper.SetDefault("debug.nfs", false)
-	viper.SetDefault("debug.noSecurity", false)
-	viper.SetDefault("debug.ticket", false)
-
-	viper.SetDefault("nsp.checkVerified", false)
+// UseFile makes LoadConfig read this file instead of searching config.yaml in /data and the working directory
+func UseFile(file string) {
+	configFile = file
+}
 
-	viper.SetDefault("sources.directories", []string{"./games"})
-	viper.SetDefault("sources.nfs", []string{})
+// SetDataDir overrides the data directory of the environment and of the configuration file
+func SetDataDir(dir string) {
+	dataDirFlag = dir
+	viper.Set("dataDir", dir)
+}
 
-	viper.SetDefault("security.bannedTheme", []string{})
-	viper.SetDefault("security.whitelist", []string{})
-	viper.SetDefault("security.blacklist", []string{})
-	viper.SetDefault("security.forwardAuth", "")
-	viper.SetDefault("security.hauth", "")
+// SetPort overrides the port of the configuration file, it is kept across reloads
+func SetPort(port int) {
+	viper.Set("port", port)
+}
 
-	viper.SetEnvPrefix("TINSHOP")
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
-	viper.AutomaticEnv()
+// LoadConfig handles viper under the hood, the shop must not start when an error is returned
+func (cfg *Configuration) LoadConfig() error {
+	setup(viper.GetViper(), configFile)
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			// Config file not found; ignore error if desired
 			log.Println("Config not found!")
-			err := viper.WriteConfigAs("/data/config.yaml")
-			if err != nil {
-				log.Println("Error while creating config.yaml in /data, most likely you are not in a container.")
+			file := filepath.Join(searchedDataDir(viper.GetViper()), "config.yaml")
+			if err := viper.WriteConfigAs(file); err != nil {
+				log.Println("Error while creating", file, err)
 			}
 		} else {
 			// Config file was found but another error was produced
-			panic(fmt.Errorf("fatal error config file: %w", err))
+			return &ValidationError{Problems: readProblems(err)}
 		}
 	}
 
+	if err := cfg.configChange(); err != nil {
+		return err
+	}
+
 	viper.OnConfigChange(func(e fsnotify.Event) {
 		log.Println("Config file changed, update new configuration...")
-		cfg.configChange()
+		if err := cfg.Reload(); err != nil {
+			log.Println("[Config] Keeping the last good configuration,", err)
+		}
 	})
 	viper.WatchConfig()
+	return nil
+}
+
+// ReadConfig reads and applies the configuration once for the commands, unlike LoadConfig
+// a missing file is not created and the file is not watched
+func (cfg *Configuration) ReadConfig() error {
+	setup(viper.GetViper(), configFile)
 
-	cfg.configChange()
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return &ValidationError{Problems: readProblems(err)}
+		}
+		log.Println("Config not found, using the default values")
+	}
+	return cfg.configChange()
 }
 
-func (cfg *Configuration) configChange() {
+// setup tells v where to find the configuration file, the default values and the environment variables
+func setup(v *viper.Viper, file string) {
+	v.SetEnvPrefix("TINSHOP")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	if file != "" {
+		v.SetConfigFile(file)
+	} else {
+		v.SetConfigName("config") // name of config file (without extension)
+		if dir := v.GetString("dataDir"); dir != "" {
+			v.AddConfigPath(dir) // given by the flag or the environment
+		}
+		v.AddConfigPath("/data") // for the docker image
+		v.AddConfigPath(".")     // optionally look for config in the working directory
+	}
+	v.SetConfigType("yaml")       // REQUIRED if the config file does not have the extension in the name
+	v.SetTypeByDefaultValue(true) // Allows []string to be parsed from Env Vars
+
+	v.SetDefault("host", "")
+	v.SetDefault("protocol", "http")
+	v.SetDefault("keys", "prod.keys")
+	v.SetDefault("dataDir", "")
+	v.SetDefault("renameFiles", "false")
+	v.SetDefault("name", "TinShop")
+	v.SetDefault("reverseProxy", false)
+	v.SetDefault("welcomeMessage", "Welcome to your own TinShop!")
+	v.SetDefault("noWelcomeMessage", false)
+
+	v.SetDefault("tls.enabled", false)
+	v.SetDefault("tls.cert", "")
+	v.SetDefault("tls.key", "")
+	v.SetDefault("tls.redirectHttpPort", 0)
+
+	v.SetDefault("debug.nfs", false)
+	v.SetDefault("debug.noSecurity", false)
+	v.SetDefault("debug.ticket", false)
+
+	v.SetDefault("nsp.checkVerified", false)
+
+	v.SetDefault("sources.directories", []string{"./games"})
+	v.SetDefault("sources.nfs", []string{})
+
+	v.SetDefault("security.bannedTheme", []string{})
+	v.SetDefault("security.whitelist", []string{})
+	v.SetDefault("security.blacklist", []string{})
+	v.SetDefault("security.forwardAuth", "")
+	v.SetDefault("security.hauth", "")
+	v.SetDefault("security.uauth", []repository.Uauth{})
+	v.SetDefault("security.trustedProxies", []string{"127.0.0.1/32", "::1/128"})
+	v.SetDefault("security.ipFilter.geoipDatabase", "")
+	v.SetDefault("security.ipFilter.index.allow", []string{})
+	v.SetDefault("security.ipFilter.index.deny", []string{})
+	v.SetDefault("security.ipFilter.download.allow", []string{})
+	v.SetDefault("security.ipFilter.download.deny", []string{})
+
+	v.SetDefault("admin.allowedOrigins", []string{})
+
+	v.SetDefault("audit.maxSize", 10)
+	v.SetDefault("audit.maxBackups", 5)
+
+	v.SetDefault("stats.path", "stats.db")
+	v.SetDefault("stats.retention.hourlyDays", 7)
+	v.SetDefault("stats.retention.dailyDays", 365)
+
+	v.SetDefault("metrics.token", "")
+
+	v.SetDefault("catalog.enabled", false)
+	v.SetDefault("catalog.path", "/catalog")
+
+}
+
+// searchedDataDir returns the data directory known before reading the configuration file
+func searchedDataDir(v *viper.Viper) string {
+	if dir := v.GetString("dataDir"); dir != "" {
+		return dir
+	}
+	return defaultDataDir()
+}
+
+// defaultDataDir returns /data for the docker image, the working directory otherwise
+func defaultDataDir() string {
+	if info, err := os.Stat("/data"); err == nil && info.IsDir() {
+		return "/data"
+	}
+	return "."
+}
+
+// Check reads the configuration file like the shop does, or the given file, and returns
+// the file read with the problems of the configuration
+func Check(file string) (string, []repository.ConfigProblem) {
+	v := viper.New()
+	if dataDirFlag != "" {
+		v.Set("dataDir", dataDirFlag)
+	}
+	setup(v, file)
+
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			return "", []repository.ConfigProblem{{
+				Severity: repository.ConfigError,
+				Message:  "config.yaml not found in the data directory, /data nor in the working directory",
+			}}
+		}
+		return v.ConfigFileUsed(), readProblems(err)
+	}
+
+	_, problems := decode(v)
+	return v.ConfigFileUsed(), problems
+}
+
+// Reload reads again the configuration file and apply it, an invalid configuration is not applied
+func (cfg *Configuration) Reload() error {
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return &ValidationError{Problems: readProblems(err)}
+		}
+	}
+	log.Println("Reloading configuration...")
+	return cfg.configChange()
+}
+
+func (cfg *Configuration) configChange() error {
+	newConfig, problems := loadAndCompute()
+	for _, problem := range problems {
+		log.Printf("[Config] %s %s\n", problem.Severity, formatProblem(problem))
+	}
+	if HasErrors(problems) {
+		return &ValidationError{Problems: problems}
+	}
+
 	// Call all before hooks
 	for _, hook := range cfg.beforeAllHooks {
 		hook(cfg)
 	}
 
-	newConfig := loadAndCompute()
 	cfg.rootShop = newConfig.rootShop
 	cfg.ShopHost = newConfig.ShopHost
 	cfg.ShopProtocol = newConfig.ShopProtocol
 	cfg.Keys = newConfig.Keys
+	// The state files are opened at start, the data directory is kept until a restart
+	dataDir := newConfig.DataDirectory
+	if dataDir == "" {
+		dataDir = defaultDataDir()
+	}
+	if cfg.DataDirectory == "" {
+		cfg.DataDirectory = dataDir
+	} else if dataDir != cfg.DataDirectory {
+		log.Printf("[Config] dataDir changed, restart the shop to use '%s'\n", dataDir)
+	}
 	cfg.RenameFiles = newConfig.RenameFiles
 	cfg.ShopPort = newConfig.ShopPort
+	cfg.TLS = newConfig.TLS
 	if newConfig.ShopWelcomeMessage != "" {
 		cfg.ShopWelcomeMessage = newConfig.ShopWelcomeMessage
 	} else {
@@ -149,6 +355,18 @@ func (cfg *Configuration) configChange() {
 	cfg.AllSources = newConfig.AllSources
 	cfg.Name = newConfig.Name
 	cfg.Security = newConfig.Security
+	if err := utils.SetTrustedProxies(cfg.Security.TrustedProxies); err != nil {
+		log.Println("[Config]", err)
+	}
+	cfg.Admin = newConfig.Admin
+	cfg.Audit = newConfig.Audit
+	cfg.Stats = newConfig.Stats
+	cfg.Metrics = newConfig.Metrics
+	cfg.AllWebhooks = newConfig.AllWebhooks
+	cfg.Catalog = newConfig.Catalog
+	if cfg.Catalog.Enabled && cfg.CatalogPath() == "" {
+		log.Printf("[Config] Catalog path '%s' is used by the shop, catalog disabled\n", cfg.Catalog.Path)
+	}
 	cfg.CustomTitleDB = newConfig.CustomTitleDB
 	cfg.NSP = newConfig.NSP
 	cfg.shopTemplateData = newConfig.shopTemplateData
@@ -157,18 +375,27 @@ func (cfg *Configuration) configChange() {
 	for _, hook := range cfg.allHooks {
 		hook(cfg)
 	}
+	return nil
 }
 
-func loadAndCompute() *Configuration {
-	var loadedConfig = &Configuration{}
-	err := viper.Unmarshal(&loadedConfig)
-
-	if err != nil {
-		log.Fatalln(err)
+// loadAndCompute returns the configuration read by viper with its problems, nil when it has errors
+func loadAndCompute() (*Configuration, []repository.ConfigProblem) {
+	loadedConfig, problems := decode(viper.GetViper())
+	if HasErrors(problems) {
+		return nil, problems
 	}
 	ComputeDefaultValues(loadedConfig)
 
-	return loadedConfig
+	return loadedConfig, problems
+}
+
+// decode returns the configuration read by v with its problems
+func decode(v *viper.Viper) (*Configuration, []repository.ConfigProblem) {
+	var loadedConfig = &Configuration{}
+	if err := v.Unmarshal(&loadedConfig); err != nil {
+		return nil, decodeProblems(err)
+	}
+	return loadedConfig, Validate(loadedConfig)
 }
 
 // ComputeDefaultValues change the value taken from the config file
@@ -177,10 +404,14 @@ func ComputeDefaultValues(config repository.Config) repository.Config {
 	// Compute rootShop url
 	// ----------------------------------------------------------
 	var rootShop string
-	if config.Protocol() == "" {
+	protocol := config.Protocol()
+	if config.TLSEnabled() {
+		protocol = "https"
+	}
+	if protocol == "" {
 		rootShop = "http"
 	} else {
-		rootShop = config.Protocol()
+		rootShop = protocol
 	}
 	rootShop += "://"
 	if config.Host() == "" {
@@ -229,7 +460,7 @@ func ComputeDefaultValues(config repository.Config) repository.Config {
 	if !config.ReverseProxy() {
 		if config.Port() == 0 {
 			rootShop += ":3000"
-		} else if !(config.Port() == 443 && config.Protocol() == "https") && !(config.Port() == 80 && config.Protocol() == "http") {
+		} else if !(config.Port() == 443 && protocol == "https") && !(config.Port() == 80 && protocol == "http") {
 			rootShop += ":" + strconv.Itoa(config.Port())
 		}
 	}
@@ -283,9 +514,22 @@ func (cfg *Configuration) Protocol() string {
 	return cfg.ShopProtocol
 }
 
-// Protocol returns the path/keys file
+// ProdKeys returns the path of the keys file, relative paths are in the data directory
 func (cfg *Configuration) ProdKeys() string {
-	return cfg.Keys
+	return cfg.DataPath(cfg.Keys)
+}
+
+// DataDir returns the directory of the state files of the shop
+func (cfg *Configuration) DataDir() string {
+	return cfg.DataDirectory
+}
+
+// DataPath returns the path of a state file, relative paths are in the data directory
+func (cfg *Configuration) DataPath(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(cfg.DataDirectory, name)
 }
 
 // Tells if files should be renamed after decrypting
@@ -303,6 +547,26 @@ func (cfg *Configuration) Port() int {
 	return cfg.ShopPort
 }
 
+// TLSEnabled tells if the shop is served over https directly
+func (cfg *Configuration) TLSEnabled() bool {
+	return cfg.TLS.Enabled
+}
+
+// TLSCertFile returns the path of the certificate file
+func (cfg *Configuration) TLSCertFile() string {
+	return cfg.TLS.Cert
+}
+
+// TLSKeyFile returns the path of the private key file
+func (cfg *Configuration) TLSKeyFile() string {
+	return cfg.TLS.Key
+}
+
+// TLSRedirectPort returns the port of the http listener redirecting to https (0 to disable)
+func (cfg *Configuration) TLSRedirectPort() int {
+	return cfg.TLS.RedirectHTTPPort
+}
+
 // DebugTicket tells if we should display additional log for ticket verification
 func (cfg *Configuration) DebugTicket() bool {
 	return cfg.Debug.Ticket
@@ -358,16 +622,149 @@ func (cfg *Configuration) VerifyNSP() bool {
 	return cfg.NSP.CheckVerified
 }
 
+// AdminAllowedOrigins returns the origins allowed to query the admin api
+func (cfg *Configuration) AdminAllowedOrigins() []string {
+	return cfg.Admin.AllowedOrigins
+}
+
+// AuditMaxSize returns the size in MB before rotating the audit log
+func (cfg *Configuration) AuditMaxSize() int {
+	return cfg.Audit.MaxSize
+}
+
+// AuditMaxBackups returns the number of rotated audit logs to keep
+func (cfg *Configuration) AuditMaxBackups() int {
+	return cfg.Audit.MaxBackups
+}
+
+// StatsHourlyRetention returns the number of days hourly statistics are kept before rollup into days
+func (cfg *Configuration) StatsHourlyRetention() int {
+	return cfg.Stats.Retention.HourlyDays
+}
+
+// StatsDailyRetention returns the number of days daily statistics are kept (0 to keep forever)
+func (cfg *Configuration) StatsDailyRetention() int {
+	return cfg.Stats.Retention.DailyDays
+}
+
+// StatsPath returns the path of the statistics database
+func (cfg *Configuration) StatsPath() string {
+	if cfg.Stats.Path == "" {
+		return cfg.DataPath("stats.db")
+	}
+	return cfg.DataPath(cfg.Stats.Path)
+}
+
+// MetricsToken returns the bearer token protecting /metrics (empty when not protected)
+func (cfg *Configuration) MetricsToken() string {
+	return cfg.Metrics.Token
+}
+
 // ForwardAuthURL returns the url of the forward auth
 func (cfg *Configuration) ForwardAuthURL() string {
 	return cfg.Security.ForwardAuth
 }
 
+// TrustedProxies returns the networks allowed to send the client ip in forwarding headers
+func (cfg *Configuration) TrustedProxies() []string {
+	return cfg.Security.TrustedProxies
+}
+
+// IPFilterIndex returns the ip rules applied to the index routes
+func (cfg *Configuration) IPFilterIndex() repository.IPPolicy {
+	return cfg.Security.IPFilter.Index
+}
+
+// IPFilterDownload returns the ip rules applied to the download routes
+func (cfg *Configuration) IPFilterDownload() repository.IPPolicy {
+	return cfg.Security.IPFilter.Download
+}
+
+// GeoIPDatabase returns the path of the GeoIP csv database
+func (cfg *Configuration) GeoIPDatabase() string {
+	return cfg.Security.IPFilter.GeoIPDatabase
+}
+
+// Webhooks returns the urls notified of the shop events
+func (cfg *Configuration) Webhooks() []repository.Webhook {
+	return cfg.AllWebhooks
+}
+
+// CatalogEnabled tells if the catalog page is served to browsers
+func (cfg *Configuration) CatalogEnabled() bool {
+	return cfg.Catalog.Enabled && cfg.CatalogPath() != ""
+}
+
+// CatalogPath returns the path of the catalog page, empty when it would hide a route of the shop
+func (cfg *Configuration) CatalogPath() string {
+	catalogPath := path.Clean("/" + cfg.Catalog.Path)
+	firstPart := strings.Split(catalogPath[1:], "/")[0]
+	if catalogPath == "/" || utils.Contains(reservedPaths, firstPart) || utils.IsValidFilter(firstPart) {
+		return ""
+	}
+	return catalogPath
+}
+
 // get Hauth code
 func (cfg *Configuration) Get_Hauth() string {
 	return cfg.Security.Hauth
 }
 
+// IsValidUauth tells if the Uauth header is the one of the shop url (always true when no value is configured)
+func (cfg *Configuration) IsValidUauth(shopURL string, value string) bool {
+	if len(cfg.Security.Uauth) == 0 {
+		return true
+	}
+	shopURL = NormalizeShopURL(shopURL)
+	for _, accepted := range cfg.Security.Uauth {
+		if NormalizeShopURL(accepted.URL) == shopURL && accepted.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidHostUauth tells if the Uauth header is the one of a shop url of the host,
+// downloads are requested with the Uauth of the shop url they are listed in
+func (cfg *Configuration) IsValidHostUauth(host string, value string) bool {
+	if len(cfg.Security.Uauth) == 0 {
+		return true
+	}
+	host = NormalizeShopURL(host)
+	for _, accepted := range cfg.Security.Uauth {
+		acceptedHost, _, _ := strings.Cut(NormalizeShopURL(accepted.URL), "/")
+		if acceptedHost == host && accepted.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// HasUauth tells if accepted Uauth values are set for the shop url
+func (cfg *Configuration) HasUauth(shopURL string) bool {
+	shopURL = NormalizeShopURL(shopURL)
+	for _, accepted := range cfg.Security.Uauth {
+		if NormalizeShopURL(accepted.URL) == shopURL {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeShopURL returns the host and path of a shop url without scheme nor trailing slash,
+// ie "https://Shop.example.com/switch/" becomes "shop.example.com/switch"
+func NormalizeShopURL(shopURL string) string {
+	if _, rest, found := strings.Cut(shopURL, "://"); found {
+		shopURL = rest
+	}
+	shopURL = strings.TrimRight(strings.TrimSpace(shopURL), "/")
+	host, path, found := strings.Cut(shopURL, "/")
+	if !found {
+		return strings.ToLower(host)
+	}
+	return strings.ToLower(host) + "/" + path
+}
+
 // get Httpauth list
 func (cfg *Configuration) Get_Httpauth() []string {
 	return cfg.Security.Httpauth
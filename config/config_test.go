@@ -64,6 +64,10 @@ var _ = Describe("Config", func() {
 					Port().
 					Return(0).
 					AnyTimes()
+				myMockConfig.EXPECT().
+					TLSEnabled().
+					Return(false).
+					AnyTimes()
 				myMockConfig.EXPECT().
 					ReverseProxy().
 					Return(false).
@@ -89,6 +93,10 @@ var _ = Describe("Config", func() {
 					Port().
 					Return(443).
 					AnyTimes()
+				myMockConfig.EXPECT().
+					TLSEnabled().
+					Return(false).
+					AnyTimes()
 				myMockConfig.EXPECT().
 					ReverseProxy().
 					Return(false).
@@ -114,6 +122,10 @@ var _ = Describe("Config", func() {
 					Port().
 					Return(80).
 					AnyTimes()
+				myMockConfig.EXPECT().
+					TLSEnabled().
+					Return(false).
+					AnyTimes()
 				myMockConfig.EXPECT().
 					ReverseProxy().
 					Return(false).
@@ -139,6 +151,10 @@ var _ = Describe("Config", func() {
 					Port().
 					Return(8080).
 					AnyTimes()
+				myMockConfig.EXPECT().
+					TLSEnabled().
+					Return(false).
+					AnyTimes()
 				myMockConfig.EXPECT().
 					ReverseProxy().
 					Return(false).
@@ -164,6 +180,10 @@ var _ = Describe("Config", func() {
 					Port().
 					Return(0).
 					AnyTimes()
+				myMockConfig.EXPECT().
+					TLSEnabled().
+					Return(false).
+					AnyTimes()
 				myMockConfig.EXPECT().
 					ReverseProxy().
 					Return(true).
@@ -172,6 +192,35 @@ var _ = Describe("Config", func() {
 
 				Expect(testRootShop).To(Equal("http://tinshop.example.com"))
 			})
+			It("Should use https when tls is enabled", func() {
+				var testRootShop string
+				myMockConfig.EXPECT().
+					Protocol().
+					Return("http").
+					AnyTimes()
+				myMockConfig.EXPECT().
+					SetRootShop(gomock.Any()).
+					Return().
+					Do(func(rootShop string) {
+						testRootShop = rootShop
+					}).
+					AnyTimes()
+				myMockConfig.EXPECT().
+					Port().
+					Return(443).
+					AnyTimes()
+				myMockConfig.EXPECT().
+					TLSEnabled().
+					Return(true).
+					AnyTimes()
+				myMockConfig.EXPECT().
+					ReverseProxy().
+					Return(false).
+					AnyTimes()
+				config.ComputeDefaultValues(myMockConfig)
+
+				Expect(testRootShop).To(Equal("https://tinshop.example.com"))
+			})
 		})
 	})
 	Context("Security for Blacklist/Whitelist tests", func() {
@@ -274,6 +323,38 @@ var _ = Describe("Config", func() {
 				Expect(myConfig.IsBannedTheme("myTheme")).To(BeTrue())
 			})
 		})
+		Describe("IsValidUauth", func() {
+			BeforeEach(func() {
+				myConfig.Security.Uauth = []repository.Uauth{
+					{URL: "https://Shop.example.com/", Value: "first"},
+					{URL: "shop.example.com/switch", Value: "second"},
+				}
+			})
+			It("should accept anything if empty config", func() {
+				myConfig.Security.Uauth = nil
+				Expect(myConfig.IsValidUauth("shop.example.com", "anything")).To(BeTrue())
+				Expect(myConfig.IsValidHostUauth("shop.example.com", "anything")).To(BeTrue())
+			})
+			It("should accept the value of the shop url", func() {
+				Expect(myConfig.IsValidUauth("shop.example.com", "first")).To(BeTrue())
+				Expect(myConfig.IsValidUauth("shop.example.com/switch/", "second")).To(BeTrue())
+			})
+			It("should refuse the value of another shop url", func() {
+				Expect(myConfig.IsValidUauth("shop.example.com", "second")).To(BeFalse())
+				Expect(myConfig.IsValidUauth("other.example.com", "first")).To(BeFalse())
+				Expect(myConfig.IsValidUauth("shop.example.com", "")).To(BeFalse())
+			})
+			It("should accept the values of the host on downloads", func() {
+				Expect(myConfig.IsValidHostUauth("shop.example.com", "first")).To(BeTrue())
+				Expect(myConfig.IsValidHostUauth("shop.example.com", "second")).To(BeTrue())
+				Expect(myConfig.IsValidHostUauth("other.example.com", "first")).To(BeFalse())
+				Expect(myConfig.IsValidHostUauth("shop.example.com", "forged")).To(BeFalse())
+			})
+			It("should tell the shop urls with values", func() {
+				Expect(myConfig.HasUauth("shop.example.com/switch/")).To(BeTrue())
+				Expect(myConfig.HasUauth("shop.example.com/fr")).To(BeFalse())
+			})
+		})
 	})
 	Describe("Protocol", func() {
 		var myConfig config.Configuration
@@ -339,6 +420,27 @@ var _ = Describe("Config", func() {
 			Expect(myConfig.Port()).To(Equal(12345))
 		})
 	})
+	Describe("DataPath", func() {
+		var myConfig config.Configuration
+
+		BeforeEach(func() {
+			myConfig = config.Configuration{DataDirectory: "/var/lib/tinshop"}
+		})
+
+		It("Test with a relative path", func() {
+			Expect(myConfig.DataPath("users.db")).To(Equal("/var/lib/tinshop/users.db"))
+		})
+		It("Test with an absolute path", func() {
+			Expect(myConfig.DataPath("/srv/users.db")).To(Equal("/srv/users.db"))
+		})
+		It("Test the state files of the config", func() {
+			myConfig.Keys = "prod.keys"
+			Expect(myConfig.ProdKeys()).To(Equal("/var/lib/tinshop/prod.keys"))
+			Expect(myConfig.StatsPath()).To(Equal("/var/lib/tinshop/stats.db"))
+			myConfig.Stats.Path = "/srv/stats.db"
+			Expect(myConfig.StatsPath()).To(Equal("/srv/stats.db"))
+		})
+	})
 	Describe("ReverseProxy", func() {
 		var myConfig config.Configuration
 
@@ -446,4 +548,29 @@ var _ = Describe("Config", func() {
 			Expect(myConfig.BannedTheme()[0]).To(Equal("Banned"))
 		})
 	})
+	Describe("Catalog", func() {
+		var myConfig config.Configuration
+
+		BeforeEach(func() {
+			myConfig = config.Configuration{}
+		})
+
+		It("Test with empty object", func() {
+			Expect(myConfig.CatalogEnabled()).To(BeFalse())
+		})
+		It("Test with a value", func() {
+			myConfig.Catalog.Enabled = true
+			myConfig.Catalog.Path = "games-list/"
+			Expect(myConfig.CatalogEnabled()).To(BeTrue())
+			Expect(myConfig.CatalogPath()).To(Equal("/games-list"))
+		})
+		It("Test with a path used by the shop", func() {
+			myConfig.Catalog.Enabled = true
+			for _, path := range []string{"/", "/api/catalog", "/games", "/admin/", "/US"} {
+				myConfig.Catalog.Path = path
+				Expect(myConfig.CatalogPath()).To(BeEmpty(), path)
+				Expect(myConfig.CatalogEnabled()).To(BeFalse(), path)
+			}
+		})
+	})
 })
@@ -0,0 +1,272 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	titleIDFormat = regexp.MustCompile(`^[0-9A-Fa-f]{16}$`) //nolint:gochecknoglobals
+	quotedField   = regexp.MustCompile(`'([^']+)'`)         //nolint:gochecknoglobals
+)
+
+// ValidationError is returned when the configuration has errors, it is not applied
+type ValidationError struct {
+	Problems []repository.ConfigProblem
+}
+
+func (e *ValidationError) Error() string {
+	errors := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		if problem.Severity == repository.ConfigError {
+			errors = append(errors, formatProblem(problem))
+		}
+	}
+	return "invalid configuration: " + strings.Join(errors, "; ")
+}
+
+// HasErrors tells if one of the problems prevents using the configuration
+func HasErrors(problems []repository.ConfigProblem) bool {
+	for _, problem := range problems {
+		if problem.Severity == repository.ConfigError {
+			return true
+		}
+	}
+	return false
+}
+
+// formatProblem returns the problem on a single line
+func formatProblem(problem repository.ConfigProblem) string {
+	if problem.Field == "" {
+		return problem.Message
+	}
+	return problem.Field + ": " + problem.Message
+}
+
+// problems collects the problems found while validating
+type problems []repository.ConfigProblem
+
+func (p *problems) error(field string, format string, args ...interface{}) {
+	*p = append(*p, repository.ConfigProblem{Field: field, Severity: repository.ConfigError, Message: fmt.Sprintf(format, args...)})
+}
+
+func (p *problems) warning(field string, format string, args ...interface{}) {
+	*p = append(*p, repository.ConfigProblem{Field: field, Severity: repository.ConfigWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate returns the errors and warnings of the values of the configuration,
+// it can be used as long as there is no error
+func Validate(cfg *Configuration) []repository.ConfigProblem {
+	var found problems
+
+	validateShop(cfg, &found)
+	validateSources(cfg, &found)
+	validateSecurity(cfg, &found)
+
+	if cfg.Audit.MaxSize < 0 {
+		found.error("audit.maxSize", "must be positive, got %d", cfg.Audit.MaxSize)
+	}
+	if cfg.Audit.MaxBackups < 0 {
+		found.error("audit.maxBackups", "must be positive, got %d", cfg.Audit.MaxBackups)
+	}
+	if cfg.Stats.Retention.HourlyDays < 0 {
+		found.error("stats.retention.hourlyDays", "must be positive, got %d", cfg.Stats.Retention.HourlyDays)
+	}
+	if cfg.Stats.Retention.DailyDays < 0 {
+		found.error("stats.retention.dailyDays", "must be positive or 0 to keep forever, got %d", cfg.Stats.Retention.DailyDays)
+	}
+	if cfg.Catalog.Enabled && cfg.CatalogPath() == "" {
+		found.warning("catalog.path", "'%s' is used by the shop, the catalog is disabled", cfg.Catalog.Path)
+	}
+	for i, webhook := range cfg.AllWebhooks {
+		validateURL(fmt.Sprintf("webhooks[%d].url", i), webhook.URL, &found)
+	}
+	for id := range cfg.CustomTitleDB {
+		if !titleIDFormat.MatchString(id) {
+			found.warning("customTitledb."+id, "is not a title id of 16 hexadecimal characters")
+		}
+	}
+
+	return found
+}
+
+func validateShop(cfg *Configuration, found *problems) {
+	switch cfg.ShopProtocol {
+	case "", "http", "https":
+	default:
+		found.error("protocol", "must be http or https, got '%s'", cfg.ShopProtocol)
+	}
+	if strings.Contains(cfg.ShopHost, "/") {
+		found.error("host", "must be a host name or an ip without protocol nor path, got '%s'", cfg.ShopHost)
+	}
+	validatePort("port", cfg.ShopPort, "0 for 3000", found)
+	if cfg.DataDirectory != "" {
+		if info, err := os.Stat(cfg.DataDirectory); err == nil && !info.IsDir() {
+			found.error("dataDir", "'%s' is not a directory", cfg.DataDirectory)
+		}
+	}
+
+	if cfg.TLS.Enabled {
+		files := []struct{ field, file string }{{"tls.cert", cfg.TLS.Cert}, {"tls.key", cfg.TLS.Key}}
+		for _, tlsFile := range files {
+			field, file := tlsFile.field, tlsFile.file
+			if file == "" {
+				found.error(field, "is required when tls is enabled")
+			} else if _, err := os.Stat(file); err != nil {
+				found.error(field, "cannot be read: %s", err)
+			}
+		}
+		validatePort("tls.redirectHttpPort", cfg.TLS.RedirectHTTPPort, "0 to disable the redirect", found)
+	}
+}
+
+func validatePort(field string, port int, unset string, found *problems) {
+	if port < 0 || port > 65535 {
+		found.error(field, "must be between 1 and 65535 (%s), got %d", unset, port)
+	}
+}
+
+func validateSources(cfg *Configuration, found *problems) {
+	for i, directory := range cfg.AllSources.Directories {
+		field := fmt.Sprintf("sources.directories[%d]", i)
+		info, err := os.Stat(directory)
+		if os.IsNotExist(err) {
+			found.warning(field, "'%s' does not exist, no game will be served from it", directory)
+		} else if err != nil {
+			found.warning(field, "'%s' cannot be read: %s", directory, err)
+		} else if !info.IsDir() {
+			found.error(field, "'%s' is not a directory", directory)
+		}
+	}
+
+	for i, share := range cfg.AllSources.Nfs {
+		host, path, ok := strings.Cut(share, ":")
+		if !ok || host == "" || strings.Contains(path, ":") || !strings.HasPrefix(path, "/") {
+			found.error(fmt.Sprintf("sources.nfs[%d]", i), "must be host:/path, got '%s'", share)
+		}
+	}
+}
+
+func validateSecurity(cfg *Configuration, found *problems) {
+	for i, credentials := range cfg.Security.Httpauth {
+		field := fmt.Sprintf("security.httpauth[%d]", i)
+		user, hash, ok := strings.Cut(credentials, ":")
+		switch {
+		case !ok:
+			found.error(field, "must be user:bcrypt-hash, the ':' is missing")
+		case user == "":
+			found.error(field, "the user name is empty")
+		default:
+			if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+				found.error(field, "the password of '%s' is not a bcrypt hash, cleartext passwords do not work", user)
+			}
+		}
+	}
+
+	shopURLs := make(map[string]bool)
+	for i, accepted := range cfg.Security.Uauth {
+		field := fmt.Sprintf("security.uauth[%d]", i)
+		shopURL := NormalizeShopURL(accepted.URL)
+		switch {
+		case shopURL == "":
+			found.error(field, "the url of the shop is empty")
+		case accepted.Value == "":
+			found.error(field, "the value for '%s' is empty", shopURL)
+		case shopURLs[shopURL]:
+			found.error(field, "'%s' is already listed", shopURL)
+		}
+		shopURLs[shopURL] = true
+	}
+
+	if cfg.Security.ForwardAuth != "" {
+		validateURL("security.forwardAuth", cfg.Security.ForwardAuth, found)
+	}
+
+	for i, network := range cfg.Security.TrustedProxies {
+		validateNetwork(fmt.Sprintf("security.trustedProxies[%d]", i), network, found)
+	}
+	policies := []struct {
+		name   string
+		policy repository.IPPolicy
+	}{
+		{"security.ipFilter.index", cfg.Security.IPFilter.Index},
+		{"security.ipFilter.download", cfg.Security.IPFilter.Download},
+	}
+	database := cfg.Security.IPFilter.GeoIPDatabase
+	var databaseErr error
+	if database != "" {
+		_, databaseErr = os.Stat(database)
+	}
+	countries := false
+	for _, filter := range policies {
+		name, policy := filter.name, filter.policy
+		for i, network := range policy.Allow {
+			validateNetwork(name+".allow["+strconv.Itoa(i)+"]", network, found)
+		}
+		for i, network := range policy.Deny {
+			validateNetwork(name+".deny["+strconv.Itoa(i)+"]", network, found)
+		}
+		if len(policy.AllowCountries)+len(policy.DenyCountries) > 0 {
+			countries = true
+			if database == "" {
+				found.error(name, "countries need security.ipFilter.geoipDatabase to be checked")
+			}
+		}
+	}
+	if databaseErr != nil {
+		if countries {
+			found.error("security.ipFilter.geoipDatabase", "cannot be read, the countries cannot be checked: %s", databaseErr)
+		} else {
+			found.warning("security.ipFilter.geoipDatabase", "cannot be read: %s", databaseErr)
+		}
+	}
+}
+
+// validateNetwork checks the value is an ip or a network in CIDR notation
+func validateNetwork(field string, network string, found *problems) {
+	if _, err := utils.ParseNetwork(network); err != nil {
+		found.error(field, "%s", err)
+	}
+}
+
+func validateURL(field string, value string, found *problems) {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		found.error(field, "must be an http or https url, got '%s'", value)
+	}
+}
+
+// decodeProblems returns a problem for each value of the file which could not be decoded
+func decodeProblems(err error) []repository.ConfigProblem {
+	var found problems
+	for _, line := range strings.Split(err.Error(), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "* ") {
+			continue
+		}
+		field := ""
+		if match := quotedField.FindStringSubmatch(line); match != nil {
+			field = match[1]
+		}
+		found.error(field, "%s", strings.TrimPrefix(line, "* "))
+	}
+	if len(found) == 0 {
+		found.error("", "%s", err)
+	}
+	return found
+}
+
+// readProblems returns the problem of a file which could not be read or parsed
+func readProblems(err error) []repository.ConfigProblem {
+	var found problems
+	found.error("", "cannot read the configuration file: %s", err)
+	return found
+}
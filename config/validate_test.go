@@ -0,0 +1,161 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/ajmandourah/tinshop-ng/config"
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+var _ = Describe("Validate", func() {
+	var (
+		myConfig  *config.Configuration
+		directory string
+	)
+
+	BeforeEach(func() {
+		directory = GinkgoT().TempDir()
+		myConfig = &config.Configuration{}
+		myConfig.AllSources.Directories = []string{directory}
+	})
+
+	fields := func(problems []repository.ConfigProblem, severity string) []string {
+		result := make([]string, 0)
+		for _, problem := range problems {
+			if problem.Severity == severity {
+				result = append(result, problem.Field)
+			}
+		}
+		return result
+	}
+
+	It("Accepts a valid configuration", func() {
+		myConfig.ShopPort = 3000
+		myConfig.AllSources.Nfs = []string{"192.168.1.100:/path/to/games"}
+		myConfig.Security.Httpauth = []string{"admin:$2a$10$7EqJtq98hPqEX7fNZaFWoOhi5BWX4Z3TfHgY7g0cC3k1gJQw1y9jS"}
+		myConfig.Security.TrustedProxies = []string{"127.0.0.1", "10.0.0.0/8"}
+		myConfig.AllWebhooks = []repository.Webhook{{URL: "https://hooks.example.com/tinshop"}}
+
+		problems := config.Validate(myConfig)
+
+		Expect(problems).To(BeEmpty())
+		Expect(config.HasErrors(problems)).To(BeFalse())
+	})
+	It("Reports the invalid values by field", func() {
+		myConfig.ShopProtocol = "ftp"
+		myConfig.ShopPort = 70000
+		myConfig.AllSources.Nfs = []string{"192.168.1.100", "192.168.1.100:games"}
+		myConfig.Security.Httpauth = []string{"admin", "user:cleartext"}
+		myConfig.Security.TrustedProxies = []string{"10.0.0.0/33"}
+		myConfig.Security.IPFilter.Download.Deny = []string{"not-an-ip"}
+		myConfig.AllWebhooks = []repository.Webhook{{URL: "hooks.example.com"}}
+
+		problems := config.Validate(myConfig)
+
+		Expect(config.HasErrors(problems)).To(BeTrue())
+		Expect(fields(problems, repository.ConfigError)).To(ConsistOf(
+			"protocol",
+			"port",
+			"sources.nfs[0]",
+			"sources.nfs[1]",
+			"security.httpauth[0]",
+			"security.httpauth[1]",
+			"security.trustedProxies[0]",
+			"security.ipFilter.download.deny[0]",
+			"webhooks[0].url",
+		))
+	})
+	It("Warns about a missing directory", func() {
+		myConfig.AllSources.Directories = []string{filepath.Join(directory, "missing")}
+
+		problems := config.Validate(myConfig)
+
+		Expect(config.HasErrors(problems)).To(BeFalse())
+		Expect(fields(problems, repository.ConfigWarning)).To(Equal([]string{"sources.directories[0]"}))
+	})
+	It("Refuses a file as directory", func() {
+		file := filepath.Join(directory, "game.nsp")
+		Expect(os.WriteFile(file, []byte("game"), 0o600)).To(Succeed())
+		myConfig.AllSources.Directories = []string{file}
+
+		Expect(fields(config.Validate(myConfig), repository.ConfigError)).To(Equal([]string{"sources.directories[0]"}))
+	})
+	It("Refuses a file as data directory", func() {
+		file := filepath.Join(directory, "data")
+		Expect(os.WriteFile(file, []byte("data"), 0o600)).To(Succeed())
+		myConfig.DataDirectory = file
+
+		Expect(fields(config.Validate(myConfig), repository.ConfigError)).To(Equal([]string{"dataDir"}))
+	})
+	It("Requires the geoip database to check countries", func() {
+		myConfig.Security.IPFilter.Download.AllowCountries = []string{"FR"}
+
+		Expect(fields(config.Validate(myConfig), repository.ConfigError)).To(Equal([]string{"security.ipFilter.download"}))
+
+		myConfig.Security.IPFilter.GeoIPDatabase = filepath.Join(directory, "missing.csv")
+		Expect(fields(config.Validate(myConfig), repository.ConfigError)).To(Equal([]string{"security.ipFilter.geoipDatabase"}))
+	})
+	It("Requires one value for each uauth shop url", func() {
+		myConfig.Security.Uauth = []repository.Uauth{
+			{URL: "shop.example.com", Value: "first"},
+			{URL: "https://shop.example.com/", Value: "second"},
+			{URL: "shop.example.com/switch"},
+		}
+
+		Expect(fields(config.Validate(myConfig), repository.ConfigError)).To(Equal([]string{"security.uauth[1]", "security.uauth[2]"}))
+	})
+	It("Requires the certificate when tls is enabled", func() {
+		myConfig.TLS.Enabled = true
+		myConfig.TLS.Key = filepath.Join(directory, "missing.pem")
+
+		Expect(fields(config.Validate(myConfig), repository.ConfigError)).To(Equal([]string{"tls.cert", "tls.key"}))
+	})
+
+	Describe("Check", func() {
+		write := func(content string) string {
+			file := filepath.Join(directory, "config.yaml")
+			Expect(os.WriteFile(file, []byte(content), 0o600)).To(Succeed())
+			return file
+		}
+
+		It("Checks a valid file", func() {
+			file := write("port: 3000\nsources:\n  directories:\n    - " + directory + "\n")
+
+			used, problems := config.Check(file)
+
+			Expect(used).To(Equal(file))
+			Expect(problems).To(BeEmpty())
+		})
+		It("Reports a malformed file", func() {
+			_, problems := config.Check(write("sources:\n  directories: [\n"))
+
+			Expect(problems).To(HaveLen(1))
+			Expect(problems[0].Severity).To(Equal(repository.ConfigError))
+			Expect(problems[0].Message).To(ContainSubstring("cannot read the configuration file"))
+		})
+		It("Reports a value of the wrong type", func() {
+			_, problems := config.Check(write("port: abc\nsources:\n  directories:\n    - " + directory + "\n"))
+
+			Expect(fields(problems, repository.ConfigError)).To(Equal([]string{"port"}))
+		})
+		It("Reports a missing file", func() {
+			_, problems := config.Check(filepath.Join(directory, "missing.yaml"))
+
+			Expect(config.HasErrors(problems)).To(BeTrue())
+		})
+		It("Searches the file in the data directory given on the command line", func() {
+			file := write("port: 3000\nsources:\n  directories:\n    - " + directory + "\n")
+			config.SetDataDir(directory)
+			DeferCleanup(config.SetDataDir, "")
+
+			used, problems := config.Check("")
+
+			Expect(used).To(Equal(file))
+			Expect(problems).To(BeEmpty())
+		})
+	})
+})
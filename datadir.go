@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	collection "github.com/ajmandourah/tinshop-ng/gamescollection"
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+// Names of the state files in the data directory
+const (
+	usersFile   = "users.db"
+	tokensFile  = "tokens.db"
+	devicesFile = "devices.db"
+	auditFile   = "audit.log"
+	// bootstrapFile holds the secret of the bootstrap token, it is never printed in the logs
+	bootstrapFile = "bootstrap-token.txt"
+)
+
+// legacyDirs are the directories where the previous versions left the state files
+var legacyDirs = []string{".", "/data"} //nolint:gochecknoglobals
+
+// prepareDataDir creates the data directory and moves there the state files
+// of the previous versions, a file already in the data directory is never replaced
+func prepareDataDir(cfg repository.Config) error {
+	dataDir, err := filepath.Abs(cfg.DataDir())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dataDir, 0o750); err != nil {
+		return fmt.Errorf("cannot create the data directory: %w", err)
+	}
+
+	files := []string{
+		cfg.DataPath(collection.TitleDBFile),
+		cfg.DataPath(usersFile),
+		cfg.DataPath(tokensFile),
+		cfg.DataPath(devicesFile),
+		cfg.DataPath(auditFile),
+		cfg.StatsPath(),
+		cfg.ProdKeys(),
+	}
+	for index := 1; index <= cfg.AuditMaxBackups(); index++ {
+		files = append(files, cfg.DataPath(auditFile+"."+strconv.Itoa(index)))
+	}
+
+	for _, file := range files {
+		if err := migrateFile(dataDir, file); err != nil {
+			log.Printf("[DataDir] Unable to move %s to the data directory: %s\n", file, err)
+		}
+	}
+	return nil
+}
+
+// migrateFile moves the file from the legacy directories when it is missing from the data directory
+func migrateFile(dataDir string, file string) error {
+	target, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	name, errRel := filepath.Rel(dataDir, target)
+	if errRel != nil || strings.HasPrefix(name, "..") {
+		// Configured outside of the data directory, it is used where it is
+		return nil
+	}
+	if _, errStat := os.Stat(target); !os.IsNotExist(errStat) {
+		return nil
+	}
+
+	for _, dir := range legacyDirs {
+		source, err := filepath.Abs(filepath.Join(dir, name))
+		if err != nil || source == target {
+			continue
+		}
+		if info, err := os.Stat(source); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o750); err != nil {
+			return err
+		}
+		if err := moveFile(source, target); err != nil {
+			return err
+		}
+		log.Printf("[DataDir] Moved %s to %s\n", source, target)
+		return nil
+	}
+	return nil
+}
+
+// moveFile renames the file, or copies it when the data directory is on another device
+func moveFile(source string, target string) error {
+	if err := os.Rename(source, target); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(target)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(target)
+		return err
+	}
+	return os.Remove(source)
+}
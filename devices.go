@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ajmandourah/tinshop-ng/devices"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/utils"
+	"github.com/gorilla/mux"
+)
+
+// DevicesHandler handles listing the device registry
+func (s *TinShop) DevicesHandler(w http.ResponseWriter, _ *http.Request) {
+	allDevices, err := s.Shop.Devices.List()
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.Shop.API.Devices(w, allDevices)
+}
+
+// DeviceHandler handles a single device, a device not seen yet can be named or blocked in advance
+func (s *TinShop) DeviceHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	device, err := s.Shop.Devices.Get(vars["uid"])
+	if err != nil && (r.Method != http.MethodPut || !errors.Is(err, devices.ErrUnknownDevice)) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.Shop.API.Device(w, device)
+	case http.MethodPut:
+		var req repository.DeviceRequest
+		if errDecode := json.NewDecoder(r.Body).Decode(&req); errDecode != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		device.UID = vars["uid"]
+		if req.Name != nil {
+			device.Name = *req.Name
+		}
+		if req.Blocked != nil {
+			device.Blocked = *req.Blocked
+		}
+		if errSave := s.Shop.Devices.Save(device); errSave != nil {
+			log.Println(errSave)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		device, _ = s.Shop.Devices.Get(device.UID)
+		log.Println("[API] Switch updated", device.UID, "name:", device.Name, "blocked:", device.Blocked)
+		s.Shop.API.Device(w, device)
+	case http.MethodDelete:
+		if errDelete := s.Shop.Devices.Delete(device.UID); errDelete != nil {
+			log.Println(errDelete)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		log.Println("[API] Switch removed from registry", device.UID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// isBlacklisted tells if the switch is blacklisted in the config or blocked from the registry
+func (s *TinShop) isBlacklisted(uid string) bool {
+	if s.Shop.Config.IsBlacklisted(uid) {
+		return true
+	}
+	return s.Shop.Devices != nil && s.Shop.Devices.IsBlocked(uid)
+}
+
+// deviceName returns the friendly name of the switch for logs
+func (s *TinShop) deviceName(uid string) string {
+	if s.Shop.Devices == nil {
+		return uid
+	}
+	return s.Shop.Devices.DisplayName(uid)
+}
+
+// recordDevice adds the switch of the request to the registry
+func (s *TinShop) recordDevice(r *http.Request) {
+	if s.Shop.Devices == nil {
+		return
+	}
+	s.Shop.Devices.Seen(repository.Switch{
+		IP:       utils.GetIPFromRequest(r),
+		UID:      r.Header.Get("Uid"),
+		Theme:    r.Header.Get("Theme"),
+		Version:  r.Header.Get("Version"),
+		Language: r.Header.Get("Language"),
+	})
+}
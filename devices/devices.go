@@ -0,0 +1,224 @@
+// @title tinshop Devices
+
+// @BasePath /devices/
+
+// Package devices provides the registry of every switch seen by the shop
+package devices
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ErrUnknownDevice is returned when the device is not in the registry
+var ErrUnknownDevice = errors.New("unknown device")
+
+const (
+	devicesBucket = "devices"
+	// seenInterval is the minimum time between two writes of the last seen time of a device
+	seenInterval = time.Minute
+)
+
+type registry struct {
+	path string
+	db   *bolt.DB
+
+	mutex   sync.RWMutex
+	devices map[string]repository.Device
+}
+
+// New create a new device registry
+func New(path string) repository.Devices {
+	return &registry{
+		path:    path,
+		devices: make(map[string]repository.Device),
+	}
+}
+
+// Load opens the devices database and reads all devices in memory
+func (s *registry) Load() {
+	db, err := bolt.Open(s.path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		log.Println("[Devices] Unable to open devices database", err)
+		return
+	}
+
+	devices := make(map[string]repository.Device)
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, errBucket := tx.CreateBucketIfNotExists([]byte(devicesBucket))
+		if errBucket != nil {
+			return fmt.Errorf("create bucket: %s", errBucket)
+		}
+		return b.ForEach(func(_, v []byte) error {
+			var device repository.Device
+			if errDevice := json.Unmarshal(v, &device); errDevice != nil {
+				return errDevice
+			}
+			devices[device.UID] = device
+			return nil
+		})
+	})
+	if err != nil {
+		log.Println("[Devices] Unable to read devices database", err)
+		_ = db.Close()
+		return
+	}
+
+	s.mutex.Lock()
+	s.db = db
+	s.devices = devices
+	s.mutex.Unlock()
+}
+
+// Close closes the devices database
+func (s *registry) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Seen records a request of the switch, the database is only written when something changed
+func (s *registry) Seen(console repository.Switch) {
+	if console.UID == "" {
+		return
+	}
+	now := time.Now().UTC()
+
+	s.mutex.Lock()
+	device, known := s.devices[console.UID]
+	previous := device
+	if !known {
+		device = repository.Device{UID: console.UID, FirstSeen: now}
+	}
+	device.IP = console.IP
+	if console.Theme != "" {
+		device.Theme = console.Theme
+	}
+	if console.Version != "" {
+		device.Version = console.Version
+	}
+	if console.Language != "" {
+		device.Language = console.Language
+	}
+	changed := !known || device.IP != previous.IP || device.Theme != previous.Theme ||
+		device.Version != previous.Version || device.Language != previous.Language
+	if !changed && now.Sub(device.LastSeen) < seenInterval {
+		s.mutex.Unlock()
+		return
+	}
+	device.LastSeen = now
+	s.devices[console.UID] = device
+	s.mutex.Unlock()
+
+	if !known {
+		log.Println("[Devices] New switch seen", console.UID, "from", console.IP)
+	}
+	if err := s.put(device); err != nil {
+		log.Println("[Devices] Unable to save switch", console.UID, err)
+	}
+}
+
+func (s *registry) put(device repository.Device) error {
+	if s.db == nil {
+		return nil
+	}
+	buf, err := json.Marshal(device)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(devicesBucket)).Put([]byte(device.UID), buf)
+	})
+}
+
+// List returns all devices, the most recently seen first
+func (s *registry) List() ([]repository.Device, error) {
+	s.mutex.RLock()
+	devices := make([]repository.Device, 0, len(s.devices))
+	for _, device := range s.devices {
+		devices = append(devices, device)
+	}
+	s.mutex.RUnlock()
+
+	sort.Slice(devices, func(i, j int) bool {
+		if !devices[i].LastSeen.Equal(devices[j].LastSeen) {
+			return devices[i].LastSeen.After(devices[j].LastSeen)
+		}
+		return devices[i].UID < devices[j].UID
+	})
+	return devices, nil
+}
+
+// Get returns a single device
+func (s *registry) Get(uid string) (repository.Device, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	device, ok := s.devices[uid]
+	if !ok {
+		return repository.Device{}, ErrUnknownDevice
+	}
+	return device, nil
+}
+
+// Save sets the name and the blocked flag of a device, it can be saved before being seen
+func (s *registry) Save(device repository.Device) error {
+	if device.UID == "" {
+		return errors.New("missing device uid")
+	}
+
+	s.mutex.Lock()
+	existing, known := s.devices[device.UID]
+	if !known {
+		existing = repository.Device{UID: device.UID}
+	}
+	existing.Name = device.Name
+	existing.Blocked = device.Blocked
+	s.devices[device.UID] = existing
+	s.mutex.Unlock()
+
+	return s.put(existing)
+}
+
+// Delete removes a device from the registry, it is added again on its next visit
+func (s *registry) Delete(uid string) error {
+	s.mutex.Lock()
+	if _, ok := s.devices[uid]; !ok {
+		s.mutex.Unlock()
+		return ErrUnknownDevice
+	}
+	delete(s.devices, uid)
+	s.mutex.Unlock()
+
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(devicesBucket)).Delete([]byte(uid))
+	})
+}
+
+// DisplayName returns the friendly name of the device, or its uid when it has none
+func (s *registry) DisplayName(uid string) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if name := s.devices[uid].Name; name != "" {
+		return name
+	}
+	return uid
+}
+
+// IsBlocked tells if the device has been blocked from the registry
+func (s *registry) IsBlocked(uid string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.devices[uid].Blocked
+}
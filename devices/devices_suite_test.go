@@ -0,0 +1,13 @@
+package devices_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestDevices(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Devices Suite")
+}
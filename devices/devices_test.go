@@ -0,0 +1,87 @@
+package devices_test
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/ajmandourah/tinshop-ng/devices"
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+var _ = Describe("Devices", func() {
+	var (
+		path     string
+		registry repository.Devices
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "devices.db")
+		registry = devices.New(path)
+		registry.Load()
+	})
+	AfterEach(func() {
+		Expect(registry.Close()).To(Succeed())
+	})
+
+	It("Records the switch seen", func() {
+		registry.Seen(repository.Switch{UID: "UID1", IP: "10.0.0.1", Theme: "THEME", Version: "17.0", Language: "fr"})
+		registry.Seen(repository.Switch{UID: "UID1", IP: "10.0.0.2"})
+		registry.Seen(repository.Switch{IP: "10.0.0.3"})
+
+		allDevices, err := registry.List()
+		Expect(err).To(BeNil())
+		Expect(allDevices).To(HaveLen(1))
+		Expect(allDevices[0].UID).To(Equal("UID1"))
+		Expect(allDevices[0].IP).To(Equal("10.0.0.2"))
+		Expect(allDevices[0].Theme).To(Equal("THEME"))
+		Expect(allDevices[0].Version).To(Equal("17.0"))
+		Expect(allDevices[0].Language).To(Equal("fr"))
+		Expect(allDevices[0].FirstSeen).NotTo(BeZero())
+		Expect(allDevices[0].LastSeen).NotTo(BeZero())
+	})
+	It("Uses the friendly name", func() {
+		registry.Seen(repository.Switch{UID: "UID1", IP: "10.0.0.1"})
+		Expect(registry.DisplayName("UID1")).To(Equal("UID1"))
+
+		Expect(registry.Save(repository.Device{UID: "UID1", Name: "Living room"})).To(Succeed())
+		Expect(registry.DisplayName("UID1")).To(Equal("Living room"))
+		Expect(registry.DisplayName("UID2")).To(Equal("UID2"))
+
+		device, err := registry.Get("UID1")
+		Expect(err).To(BeNil())
+		Expect(device.IP).To(Equal("10.0.0.1"))
+	})
+	It("Blocks a device before it is seen", func() {
+		Expect(registry.Save(repository.Device{UID: "UID2", Blocked: true})).To(Succeed())
+		Expect(registry.IsBlocked("UID2")).To(BeTrue())
+		Expect(registry.IsBlocked("UID1")).To(BeFalse())
+
+		registry.Seen(repository.Switch{UID: "UID2", IP: "10.0.0.1"})
+		Expect(registry.IsBlocked("UID2")).To(BeTrue())
+	})
+	It("Refuses a device without uid", func() {
+		Expect(registry.Save(repository.Device{Name: "nobody"})).NotTo(Succeed())
+	})
+	It("Deletes a device", func() {
+		registry.Seen(repository.Switch{UID: "UID1"})
+		Expect(registry.Delete("UID1")).To(Succeed())
+		_, err := registry.Get("UID1")
+		Expect(err).To(MatchError(devices.ErrUnknownDevice))
+		Expect(registry.Delete("UID1")).To(MatchError(devices.ErrUnknownDevice))
+	})
+	It("Persists the registry", func() {
+		registry.Seen(repository.Switch{UID: "UID1", IP: "10.0.0.1"})
+		Expect(registry.Save(repository.Device{UID: "UID1", Name: "Kid's Lite", Blocked: true})).To(Succeed())
+		Expect(registry.Close()).To(Succeed())
+
+		registry = devices.New(path)
+		registry.Load()
+		device, err := registry.Get("UID1")
+		Expect(err).To(BeNil())
+		Expect(device.Name).To(Equal("Kid's Lite"))
+		Expect(device.Blocked).To(BeTrue())
+		Expect(device.IP).To(Equal("10.0.0.1"))
+	})
+})
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/events"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/utils"
+)
+
+// keepAliveInterval keeps the stream open through proxies closing idle connections
+const keepAliveInterval = 30 * time.Second
+
+// EventsHandler streams the live events as Server-Sent Events
+func (s *TinShop) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var types map[repository.EventType]bool
+	if filter := r.URL.Query().Get("types"); filter != "" {
+		types = make(map[repository.EventType]bool)
+		for _, eventType := range strings.Split(filter, ",") {
+			types[repository.EventType(strings.TrimSpace(eventType))] = true
+		}
+	}
+
+	stream, unsubscribe := events.Default.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprint(w, ": connected\n\n")
+
+	// Resume after the last event received by a reconnecting client
+	var lastID uint64
+	if id, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		for _, event := range events.Default.Since(id) {
+			lastID = event.ID
+			if types == nil || types[event.Type] {
+				writeEvent(w, event)
+			}
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-stream:
+			if !ok {
+				return
+			}
+			if event.ID <= lastID || (types != nil && !types[event.Type]) {
+				continue
+			}
+			lastID = event.ID
+			writeEvent(w, event)
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event repository.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Println("[Events] Unable to encode event", err)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
+
+// newDownloadEvent returns the download event of a request
+func (s *TinShop) newDownloadEvent(titleID string, r *http.Request) repository.DownloadEvent {
+	uid := r.Header.Get("Uid")
+	downloadEvent := repository.DownloadEvent{
+		TitleID: titleID,
+		UID:     uid,
+		IP:      utils.GetIPFromRequest(r),
+		Range:   r.Header.Get("Range"),
+	}
+	if name := s.deviceName(uid); name != uid {
+		downloadEvent.Device = name
+	}
+	return downloadEvent
+}
+
+// publishDownload publishes a download event, HEAD requests do not download anything
+func publishDownload(r *http.Request, eventType repository.EventType, downloadEvent repository.DownloadEvent) {
+	if r.Method == http.MethodHead {
+		return
+	}
+	events.Publish(eventType, downloadEvent)
+}
+
+// downloadEventType tells if all the bytes requested have been sent
+func downloadEventType(counter *utils.ResponseCounter) repository.EventType {
+	if counter.Status != http.StatusOK && counter.Status != http.StatusPartialContent {
+		return repository.EventDownloadAborted
+	}
+	length, err := strconv.ParseInt(counter.Header().Get("Content-Length"), 10, 64)
+	if err != nil || counter.Bytes < length {
+		return repository.EventDownloadAborted
+	}
+	return repository.EventDownloadCompleted
+}
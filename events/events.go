@@ -0,0 +1,236 @@
+// @title tinshop Events
+
+// @BasePath /events/
+
+// Package events provides the live events of the shop
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+const (
+	// historySize is the number of events kept to resume a stream
+	historySize = 100
+	// bufferSize is the number of events a slow subscriber can be late
+	bufferSize = 64
+	// queueSize is the number of events a queue keeps before dropping the next ones
+	queueSize = 50000
+)
+
+// Default is the bus streamed on /api/events
+var Default = New() //nolint:gochecknoglobals
+
+// Bus sends the published events to all subscribers
+type Bus struct {
+	mutex       sync.Mutex
+	lastID      uint64
+	history     []repository.Event
+	subscribers map[chan repository.Event]struct{}
+	queues      map[*queue]struct{}
+	closed      bool
+}
+
+// queue keeps in memory the events its subscriber has not read yet
+type queue struct {
+	mutex    sync.Mutex
+	types    map[repository.EventType]bool
+	pending  []repository.Event
+	wake     chan struct{}
+	stream   chan repository.Event
+	closed   bool
+	dropping bool
+}
+
+// New returns an empty bus
+func New() *Bus {
+	return &Bus{
+		subscribers: make(map[chan repository.Event]struct{}),
+		queues:      make(map[*queue]struct{}),
+	}
+}
+
+// Publish sends a new event to all subscribers, a subscriber too slow to
+// follow misses the event instead of blocking the shop
+func (b *Bus) Publish(eventType repository.EventType, data interface{}) repository.Event {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.lastID++
+	event := repository.Event{
+		ID:   b.lastID,
+		Type: eventType,
+		Time: time.Now().UTC(),
+		Data: data,
+	}
+
+	b.history = append(b.history, event)
+	if len(b.history) > historySize {
+		b.history = b.history[len(b.history)-historySize:]
+	}
+
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+	for queue := range b.queues {
+		queue.push(event)
+	}
+	return event
+}
+
+// Subscribe returns the events published from now on, the channel is
+// closed by unsubscribe or when the bus is closed
+func (b *Bus) Subscribe() (<-chan repository.Event, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	subscriber := make(chan repository.Event, bufferSize)
+	if b.closed {
+		close(subscriber)
+		return subscriber, func() {}
+	}
+	b.subscribers[subscriber] = struct{}{}
+
+	var once sync.Once
+	return subscriber, func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			if _, ok := b.subscribers[subscriber]; ok {
+				delete(b.subscribers, subscriber)
+				close(subscriber)
+			}
+		})
+	}
+}
+
+// SubscribeQueue returns the events of the types published from now on, all types without any.
+// They wait in memory until read and are only dropped once queueSize events are waiting.
+// The pending events are still sent after unsubscribe or the bus is closed, then the channel is closed
+func (b *Bus) SubscribeQueue(types ...repository.EventType) (<-chan repository.Event, func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	subscriber := &queue{
+		wake:   make(chan struct{}, 1),
+		stream: make(chan repository.Event),
+		closed: b.closed,
+	}
+	if len(types) > 0 {
+		subscriber.types = make(map[repository.EventType]bool)
+		for _, eventType := range types {
+			subscriber.types[eventType] = true
+		}
+	}
+	go subscriber.forward()
+	if b.closed {
+		return subscriber.stream, func() {}
+	}
+	b.queues[subscriber] = struct{}{}
+
+	return subscriber.stream, func() {
+		b.mutex.Lock()
+		delete(b.queues, subscriber)
+		b.mutex.Unlock()
+		subscriber.close()
+	}
+}
+
+func (q *queue) push(event repository.Event) {
+	if q.types != nil && !q.types[event.Type] {
+		return
+	}
+	q.mutex.Lock()
+	if len(q.pending) >= queueSize {
+		// Logged once until the subscriber catches up
+		if !q.dropping {
+			log.Println("[Events] Subscriber too slow, dropping the events from", event.ID)
+		}
+		q.dropping = true
+		q.mutex.Unlock()
+		return
+	}
+	q.dropping = false
+	q.pending = append(q.pending, event)
+	q.mutex.Unlock()
+	q.signal()
+}
+
+func (q *queue) close() {
+	q.mutex.Lock()
+	q.closed = true
+	q.mutex.Unlock()
+	q.signal()
+}
+
+func (q *queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// forward sends the pending events in order until the queue is closed and empty
+func (q *queue) forward() {
+	defer close(q.stream)
+	for {
+		q.mutex.Lock()
+		if len(q.pending) == 0 {
+			closed := q.closed
+			q.mutex.Unlock()
+			if closed {
+				return
+			}
+			<-q.wake
+			continue
+		}
+		event := q.pending[0]
+		q.pending[0] = repository.Event{}
+		q.pending = q.pending[1:]
+		q.mutex.Unlock()
+
+		q.stream <- event
+	}
+}
+
+// Since returns the recent events published after the event id
+func (b *Bus) Since(id uint64) []repository.Event {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	events := make([]repository.Event, 0)
+	for _, event := range b.history {
+		if event.ID > id {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Close ends all subscriptions, used on shutdown
+func (b *Bus) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.closed = true
+	for subscriber := range b.subscribers {
+		delete(b.subscribers, subscriber)
+		close(subscriber)
+	}
+	for queue := range b.queues {
+		delete(b.queues, queue)
+		queue.close()
+	}
+}
+
+// Publish sends a new event on the default bus
+func Publish(eventType repository.EventType, data interface{}) {
+	Default.Publish(eventType, data)
+}
@@ -0,0 +1,105 @@
+package events_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/ajmandourah/tinshop-ng/events"
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+var _ = Describe("Events", func() {
+	var bus *events.Bus
+	BeforeEach(func() {
+		bus = events.New()
+	})
+	It("Numbers the events", func() {
+		first := bus.Publish(repository.EventTitleAdded, nil)
+		second := bus.Publish(repository.EventTitleRemoved, nil)
+
+		Expect(first.ID).To(Equal(uint64(1)))
+		Expect(second.ID).To(Equal(uint64(2)))
+		Expect(second.Time).NotTo(BeZero())
+	})
+	It("Sends the events to all subscribers", func() {
+		first, unsubscribeFirst := bus.Subscribe()
+		defer unsubscribeFirst()
+		second, unsubscribeSecond := bus.Subscribe()
+		defer unsubscribeSecond()
+
+		bus.Publish(repository.EventScanStarted, repository.ScanStatus{ID: 1})
+
+		Expect((<-first).Type).To(Equal(repository.EventScanStarted))
+		Expect((<-second).Data).To(Equal(repository.ScanStatus{ID: 1}))
+	})
+	It("Stops sending after unsubscribe", func() {
+		stream, unsubscribe := bus.Subscribe()
+		unsubscribe()
+		unsubscribe()
+
+		bus.Publish(repository.EventScanStarted, nil)
+
+		Eventually(stream).Should(BeClosed())
+	})
+	It("Does not block on a slow subscriber", func() {
+		_, unsubscribe := bus.Subscribe()
+		defer unsubscribe()
+
+		for i := 0; i < 1000; i++ {
+			bus.Publish(repository.EventTitleAdded, nil)
+		}
+		Expect(bus.Publish(repository.EventTitleAdded, nil).ID).To(Equal(uint64(1001)))
+	})
+	It("Queues every event for a slow subscriber", func() {
+		stream, unsubscribe := bus.SubscribeQueue()
+
+		for i := 0; i < 1000; i++ {
+			bus.Publish(repository.EventTitleAdded, nil)
+		}
+		unsubscribe()
+		bus.Publish(repository.EventTitleAdded, nil)
+
+		received := 0
+		for event := range stream {
+			received++
+			Expect(event.ID).To(Equal(uint64(received)))
+		}
+		Expect(received).To(Equal(1000))
+	})
+	It("Queues only the events of the types asked", func() {
+		stream, unsubscribe := bus.SubscribeQueue(repository.EventScanFinished)
+
+		bus.Publish(repository.EventTitleAdded, nil)
+		bus.Publish(repository.EventScanFinished, nil)
+		unsubscribe()
+
+		var received []repository.Event
+		for event := range stream {
+			received = append(received, event)
+		}
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].Type).To(Equal(repository.EventScanFinished))
+	})
+	It("Keeps the recent events", func() {
+		for i := 0; i < 150; i++ {
+			bus.Publish(repository.EventTitleAdded, nil)
+		}
+
+		Expect(bus.Since(0)).To(HaveLen(100))
+		Expect(bus.Since(0)[0].ID).To(Equal(uint64(51)))
+		Expect(bus.Since(148)).To(HaveLen(2))
+		Expect(bus.Since(150)).To(BeEmpty())
+	})
+	It("Ends all subscriptions on close", func() {
+		stream, unsubscribe := bus.Subscribe()
+		defer unsubscribe()
+
+		bus.Close()
+
+		Eventually(stream).Should(BeClosed())
+		late, _ := bus.Subscribe()
+		Eventually(late).Should(BeClosed())
+		lateQueue, _ := bus.SubscribeQueue()
+		Eventually(lateQueue).Should(BeClosed())
+	})
+})
@@ -0,0 +1,59 @@
+package main_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	main "github.com/ajmandourah/tinshop-ng"
+	"github.com/ajmandourah/tinshop-ng/events"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Events", func() {
+	var myShop *main.TinShop
+
+	BeforeEach(func() {
+		myShop = &main.TinShop{}
+	})
+
+	It("replays the events missed by a reconnecting client", func() {
+		last := events.Default.Publish(repository.EventScanStarted, repository.ScanStatus{ID: 1})
+		events.Default.Publish(repository.EventTitleAdded, repository.TitleEvent{ID: "0100000000010000"})
+		events.Default.Publish(repository.EventScanFinished, repository.ScanStatus{ID: 1})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req := httptest.NewRequest(http.MethodGet, "/api/events?types=title_added", nil).WithContext(ctx)
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(last.ID, 10))
+		writer := httptest.NewRecorder()
+
+		myShop.EventsHandler(writer, req)
+
+		Expect(writer.Header().Get("Content-Type")).To(Equal("text/event-stream"))
+		Expect(writer.Body.String()).To(ContainSubstring("event: title_added\ndata: {"))
+		Expect(writer.Body.String()).To(ContainSubstring(`"id":"0100000000010000"`))
+		Expect(writer.Body.String()).NotTo(ContainSubstring("scan_"))
+	})
+	It("streams the live events", func() {
+		server := httptest.NewServer(http.HandlerFunc(myShop.EventsHandler))
+		defer server.Close()
+
+		resp, err := http.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		reader := bufio.NewReader(resp.Body)
+		Expect(reader.ReadString('\n')).To(Equal(": connected\n"))
+
+		event := events.Default.Publish(repository.EventDownloadStarted, repository.DownloadEvent{TitleID: "0100000000010000"})
+
+		Expect(reader.ReadString('\n')).To(Equal("\n"))
+		Expect(reader.ReadString('\n')).To(Equal("id: " + strconv.FormatUint(event.ID, 10) + "\n"))
+		Expect(reader.ReadString('\n')).To(Equal("event: download_started\n"))
+		Expect(reader.ReadString('\n')).To(ContainSubstring(`"titleId":"0100000000010000"`))
+	})
+})
@@ -13,16 +13,23 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync"
 
+	"github.com/ajmandourah/tinshop-ng/events"
+	"github.com/ajmandourah/tinshop-ng/metrics"
 	"github.com/ajmandourah/tinshop-ng/repository"
 	"github.com/ajmandourah/tinshop-ng/utils"
 )
 
 var Rename bool = false
 
+// collect never modifies the Titledb and Files of the games once set, they are copied then swapped
+// so the games returned by Games are safe to read without the lock
 type collect struct {
+	gamesMutex    sync.RWMutex
 	games         repository.GameType
 	library       map[string]repository.TitleDBEntry
+	libraryMutex  sync.RWMutex
 	mergedLibrary map[string]repository.TitleDBEntry
 	config        repository.Config
 }
@@ -43,19 +50,40 @@ func (c *collect) Load() {
 	c.ResetGamesCollection()
 }
 
-func (c *collect) loadTitlesLibrary() {
-	//determine path 
-	var jsonPath string
-	if _, err := os.Stat("/data/config.yaml"); !os.IsNotExist(err) {
-		jsonPath = "/data/titles.US.en.json" 
-	}else{
-		jsonPath = "titles.US.en.json"
+// titleDBURL is where the titles library is downloaded from
+const titleDBURL = "https://tinfoil.media/repo/db/titles.json"
+
+// TitleDBFile is the name of the titles library in the data directory
+const TitleDBFile = "titles.US.en.json"
+
+// UpdateTitleDB downloads the titles library again and returns its number of titles.
+// The current file is only replaced by a valid library.
+func UpdateTitleDB(config repository.Config) (int, error) {
+	jsonPath := config.DataPath(TitleDBFile)
+	newPath := jsonPath + ".new"
+	defer os.Remove(newPath)
+
+	if err := utils.DownloadFile(titleDBURL, newPath); err != nil {
+		return 0, err
+	}
+	content, err := os.ReadFile(newPath)
+	if err != nil {
+		return 0, err
 	}
+	var library map[string]repository.TitleDBEntry
+	if err := json.Unmarshal(content, &library); err != nil {
+		return 0, fmt.Errorf("the downloaded titles library is invalid: %w", err)
+	}
+	return len(library), os.Rename(newPath, jsonPath)
+}
+
+func (c *collect) loadTitlesLibrary() {
+	jsonPath := c.config.DataPath(TitleDBFile)
 
 	// Open our jsonFile
 	if _, err := os.Stat(jsonPath); os.IsNotExist(err) {
 		log.Println("Missing 'titles.US.en.json'! Start downloading it.")
-		downloadErr := utils.DownloadFile("https://tinfoil.media/repo/db/titles.json", jsonPath )
+		downloadErr := utils.DownloadFile(titleDBURL, jsonPath )
 		if downloadErr != nil {
 			log.Fatalln(err, downloadErr)
 	}
@@ -64,6 +92,9 @@ func (c *collect) loadTitlesLibrary() {
 	if err != nil {
 		log.Fatalln("Error while parsing downloaded json file.\nPlease remove the file and start again the program.\n", err)
 	}
+	if info, errStat := jsonFile.Stat(); errStat == nil {
+		metrics.SetTitleDBTime(info.ModTime())
+	}
 
 	log.Println("Successfully Opened titles library")
 	// defer the closing of our jsonFile so that we can parse it later on
@@ -81,6 +112,9 @@ func (c *collect) loadTitlesLibrary() {
 
 // ResetGamesCollection reset the game collection
 func (c *collect) ResetGamesCollection() {
+	c.gamesMutex.Lock()
+	defer c.gamesMutex.Unlock()
+
 	// Build games object
 	if c.config.NoWelcomeMessage() {
 		c.games.Success = ""
@@ -92,32 +126,46 @@ func (c *collect) ResetGamesCollection() {
 	c.games.ThemeBlackList = nil
 }
 
-// OnConfigUpdate the collection of files
+// OnConfigUpdate the collection of files, the games are kept until the sources replace them
 func (c *collect) OnConfigUpdate(cfg repository.Config) {
 	c.config = cfg
-	c.ResetGamesCollection()
 
 	// Create merged library
-	c.mergedLibrary = make(map[string]repository.TitleDBEntry)
+	mergedLibrary := make(map[string]repository.TitleDBEntry)
 
 	// Copy library
 	for key, entry := range c.library {
 		gameID := strings.ToUpper(key)
 
-		c.mergedLibrary[gameID] = entry
+		mergedLibrary[gameID] = entry
 	}
 
 	// Copy CustomDB
 	for key, entry := range c.config.CustomDB() {
 		gameID := strings.ToUpper(key)
-		if _, ok := c.mergedLibrary[gameID]; ok {
+		if _, ok := mergedLibrary[gameID]; ok {
 			log.Println("Duplicate customDB entry from official titledb (consider removing from configuration)", gameID)
 		} else {
-			c.mergedLibrary[gameID] = entry
+			mergedLibrary[gameID] = entry
 		}
 	}
 
+	c.libraryMutex.Lock()
+	c.mergedLibrary = mergedLibrary
+	c.libraryMutex.Unlock()
+
 	// Check if blacklist entries
+	c.gamesMutex.Lock()
+	defer c.gamesMutex.Unlock()
+	if c.config.NoWelcomeMessage() {
+		c.games.Success = ""
+	} else {
+		c.games.Success = c.config.WelcomeMessage()
+	}
+	if c.games.Titledb == nil {
+		c.games.Titledb = make(map[string]repository.TitleDBEntry)
+		c.games.Files = make([]repository.GameFileType, 0)
+	}
 	if len(c.config.BannedTheme()) != 0 {
 		c.games.ThemeBlackList = c.config.BannedTheme()
 	} else {
@@ -125,8 +173,10 @@ func (c *collect) OnConfigUpdate(cfg repository.Config) {
 	}
 }
 
-// Library returns the titledb library
+// Library returns the titledb library, it is replaced and never modified on config update
 func (c *collect) Library() map[string]repository.TitleDBEntry {
+	c.libraryMutex.RLock()
+	defer c.libraryMutex.RUnlock()
 	return c.mergedLibrary
 }
 
@@ -143,11 +193,16 @@ func (c *collect) IsBaseGame(gameID string) bool {
 
 // Games returns the games inside the library
 func (c *collect) Games() repository.GameType {
+	c.gamesMutex.RLock()
+	defer c.gamesMutex.RUnlock()
 	return c.games
 }
 
 // Filter returns the games inside the library after filtering
 func (c *collect) Filter(filter string) repository.GameType {
+	c.gamesMutex.RLock()
+	defer c.gamesMutex.RUnlock()
+
 	var filteredGames repository.GameType
 	if !c.config.NoWelcomeMessage() {
 		filteredGames.Success = c.games.Success
@@ -197,21 +252,30 @@ func (c *collect) RemoveGame(ID string) {
 	gameID := strings.ToUpper(ID)
 	log.Println("Removing game", gameID)
 
+	c.gamesMutex.Lock()
+	defer c.gamesMutex.Unlock()
+	games := copyGames(c.games)
+
 	// Remove from Files entry
-	idx := utils.Search(len(c.games.Files), func(index int) bool {
-		return strings.Contains(c.games.Files[index].URL, gameID)
+	idx := utils.Search(len(games.Files), func(index int) bool {
+		return strings.Contains(games.Files[index].URL, gameID)
 	})
 
 	if idx != -1 {
-		c.games.Files = utils.RemoveGameFile(c.games.Files, idx)
+		games.Files = utils.RemoveGameFile(games.Files, idx)
+		events.Publish(repository.EventTitleRemoved, repository.TitleEvent{ID: gameID})
 	}
 
 	// Remove from titledb entry
-	delete(c.games.Titledb, gameID)
+	delete(games.Titledb, gameID)
+	c.games = games
 }
 
 // CountGames return the number of games in collection
 func (c *collect) CountGames() int {
+	c.gamesMutex.RLock()
+	defer c.gamesMutex.RUnlock()
+
 	var uniqueGames int
 	for _, entry := range c.games.Titledb {
 		if entry.IconURL != "" || entry.BannerURL != "" {
@@ -224,6 +288,87 @@ func (c *collect) CountGames() int {
 // AddNewGames increase the games available in the shop
 func (c *collect) AddNewGames(newGames []repository.FileDesc) {
 	log.Println("Add new games...")
+	c.gamesMutex.Lock()
+	games := copyGames(c.games)
+	c.addGames(&games, newGames)
+	c.games = games
+	c.gamesMutex.Unlock()
+
+	for _, file := range newGames {
+		publishTitleAdded(file)
+	}
+}
+
+// ReplaceGames builds the games from all the files then swaps them at once,
+// the previous games are served until it is done
+func (c *collect) ReplaceGames(files []repository.FileDesc) {
+	log.Println("Replace all games...")
+	c.gamesMutex.RLock()
+	games := repository.GameType{
+		Success:        c.games.Success,
+		ThemeBlackList: c.games.ThemeBlackList,
+		Headers:        c.games.Headers,
+	}
+	c.gamesMutex.RUnlock()
+	games.Titledb = make(map[string]repository.TitleDBEntry)
+	games.Files = make([]repository.GameFileType, 0)
+	c.addGames(&games, files)
+
+	c.gamesMutex.Lock()
+	previousIDs := gameIDs(c.games.Files)
+	c.games = games
+	c.gamesMutex.Unlock()
+
+	// Only the difference is published
+	newIDs := make(map[string]bool)
+	for _, file := range files {
+		newIDs[file.GameID] = true
+		if !previousIDs[file.GameID] {
+			publishTitleAdded(file)
+		}
+	}
+	for gameID := range previousIDs {
+		if !newIDs[gameID] {
+			events.Publish(repository.EventTitleRemoved, repository.TitleEvent{ID: gameID})
+		}
+	}
+}
+
+// copyGames returns the games with their own Titledb and Files to be modified
+func copyGames(games repository.GameType) repository.GameType {
+	titledb := make(map[string]repository.TitleDBEntry, len(games.Titledb))
+	for gameID, entry := range games.Titledb {
+		titledb[gameID] = entry
+	}
+	games.Titledb = titledb
+	games.Files = append(make([]repository.GameFileType, 0, len(games.Files)), games.Files...)
+	return games
+}
+
+func publishTitleAdded(file repository.FileDesc) {
+	events.Publish(repository.EventTitleAdded, repository.TitleEvent{
+		ID:     file.GameID,
+		Path:   file.Path,
+		Size:   file.Size,
+		Source: file.HostType,
+	})
+}
+
+// gameIDs returns the ids of the files from their url
+func gameIDs(files []repository.GameFileType) map[string]bool {
+	ids := make(map[string]bool, len(files))
+	for _, file := range files {
+		url, _, _ := strings.Cut(file.URL, "#")
+		idx := strings.LastIndex(url, "/games/")
+		if idx == -1 {
+			continue
+		}
+		ids[url[idx+len("/games/"):]] = true
+	}
+	return ids
+}
+
+func (c *collect) addGames(games *repository.GameType, newGames []repository.FileDesc) {
 	var gameList = make([]repository.GameFileType, 0)
 
 	for _, file := range newGames {
@@ -244,16 +389,16 @@ func (c *collect) AddNewGames(newGames []repository.FileDesc) {
 
 		if c.HasGameIDInLibrary(file.GameID) {
 			// Verify already present and not update nor dlc
-			if _, ok := c.games.Titledb[file.GameID]; ok && c.IsBaseGame(file.GameID) {
+			if _, ok := games.Titledb[file.GameID]; ok && c.IsBaseGame(file.GameID) {
 				log.Println("Already added id!", file.GameID, file.Path)
 			} else {
-				c.games.Titledb[file.GameID] = c.Library()[file.GameID]
+				games.Titledb[file.GameID] = c.Library()[file.GameID]
 			}
 		} else {
 			log.Println("Game not found in database!", file.GameInfo, file.Path)
 		}
 	}
-	c.games.Files = append(c.games.Files, gameList...)
+	games.Files = append(games.Files, gameList...)
 	log.Printf("Added %d games in your library\n", len(gameList))
 }
 
@@ -133,6 +133,25 @@ var _ = Describe("Collection", func() {
 				Expect(games.Titledb).To(HaveLen(1))
 				Expect(games.Files[0].URL).To(Equal("http://tinshop.example.com/games/010034500641A000#[010034500641A000] Attack on Titan 2 (US) [BASE].nsp"))
 			})
+			It("Keeps the games returned before unchanged", func() {
+				before := testCollection.Games()
+				testCollection.AddNewGames([]repository.FileDesc{{
+					Size:      42,
+					Path:      "/here/is/my/game",
+					GameID:    "010034500641A000",
+					GameInfo:  "[010034500641A000][v0].nsp",
+					Extension: "nsp",
+					HostType:  repository.LocalFile,
+				}})
+				Expect(before.Titledb).To(BeEmpty())
+				Expect(before.Files).To(BeEmpty())
+
+				added := testCollection.Games()
+				testCollection.RemoveGame("010034500641A000")
+				Expect(added.Titledb).To(HaveKey("010034500641A000"))
+				Expect(added.Files).To(HaveLen(1))
+				Expect(testCollection.Games().Titledb).To(BeEmpty())
+			})
 			It("Add a base game (without Region)", func() {
 				newGames := make([]repository.FileDesc, 0)
 				newFile := repository.FileDesc{
@@ -298,6 +317,59 @@ var _ = Describe("Collection", func() {
 			Expect(testCollection.Games().Files).To(HaveLen(1))
 		})
 	})
+	Describe("ReplaceGames", func() {
+		JustBeforeEach(func() {
+			testCollection.ResetGamesCollection()
+			testCollection.AddNewGames([]repository.FileDesc{{
+				Size:     42,
+				Path:     "/here/is/my/game",
+				GameID:   "0000000000000001",
+				GameInfo: "[0000000000000001][v0].nsp",
+				HostType: repository.LocalFile,
+			}})
+		})
+		It("Replace all games at once", func() {
+			testCollection.ReplaceGames([]repository.FileDesc{{
+				Size:     42,
+				Path:     "/here/is/my/other/game",
+				GameID:   "0000000000000002",
+				GameInfo: "[0000000000000002][v0].nsp",
+				HostType: repository.LocalFile,
+			}})
+
+			games := testCollection.Games()
+			Expect(games.Files).To(HaveLen(1))
+			Expect(games.Files[0].URL).To(ContainSubstring("0000000000000002"))
+			Expect(games.Success).To(Equal("Welcome to testing shop!"))
+		})
+		It("Replace with no games", func() {
+			testCollection.ReplaceGames(nil)
+
+			Expect(testCollection.Games().Files).To(HaveLen(0))
+			Expect(testCollection.Games().Titledb).To(HaveLen(0))
+		})
+	})
+	Describe("OnConfigUpdate", func() {
+		JustBeforeEach(func() {
+			myMockConfig.EXPECT().CustomDB().Return(nil).AnyTimes()
+			myMockConfig.EXPECT().BannedTheme().Return([]string{"THEME1"}).AnyTimes()
+			testCollection.ResetGamesCollection()
+			testCollection.AddNewGames([]repository.FileDesc{{
+				Size:     42,
+				Path:     "/here/is/my/game",
+				GameID:   "0000000000000001",
+				GameInfo: "[0000000000000001][v0].nsp",
+				HostType: repository.LocalFile,
+			}})
+		})
+		It("Keeps the games", func() {
+			testCollection.OnConfigUpdate(myMockConfig)
+
+			games := testCollection.Games()
+			Expect(games.Files).To(HaveLen(1))
+			Expect(games.ThemeBlackList).To(Equal([]string{"THEME1"}))
+		})
+	})
 	Describe("Filter", func() {
 		var (
 			myMockConfig *mock_repository.MockConfig
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+// HealthzHandler tells the process is alive, it does not check anything else
+func (s *TinShop) HealthzHandler(w http.ResponseWriter, _ *http.Request) {
+	s.Shop.API.Health(w, repository.Health{Status: repository.HealthOK})
+}
+
+// ReadyzHandler tells the shop is ready to serve with the details of each check
+func (s *TinShop) ReadyzHandler(w http.ResponseWriter, _ *http.Request) {
+	s.Shop.API.Health(w, s.readiness())
+}
+
+// readiness checks the titledb, the sources and the statistics
+func (s *TinShop) readiness() repository.Health {
+	checks := []repository.HealthCheck{s.titledbHealth()}
+	if s.Shop.Sources == nil {
+		checks = append(checks, repository.HealthCheck{Name: "scan", Status: repository.HealthFailed, Message: "sources not loaded"})
+	} else {
+		checks = append(checks, s.Shop.Sources.Health()...)
+	}
+	checks = append(checks, s.statsHealth())
+
+	health := repository.Health{Status: repository.HealthOK, Checks: checks}
+	for _, check := range checks {
+		if check.Status != repository.HealthOK {
+			health.Status = repository.HealthFailed
+		}
+	}
+	return health
+}
+
+func (s *TinShop) titledbHealth() repository.HealthCheck {
+	if s.Shop.Collection == nil || len(s.Shop.Collection.Library()) == 0 {
+		return repository.HealthCheck{Name: "titledb", Status: repository.HealthFailed, Message: "titledb not loaded"}
+	}
+	return repository.HealthCheck{
+		Name:    "titledb",
+		Status:  repository.HealthOK,
+		Message: fmt.Sprintf("%d titles", len(s.Shop.Collection.Library())),
+	}
+}
+
+func (s *TinShop) statsHealth() repository.HealthCheck {
+	if s.Shop.Stats == nil {
+		return repository.HealthCheck{Name: "stats", Status: repository.HealthFailed, Message: "stats database not open"}
+	}
+	if err := s.Shop.Stats.Ping(); err != nil {
+		log.Println("[Health] Stats database is not available:", err)
+		return repository.HealthCheck{Name: "stats", Status: repository.HealthFailed, Message: "stats database not open"}
+	}
+	return repository.HealthCheck{Name: "stats", Status: repository.HealthOK}
+}
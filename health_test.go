@@ -0,0 +1,84 @@
+package main_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	main "github.com/ajmandourah/tinshop-ng"
+	"github.com/ajmandourah/tinshop-ng/api"
+	"github.com/ajmandourah/tinshop-ng/mock_repository"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Health", func() {
+	var (
+		myShop           *main.TinShop
+		writer           *httptest.ResponseRecorder
+		myMockSources    *mock_repository.MockSources
+		myMockCollection *mock_repository.MockCollection
+		myMockStats      *mock_repository.MockStats
+	)
+
+	BeforeEach(func() {
+		ctrl := gomock.NewController(GinkgoT())
+		myMockSources = mock_repository.NewMockSources(ctrl)
+		myMockCollection = mock_repository.NewMockCollection(ctrl)
+		myMockStats = mock_repository.NewMockStats(ctrl)
+		myShop = &main.TinShop{}
+		myShop.Shop = repository.Shop{Sources: myMockSources, Collection: myMockCollection, Stats: myMockStats, API: api.New()}
+		writer = httptest.NewRecorder()
+	})
+
+	readiness := func() repository.Health {
+		var health repository.Health
+		Expect(json.Unmarshal(writer.Body.Bytes(), &health)).To(Succeed())
+		return health
+	}
+
+	It("tells the process is alive", func() {
+		myShop.HealthzHandler(writer, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		Expect(writer.Code).To(Equal(http.StatusOK))
+		Expect(readiness().Status).To(Equal(repository.HealthOK))
+	})
+	It("is ready when every check succeeds", func() {
+		myMockCollection.EXPECT().Library().Return(map[string]repository.TitleDBEntry{"0100000000010000": {}}).AnyTimes()
+		myMockSources.EXPECT().Health().Return([]repository.HealthCheck{
+			{Name: "scan", Status: repository.HealthOK},
+			{Name: "directory.0", Status: repository.HealthOK},
+		})
+		myMockStats.EXPECT().Ping().Return(nil)
+
+		myShop.ReadyzHandler(writer, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		Expect(writer.Code).To(Equal(http.StatusOK))
+		health := readiness()
+		Expect(health.Status).To(Equal(repository.HealthOK))
+		Expect(health.Checks).To(HaveLen(4))
+		Expect(health.Checks[0]).To(Equal(repository.HealthCheck{Name: "titledb", Status: repository.HealthOK, Message: "1 titles"}))
+	})
+	It("is not ready when a check fails", func() {
+		myMockCollection.EXPECT().Library().Return(map[string]repository.TitleDBEntry{}).AnyTimes()
+		myMockSources.EXPECT().Health().Return([]repository.HealthCheck{
+			{Name: "scan", Status: repository.HealthOK},
+			{Name: "nfs.0", Status: repository.HealthFailed, Message: "timeout"},
+		})
+		myMockStats.EXPECT().Ping().Return(errors.New("stats database not open"))
+
+		myShop.ReadyzHandler(writer, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		Expect(writer.Code).To(Equal(http.StatusServiceUnavailable))
+		health := readiness()
+		Expect(health.Status).To(Equal(repository.HealthFailed))
+		Expect(health.Checks).To(ContainElements(
+			repository.HealthCheck{Name: "titledb", Status: repository.HealthFailed, Message: "titledb not loaded"},
+			repository.HealthCheck{Name: "nfs.0", Status: repository.HealthFailed, Message: "timeout"},
+			repository.HealthCheck{Name: "stats", Status: repository.HealthFailed, Message: "stats database not open"},
+		))
+	})
+})
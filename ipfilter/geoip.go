@@ -0,0 +1,90 @@
+package ipfilter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+type ipRange struct {
+	start   net.IP
+	end     net.IP
+	country string
+}
+
+// GeoIP resolves the country of an ip from a csv database of ranges.
+// Each line is "first ip,last ip,country code" as in the db-ip.com lite country database.
+type GeoIP struct {
+	ranges []ipRange
+}
+
+// LoadGeoIP reads a GeoIP csv database
+func LoadGeoIP(path string) (*GeoIP, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	geoip := &GeoIP{ranges: make([]ipRange, 0)}
+	for {
+		record, errRead := reader.Read()
+		if errRead == io.EOF {
+			break
+		}
+		if errRead != nil {
+			return nil, errRead
+		}
+		if len(record) < 3 {
+			continue
+		}
+		start := net.ParseIP(strings.TrimSpace(record[0]))
+		end := net.ParseIP(strings.TrimSpace(record[1]))
+		if start == nil || end == nil {
+			continue
+		}
+		geoip.ranges = append(geoip.ranges, ipRange{
+			start:   start.To16(),
+			end:     end.To16(),
+			country: strings.ToUpper(strings.TrimSpace(record[2])),
+		})
+	}
+	if len(geoip.ranges) == 0 {
+		return nil, errors.New("no ip range found")
+	}
+
+	sort.Slice(geoip.ranges, func(i, j int) bool {
+		return bytes.Compare(geoip.ranges[i].start, geoip.ranges[j].start) < 0
+	})
+	return geoip, nil
+}
+
+// Len returns the number of ranges loaded
+func (g *GeoIP) Len() int {
+	return len(g.ranges)
+}
+
+// Country returns the country code of the ip or an empty string if unknown
+func (g *GeoIP) Country(ip net.IP) string {
+	ip = ip.To16()
+	if ip == nil {
+		return ""
+	}
+	// Find the last range starting before the ip
+	index := sort.Search(len(g.ranges), func(i int) bool {
+		return bytes.Compare(g.ranges[i].start, ip) > 0
+	}) - 1
+	if index < 0 || bytes.Compare(ip, g.ranges[index].end) > 0 {
+		return ""
+	}
+	return g.ranges[index].country
+}
@@ -0,0 +1,149 @@
+// @title tinshop IP Filter
+
+// @BasePath /ipfilter/
+
+// Package ipfilter provides allow and deny lists of ip, networks and countries
+package ipfilter
+
+import (
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/utils"
+)
+
+type policy struct {
+	allow          []*net.IPNet
+	deny           []*net.IPNet
+	allowCountries []string
+	denyCountries  []string
+	// invalid is set when some rules cannot be parsed, nothing is allowed then
+	invalid bool
+}
+
+type filter struct {
+	mutex    sync.RWMutex
+	index    policy
+	download policy
+	geoip    *GeoIP
+	geoPath  string
+}
+
+// New create a new ip filter
+func New() repository.IPFilter {
+	return &filter{}
+}
+
+// OnConfigUpdate compiles the rules from the configuration
+func (f *filter) OnConfigUpdate(cfg repository.Config) {
+	index := compile("index", cfg.IPFilterIndex())
+	download := compile("download", cfg.IPFilterDownload())
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.index = index
+	f.download = download
+
+	if cfg.GeoIPDatabase() != f.geoPath {
+		f.geoPath = cfg.GeoIPDatabase()
+		f.geoip = nil
+		if f.geoPath != "" {
+			geoip, err := LoadGeoIP(f.geoPath)
+			if err != nil {
+				log.Println("[IPFilter] Unable to load GeoIP database", err)
+			} else {
+				log.Println("[IPFilter] GeoIP database loaded with", geoip.Len(), "ranges")
+				f.geoip = geoip
+			}
+		}
+	}
+	if f.geoip == nil && (index.usesCountries() || download.usesCountries()) {
+		log.Println("[IPFilter] Country rules are set without a GeoIP database, every client is refused")
+	}
+}
+
+// AllowIndex tells if the ip can browse the shop
+func (f *filter) AllowIndex(ip string) bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.index.allows(net.ParseIP(ip), f.geoip)
+}
+
+// AllowDownload tells if the ip can download games
+func (f *filter) AllowDownload(ip string) bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.download.allows(net.ParseIP(ip), f.geoip)
+}
+
+func compile(name string, rules repository.IPPolicy) policy {
+	allow, errAllow := utils.ParseNetworks(rules.Allow)
+	deny, errDeny := utils.ParseNetworks(rules.Deny)
+	for _, err := range []error{errAllow, errDeny} {
+		if err != nil {
+			log.Printf("[IPFilter] Unable to apply the %s rules, every client is refused: %s\n", name, err)
+		}
+	}
+	return policy{
+		allow:          allow,
+		deny:           deny,
+		allowCountries: upper(rules.AllowCountries),
+		denyCountries:  upper(rules.DenyCountries),
+		invalid:        errAllow != nil || errDeny != nil,
+	}
+}
+
+func (p policy) usesCountries() bool {
+	return len(p.allowCountries) > 0 || len(p.denyCountries) > 0
+}
+
+// allows applies deny rules first, then the allow rules if any.
+// Rules which cannot be checked refuse everything rather than letting everyone in
+func (p policy) allows(ip net.IP, geoip *GeoIP) bool {
+	if p.invalid || (geoip == nil && p.usesCountries()) {
+		return false
+	}
+	var country string
+	if geoip != nil && ip != nil {
+		country = geoip.Country(ip)
+	}
+	if ip != nil && contains(p.deny, ip) {
+		return false
+	}
+	if country != "" && utils.Contains(p.denyCountries, country) {
+		return false
+	}
+
+	hasAllowRules := len(p.allow) > 0 || len(p.allowCountries) > 0
+	if !hasAllowRules {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	if contains(p.allow, ip) {
+		return true
+	}
+	return country != "" && utils.Contains(p.allowCountries, country)
+}
+
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func upper(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		result = append(result, strings.ToUpper(strings.TrimSpace(value)))
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package ipfilter_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/ajmandourah/tinshop-ng/ipfilter"
+	"github.com/ajmandourah/tinshop-ng/mock_repository"
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+var _ = Describe("IPFilter", func() {
+	var (
+		myMockConfig *mock_repository.MockConfig
+		ctrl         *gomock.Controller
+		filter       repository.IPFilter
+		geoipPath    string
+	)
+
+	configure := func(index, download repository.IPPolicy, database string) {
+		myMockConfig.EXPECT().IPFilterIndex().Return(index).AnyTimes()
+		myMockConfig.EXPECT().IPFilterDownload().Return(download).AnyTimes()
+		myMockConfig.EXPECT().GeoIPDatabase().Return(database).AnyTimes()
+		filter.OnConfigUpdate(myMockConfig)
+	}
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		myMockConfig = mock_repository.NewMockConfig(ctrl)
+		filter = ipfilter.New()
+		geoipPath = filepath.Join(GinkgoT().TempDir(), "geoip.csv")
+		Expect(os.WriteFile(geoipPath, []byte("1.0.0.0,1.0.0.255,AU\n2.0.0.0,2.15.255.255,FR\n2001:db8::,2001:db8::ffff,DE\n"), 0600)).To(Succeed())
+	})
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	It("Allows everything without rules", func() {
+		configure(repository.IPPolicy{}, repository.IPPolicy{}, "")
+		Expect(filter.AllowIndex("8.8.8.8")).To(BeTrue())
+		Expect(filter.AllowDownload("8.8.8.8")).To(BeTrue())
+	})
+	It("Allows only the listed networks", func() {
+		configure(repository.IPPolicy{Allow: []string{"192.168.1.0/24", "10.8.0.1"}}, repository.IPPolicy{}, "")
+		Expect(filter.AllowIndex("192.168.1.42")).To(BeTrue())
+		Expect(filter.AllowIndex("10.8.0.1")).To(BeTrue())
+		Expect(filter.AllowIndex("10.8.0.2")).To(BeFalse())
+		Expect(filter.AllowIndex("not an ip")).To(BeFalse())
+		Expect(filter.AllowDownload("10.8.0.2")).To(BeTrue())
+	})
+	It("Denies before allowing", func() {
+		policy := repository.IPPolicy{Allow: []string{"192.168.0.0/16"}, Deny: []string{"192.168.66.0/24"}}
+		configure(policy, policy, "")
+		Expect(filter.AllowDownload("192.168.1.1")).To(BeTrue())
+		Expect(filter.AllowDownload("192.168.66.1")).To(BeFalse())
+	})
+	It("Handles ipv6", func() {
+		configure(repository.IPPolicy{}, repository.IPPolicy{Deny: []string{"fd00::/8"}}, "")
+		Expect(filter.AllowDownload("fd00::1")).To(BeFalse())
+		Expect(filter.AllowDownload("2001:db8::1")).To(BeTrue())
+	})
+	It("Filters by country", func() {
+		configure(repository.IPPolicy{AllowCountries: []string{"fr", "DE"}}, repository.IPPolicy{DenyCountries: []string{"AU"}}, geoipPath)
+		Expect(filter.AllowIndex("2.1.2.3")).To(BeTrue())
+		Expect(filter.AllowIndex("2001:db8::1")).To(BeTrue())
+		Expect(filter.AllowIndex("1.0.0.1")).To(BeFalse())
+		Expect(filter.AllowIndex("8.8.8.8")).To(BeFalse())
+		Expect(filter.AllowDownload("1.0.0.1")).To(BeFalse())
+		Expect(filter.AllowDownload("8.8.8.8")).To(BeTrue())
+	})
+	It("Refuses everyone when the countries cannot be checked", func() {
+		configure(repository.IPPolicy{AllowCountries: []string{"FR"}}, repository.IPPolicy{DenyCountries: []string{"AU"}}, filepath.Join(GinkgoT().TempDir(), "missing.csv"))
+		Expect(filter.AllowIndex("2.1.2.3")).To(BeFalse())
+		Expect(filter.AllowDownload("8.8.8.8")).To(BeFalse())
+	})
+	It("Refuses everyone with an invalid network", func() {
+		configure(repository.IPPolicy{Deny: []string{"192.168.66.0/33"}}, repository.IPPolicy{Allow: []string{"10.0.0.1", "notanip"}}, "")
+		Expect(filter.AllowIndex("8.8.8.8")).To(BeFalse())
+		Expect(filter.AllowDownload("10.0.0.1")).To(BeFalse())
+	})
+	Describe("GeoIP", func() {
+		It("Resolves countries", func() {
+			geoip, err := ipfilter.LoadGeoIP(geoipPath)
+			Expect(err).To(BeNil())
+			Expect(geoip.Len()).To(Equal(3))
+			Expect(geoip.Country(net.ParseIP("1.0.0.0"))).To(Equal("AU"))
+			Expect(geoip.Country(net.ParseIP("2.15.255.255"))).To(Equal("FR"))
+			Expect(geoip.Country(net.ParseIP("2.16.0.0"))).To(Equal(""))
+			Expect(geoip.Country(net.ParseIP("0.0.0.1"))).To(Equal(""))
+		})
+		It("Fails with a missing file", func() {
+			_, err := ipfilter.LoadGeoIP(filepath.Join(GinkgoT().TempDir(), "missing.csv"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -46,15 +46,7 @@ func InitSwitchKeys(baseFolder string) (*switchKeys, error) {
 		err = errors.New("prod.keys not defined in settings.json")
 	}
 
-	// second, if not found by settings look into the current folder
-	if err != nil {
-		path = filepath.Join(baseFolder, "prod.keys")
-
-		log.Printf("Trying to load prod.keys based on current folder: %v \n", path)
-		p, err = properties.LoadFile(path, properties.UTF8)
-	}
-
-	// third, if not found in current, look in home directory
+	// second, if not found by settings look in home directory, where other switch tools keep them
 	if err != nil {
 		path = "${HOME}/.switch/prod.keys"
 
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ajmandourah/tinshop-ng/library"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/gorilla/mux"
+)
+
+// libraryTitles returns all titles of the library
+func (s *TinShop) libraryTitles() []repository.LibraryTitle {
+	return library.Titles(s.Shop.Sources.GetFiles(), s.Shop.Collection.Library())
+}
+
+// TitlesHandler lists the titles with pagination, sort and filters
+func (s *TinShop) TitlesHandler(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	query := repository.TitleQuery{
+		Sort:      values.Get("sort"),
+		Desc:      values.Get("order") == "desc",
+		Name:      values.Get("name"),
+		Language:  values.Get("language"),
+		Publisher: values.Get("publisher"),
+		Type:      values.Get("type"),
+	}
+	var ok bool
+	if query.Offset, ok = intParam(values.Get("offset")); !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if query.Limit, ok = intParam(values.Get("limit")); !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !library.IsValidSort(query.Sort) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.Shop.API.Titles(w, library.Query(s.libraryTitles(), query))
+}
+
+// TitleHandler returns a title with all its files, from its base, update or dlc id
+func (s *TinShop) TitleHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	title, ok := library.Title(s.libraryTitles(), strings.ToUpper(vars["id"]))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.Shop.API.Title(w, title)
+}
+
+// SearchHandler returns the titles matching a name
+func (s *TinShop) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	if values.Get("q") == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	limit, ok := intParam(values.Get("limit"))
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	titles := library.Search(s.libraryTitles(), values.Get("q"), limit)
+	s.Shop.API.Titles(w, repository.TitlesPage{Total: len(titles), Limit: limit, Titles: titles})
+}
+
+// FilesHandler lists the files, optionally of a single source type
+func (s *TinShop) FilesHandler(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	source := repository.HostType(values.Get("source"))
+	if source != "" && source != repository.LocalFile && source != repository.NFSShare {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	offset, ok := intParam(values.Get("offset"))
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	limit, ok := intParam(values.Get("limit"))
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.Shop.API.Files(w, library.Files(s.Shop.Sources.GetFiles(), s.Shop.Collection.Library(), source, offset, limit))
+}
+
+// MissingHandler lists the updates and dlc of the titledb missing in the library
+func (s *TinShop) MissingHandler(w http.ResponseWriter, _ *http.Request) {
+	s.Shop.API.Missing(w, library.Missing(s.libraryTitles(), s.Shop.Collection.Library()))
+}
+
+// UnidentifiedHandler lists the game files of the sources which could not be added
+func (s *TinShop) UnidentifiedHandler(w http.ResponseWriter, _ *http.Request) {
+	s.Shop.API.FailedFiles(w, s.Shop.Sources.FailedFiles())
+}
+
+// intParam parses an optional positive query parameter
+func intParam(value string) (int, bool) {
+	if value == "" {
+		return 0, true
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return 0, false
+	}
+	return number, true
+}
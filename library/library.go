@@ -0,0 +1,341 @@
+// @title tinshop Library
+
+// @BasePath /library/
+
+// Package library builds the browsable view of the games files and the titledb
+package library
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/utils"
+)
+
+const (
+	// DefaultLimit is the page size when none is asked
+	DefaultLimit = 50
+	// MaxLimit is the biggest page size allowed
+	MaxLimit = 500
+)
+
+// Sorts available for titles
+const (
+	SortName        = "name"
+	SortID          = "id"
+	SortSize        = "size"
+	SortReleaseDate = "releaseDate"
+)
+
+var versionRegexp = regexp.MustCompile(`\[v(\d+)\]`) //nolint:gochecknoglobals
+
+// ContentType returns base, update or dlc from a title id
+func ContentType(titleID string) string {
+	if len(titleID) != 16 {
+		return repository.ContentBase
+	}
+	_, update, dlc := utils.GetTitleMeta(titleID)
+	switch {
+	case dlc:
+		return repository.ContentDLC
+	case update:
+		return repository.ContentUpdate
+	}
+	return repository.ContentBase
+}
+
+// BaseID returns the id of the base title of a base, update or dlc id
+func BaseID(titleID string) string {
+	titleID = strings.ToUpper(titleID)
+	if len(titleID) != 16 {
+		return titleID
+	}
+	baseID, _, _ := utils.GetTitleMeta(titleID)
+	if baseID == "" {
+		return titleID
+	}
+	return baseID
+}
+
+// NewFile returns a file of the library with its titledb information
+func NewFile(file repository.FileDesc, titledb map[string]repository.TitleDBEntry) repository.LibraryFile {
+	libraryFile := repository.LibraryFile{
+		TitleID:   file.GameID,
+		BaseID:    BaseID(file.GameID),
+		Type:      ContentType(file.GameID),
+		Name:      titledb[file.GameID].Name,
+		Size:      file.Size,
+		Extension: file.Extension,
+		Path:      file.Path,
+		Source:    file.HostType,
+	}
+	if matches := versionRegexp.FindStringSubmatch(file.GameInfo); len(matches) == 2 {
+		libraryFile.Version, _ = strconv.Atoi(matches[1])
+	}
+	if libraryFile.Name == "" {
+		libraryFile.Name = titledb[libraryFile.BaseID].Name
+	}
+	return libraryFile
+}
+
+// Titles groups the files by base title, sorted by id
+func Titles(files []repository.FileDesc, titledb map[string]repository.TitleDBEntry) []repository.LibraryTitle {
+	byID := make(map[string]*repository.LibraryTitle)
+	for _, file := range files {
+		libraryFile := NewFile(file, titledb)
+		title, ok := byID[libraryFile.BaseID]
+		if !ok {
+			entry, inTitleDB := titledb[libraryFile.BaseID]
+			if entry.ID == "" {
+				entry.ID = libraryFile.BaseID
+			}
+			title = &repository.LibraryTitle{
+				TitleDBEntry: entry,
+				InTitleDB:    inTitleDB,
+				Files:        make([]repository.LibraryFile, 0),
+				Updates:      make([]repository.LibraryFile, 0),
+				DLC:          make([]repository.LibraryFile, 0),
+			}
+			byID[libraryFile.BaseID] = title
+		}
+
+		title.TotalSize += libraryFile.Size
+		switch libraryFile.Type {
+		case repository.ContentUpdate:
+			title.Updates = append(title.Updates, libraryFile)
+		case repository.ContentDLC:
+			title.DLC = append(title.DLC, libraryFile)
+		default:
+			title.Files = append(title.Files, libraryFile)
+		}
+		if libraryFile.Type != repository.ContentDLC && libraryFile.Version > title.LatestVersion {
+			title.LatestVersion = libraryFile.Version
+		}
+	}
+
+	titles := make([]repository.LibraryTitle, 0, len(byID))
+	for _, title := range byID {
+		sort.Slice(title.Updates, func(i, j int) bool { return title.Updates[i].Version < title.Updates[j].Version })
+		sort.Slice(title.DLC, func(i, j int) bool { return title.DLC[i].TitleID < title.DLC[j].TitleID })
+		titles = append(titles, *title)
+	}
+	sort.Slice(titles, func(i, j int) bool { return titles[i].ID < titles[j].ID })
+	return titles
+}
+
+// Title returns the title of a base, update or dlc id
+func Title(titles []repository.LibraryTitle, titleID string) (repository.LibraryTitle, bool) {
+	baseID := BaseID(titleID)
+	for _, title := range titles {
+		if title.ID == baseID {
+			return title, true
+		}
+	}
+	return repository.LibraryTitle{}, false
+}
+
+// Query filters, sorts and paginates the titles
+func Query(titles []repository.LibraryTitle, query repository.TitleQuery) repository.TitlesPage {
+	filtered := make([]repository.LibraryTitle, 0, len(titles))
+	for _, title := range titles {
+		if matchTitle(title, query) {
+			filtered = append(filtered, title)
+		}
+	}
+	sortTitles(filtered, query.Sort, query.Desc)
+
+	offset, limit := pagination(query.Offset, query.Limit)
+	page := repository.TitlesPage{Total: len(filtered), Offset: offset, Limit: limit}
+	start, end := bounds(len(filtered), offset, limit)
+	page.Titles = filtered[start:end]
+	return page
+}
+
+func matchTitle(title repository.LibraryTitle, query repository.TitleQuery) bool {
+	if query.Name != "" && !containsFold(title.Name, query.Name) {
+		return false
+	}
+	if query.Language != "" && !utils.Contains(title.Languages, strings.ToLower(query.Language)) && !utils.Contains(title.Languages, strings.ToUpper(query.Language)) {
+		return false
+	}
+	if query.Publisher != "" && !containsFold(title.Publisher, query.Publisher) {
+		return false
+	}
+	switch query.Type {
+	case repository.ContentUpdate:
+		return len(title.Updates) > 0
+	case repository.ContentDLC:
+		return len(title.DLC) > 0
+	case repository.ContentBase:
+		return len(title.Files) > 0
+	}
+	return true
+}
+
+func sortTitles(titles []repository.LibraryTitle, by string, desc bool) {
+	less := func(a, b repository.LibraryTitle) bool {
+		switch by {
+		case SortID:
+			return a.ID < b.ID
+		case SortSize:
+			if a.TotalSize != b.TotalSize {
+				return a.TotalSize < b.TotalSize
+			}
+		case SortReleaseDate:
+			if a.ReleaseDate != b.ReleaseDate {
+				return a.ReleaseDate < b.ReleaseDate
+			}
+		default:
+			nameA, nameB := strings.ToLower(a.Name), strings.ToLower(b.Name)
+			if nameA != nameB {
+				return nameA < nameB
+			}
+		}
+		return a.ID < b.ID
+	}
+	sort.SliceStable(titles, func(i, j int) bool {
+		if desc {
+			return less(titles[j], titles[i])
+		}
+		return less(titles[i], titles[j])
+	})
+}
+
+// IsValidSort tells if the titles can be sorted by this field
+func IsValidSort(by string) bool {
+	return by == "" || by == SortName || by == SortID || by == SortSize || by == SortReleaseDate
+}
+
+// Missing returns the titles of the library whose latest update or some dlc
+// listed in the titledb are not in the library
+func Missing(titles []repository.LibraryTitle, titledb map[string]repository.TitleDBEntry) []repository.MissingContent {
+	dlcByBase := make(map[string][]repository.TitleDBEntry)
+	for titleID, entry := range titledb {
+		if ContentType(titleID) == repository.ContentDLC {
+			baseID := BaseID(titleID)
+			dlcByBase[baseID] = append(dlcByBase[baseID], entry)
+		}
+	}
+
+	missing := make([]repository.MissingContent, 0)
+	for _, title := range titles {
+		if !title.InTitleDB {
+			continue
+		}
+		content := repository.MissingContent{
+			ID:      title.ID,
+			Name:    title.Name,
+			IconURL: title.IconURL,
+			Version: title.LatestVersion,
+			DLC:     make([]repository.MissingDLC, 0),
+		}
+		if int(title.TitleDBEntry.Version) > title.LatestVersion {
+			content.LatestVersion = int(title.TitleDBEntry.Version)
+		}
+
+		owned := make(map[string]bool, len(title.DLC))
+		for _, dlc := range title.DLC {
+			owned[strings.ToUpper(dlc.TitleID)] = true
+		}
+		for _, dlc := range dlcByBase[title.ID] {
+			if dlc.ID != "" && !owned[strings.ToUpper(dlc.ID)] {
+				content.DLC = append(content.DLC, repository.MissingDLC{ID: dlc.ID, Name: dlc.Name})
+			}
+		}
+		sort.Slice(content.DLC, func(i, j int) bool { return content.DLC[i].ID < content.DLC[j].ID })
+
+		if content.LatestVersion != 0 || len(content.DLC) != 0 {
+			missing = append(missing, content)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return strings.ToLower(missing[i].Name) < strings.ToLower(missing[j].Name)
+	})
+	return missing
+}
+
+// Search returns the titles whose name, or the name of one of their dlc, contains the text.
+// Titles starting with the text come first.
+func Search(titles []repository.LibraryTitle, text string, limit int) []repository.LibraryTitle {
+	text = strings.TrimSpace(text)
+	found := make([]repository.LibraryTitle, 0)
+	if text == "" {
+		return found
+	}
+	for _, title := range titles {
+		if containsFold(title.Name, text) || title.ID == strings.ToUpper(text) {
+			found = append(found, title)
+			continue
+		}
+		for _, dlc := range title.DLC {
+			if containsFold(dlc.Name, text) {
+				found = append(found, title)
+				break
+			}
+		}
+	}
+
+	lowerText := strings.ToLower(text)
+	sort.SliceStable(found, func(i, j int) bool {
+		prefixI := strings.HasPrefix(strings.ToLower(found[i].Name), lowerText)
+		prefixJ := strings.HasPrefix(strings.ToLower(found[j].Name), lowerText)
+		if prefixI != prefixJ {
+			return prefixI
+		}
+		return strings.ToLower(found[i].Name) < strings.ToLower(found[j].Name)
+	})
+	_, limit = pagination(0, limit)
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found
+}
+
+// Files returns a page of the files, of a single source when given
+func Files(files []repository.FileDesc, titledb map[string]repository.TitleDBEntry, source repository.HostType, offset int, limit int) repository.FilesPage {
+	filtered := make([]repository.LibraryFile, 0, len(files))
+	for _, file := range files {
+		if source != "" && file.HostType != source {
+			continue
+		}
+		filtered = append(filtered, NewFile(file, titledb))
+	}
+	sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].Path < filtered[j].Path })
+
+	offset, limit = pagination(offset, limit)
+	page := repository.FilesPage{Total: len(filtered), Offset: offset, Limit: limit}
+	start, end := bounds(len(filtered), offset, limit)
+	page.Files = filtered[start:end]
+	return page
+}
+
+func pagination(offset int, limit int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	return offset, limit
+}
+
+func bounds(length int, offset int, limit int) (int, int) {
+	if offset > length {
+		offset = length
+	}
+	end := offset + limit
+	if end > length {
+		end = length
+	}
+	return offset, end
+}
+
+func containsFold(s string, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,126 @@
+package library_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/ajmandourah/tinshop-ng/library"
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+var _ = Describe("Library", func() {
+	var (
+		files   []repository.FileDesc
+		titledb map[string]repository.TitleDBEntry
+		titles  []repository.LibraryTitle
+	)
+
+	BeforeEach(func() {
+		files = []repository.FileDesc{
+			{GameID: "0100000000010000", GameInfo: "[0100000000010000][v0].nsp", Size: 100, Extension: "nsp", Path: "/games/zelda.nsp", HostType: repository.LocalFile},
+			{GameID: "0100000000010800", GameInfo: "[0100000000010800][v131072].nsp", Size: 10, Extension: "nsp", Path: "/games/zelda-update.nsp", HostType: repository.LocalFile},
+			{GameID: "0100000000011001", GameInfo: "[0100000000011001][v0].nsp", Size: 5, Extension: "nsp", Path: "/nfs/zelda-dlc.nsp", HostType: repository.NFSShare},
+			{GameID: "0100000000020000", GameInfo: "[0100000000020000][v0].xci", Size: 300, Extension: "xci", Path: "/games/mario.xci", HostType: repository.LocalFile},
+			{GameID: "0100000000030000", GameInfo: "[0100000000030000][v0].nsz", Size: 50, Extension: "nsz", Path: "/nfs/unknown.nsz", HostType: repository.NFSShare},
+		}
+		titledb = map[string]repository.TitleDBEntry{
+			"0100000000010000": {ID: "0100000000010000", Name: "Zelda", Publisher: "Nintendo", Languages: []string{"en", "fr"}, ReleaseDate: 20170303},
+			"0100000000010800": {ID: "0100000000010800", Version: 131072},
+			"0100000000011001": {ID: "0100000000011001", Name: "Zelda Expansion Pass"},
+			"0100000000020000": {ID: "0100000000020000", Name: "Mario Kart", Publisher: "Nintendo", Languages: []string{"en"}, ReleaseDate: 20170428},
+		}
+		titles = library.Titles(files, titledb)
+	})
+
+	It("Detects the content type", func() {
+		Expect(library.ContentType("0100000000010000")).To(Equal(repository.ContentBase))
+		Expect(library.ContentType("0100000000010800")).To(Equal(repository.ContentUpdate))
+		Expect(library.ContentType("0100000000011001")).To(Equal(repository.ContentDLC))
+		Expect(library.ContentType("short")).To(Equal(repository.ContentBase))
+		Expect(library.BaseID("0100000000011001")).To(Equal("0100000000010000"))
+	})
+	It("Groups files by base title", func() {
+		Expect(titles).To(HaveLen(3))
+		zelda := titles[0]
+		Expect(zelda.ID).To(Equal("0100000000010000"))
+		Expect(zelda.Name).To(Equal("Zelda"))
+		Expect(zelda.InTitleDB).To(BeTrue())
+		Expect(zelda.TotalSize).To(Equal(int64(115)))
+		Expect(zelda.LatestVersion).To(Equal(131072))
+		Expect(zelda.Files).To(HaveLen(1))
+		Expect(zelda.Updates).To(HaveLen(1))
+		Expect(zelda.Updates[0].Name).To(Equal("Zelda"))
+		Expect(zelda.DLC).To(HaveLen(1))
+		Expect(zelda.DLC[0].Name).To(Equal("Zelda Expansion Pass"))
+		Expect(zelda.DLC[0].Source).To(Equal(repository.NFSShare))
+
+		Expect(titles[2].ID).To(Equal("0100000000030000"))
+		Expect(titles[2].InTitleDB).To(BeFalse())
+	})
+	It("Lists the missing updates and dlc", func() {
+		zelda := titledb["0100000000010000"]
+		zelda.Version = 196608
+		titledb["0100000000010000"] = zelda
+		titledb["0100000000011002"] = repository.TitleDBEntry{ID: "0100000000011002", Name: "Zelda Champions"}
+		titles = library.Titles(files, titledb)
+
+		missing := library.Missing(titles, titledb)
+
+		Expect(missing).To(HaveLen(1))
+		Expect(missing[0].ID).To(Equal("0100000000010000"))
+		Expect(missing[0].Version).To(Equal(131072))
+		Expect(missing[0].LatestVersion).To(Equal(196608))
+		Expect(missing[0].DLC).To(Equal([]repository.MissingDLC{{ID: "0100000000011002", Name: "Zelda Champions"}}))
+	})
+	It("Has nothing missing when up to date", func() {
+		Expect(library.Missing(titles, titledb)).To(BeEmpty())
+	})
+	It("Finds a title from any of its ids", func() {
+		title, ok := library.Title(titles, "0100000000011001")
+		Expect(ok).To(BeTrue())
+		Expect(title.Name).To(Equal("Zelda"))
+		_, ok = library.Title(titles, "0100000000040000")
+		Expect(ok).To(BeFalse())
+	})
+	It("Sorts and paginates titles", func() {
+		page := library.Query(titles, repository.TitleQuery{Sort: library.SortSize, Desc: true, Limit: 2})
+		Expect(page.Total).To(Equal(3))
+		Expect(page.Limit).To(Equal(2))
+		Expect(page.Titles).To(HaveLen(2))
+		Expect(page.Titles[0].Name).To(Equal("Mario Kart"))
+		Expect(page.Titles[1].Name).To(Equal("Zelda"))
+
+		page = library.Query(titles, repository.TitleQuery{Offset: 2, Limit: 2})
+		Expect(page.Titles).To(HaveLen(1))
+		page = library.Query(titles, repository.TitleQuery{Offset: 10})
+		Expect(page.Titles).To(BeEmpty())
+		Expect(page.Limit).To(Equal(library.DefaultLimit))
+	})
+	It("Filters titles", func() {
+		Expect(library.Query(titles, repository.TitleQuery{Language: "FR"}).Titles).To(HaveLen(1))
+		Expect(library.Query(titles, repository.TitleQuery{Publisher: "nintendo"}).Titles).To(HaveLen(2))
+		Expect(library.Query(titles, repository.TitleQuery{Type: repository.ContentDLC}).Titles).To(HaveLen(1))
+		Expect(library.Query(titles, repository.TitleQuery{Name: "kart"}).Titles[0].ID).To(Equal("0100000000020000"))
+	})
+	It("Searches by name", func() {
+		found := library.Search(titles, "expansion", 10)
+		Expect(found).To(HaveLen(1))
+		Expect(found[0].Name).To(Equal("Zelda"))
+
+		found = library.Search(titles, "ar", 10)
+		Expect(found).To(HaveLen(1))
+		Expect(library.Search(titles, " ", 10)).To(BeEmpty())
+	})
+	It("Lists files by source", func() {
+		page := library.Files(files, titledb, repository.NFSShare, 0, 0)
+		Expect(page.Total).To(Equal(2))
+		Expect(page.Files[0].Path).To(Equal("/nfs/unknown.nsz"))
+		Expect(page.Files[1].Type).To(Equal(repository.ContentDLC))
+
+		page = library.Files(files, titledb, "", 1, 1)
+		Expect(page.Total).To(Equal(5))
+		Expect(page.Files).To(HaveLen(1))
+		Expect(page.Files[0].Path).To(Equal("/games/zelda-update.nsp"))
+		Expect(page.Files[0].Version).To(Equal(131072))
+	})
+})
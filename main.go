@@ -3,24 +3,37 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"embed"
 	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ajmandourah/tinshop-ng/api"
+	"github.com/ajmandourah/tinshop-ng/audit"
+	"github.com/ajmandourah/tinshop-ng/certs"
 	"github.com/ajmandourah/tinshop-ng/config"
+	"github.com/ajmandourah/tinshop-ng/devices"
+	"github.com/ajmandourah/tinshop-ng/events"
 	collection "github.com/ajmandourah/tinshop-ng/gamescollection"
+	"github.com/ajmandourah/tinshop-ng/ipfilter"
 	"github.com/ajmandourah/tinshop-ng/keys"
 	"github.com/ajmandourah/tinshop-ng/repository"
 	"github.com/ajmandourah/tinshop-ng/sources"
 	"github.com/ajmandourah/tinshop-ng/stats"
+	"github.com/ajmandourah/tinshop-ng/tokens"
+	"github.com/ajmandourah/tinshop-ng/users"
 	"github.com/ajmandourah/tinshop-ng/utils"
-	"github.com/goji/httpauth"
+	"github.com/ajmandourah/tinshop-ng/webhooks"
 	"github.com/gorilla/mux"
 )
 
@@ -28,33 +41,78 @@ import (
 var assetData embed.FS //nolint:gochecknoglobals
 // TinShop holds all information about the Shop
 type TinShop struct {
-	Shop   repository.Shop
-	Server *http.Server
+	Shop     repository.Shop
+	Server   *http.Server
+	Redirect *http.Server
 }
 
-var creds []string
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev" //nolint:gochecknoglobals
+
 func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// serveCommand runs `tinshop serve` until interrupted and returns the exit code
+func serveCommand(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	file := flags.String("config", "", configFlagUsage)
+	dataDir := flags.String("data", "", dataFlagUsage)
+	listen := flags.String("listen", "", "address to listen on as host:port, its port replaces the port of the config (default 0.0.0.0 and the port of the config)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *listen != "" {
+		_, port, err := net.SplitHostPort(*listen)
+		portNumber, errPort := strconv.Atoi(port)
+		if err != nil || errPort != nil {
+			fmt.Fprintf(stderr, "Error: invalid listen address '%s', expected host:port\n", *listen)
+			return 2
+		}
+		config.SetPort(portNumber)
+	}
+	if err := useLocation(*file, *dataDir); err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
 
 	// this is dirty. will leave it for now untill implemented correctly as there are some conflicts around the shop init
 
 	config := config.New()
-	config.LoadConfig()
-
-	prodkeys, _ := keys.InitSwitchKeys(config.ProdKeys())
-	if prodkeys == nil || prodkeys.GetKey("header_key") == "" {
-		log.Println("!!NOTE!!: keys file was not found, deep scan is disabled, library will be based on file tags.")
-		keys.UseKey = false
+	if err := config.LoadConfig(); err != nil {
+		log.Println("Unable to start with this configuration, check it with `tinshop check-config`:", err)
+		return 1
 	}
+	if err := prepareDataDir(config); err != nil {
+		log.Println(err)
+		return 1
+	}
+
+	loadKeys(config)
 	collection.Rename = config.Rename()
 
-	shop := createShop()
+	shop := createShop(*listen)
 
 	// Run our server in a goroutine so that it doesn't block.
 	go func() {
-		if err := shop.Server.ListenAndServe(); err != nil {
+		var err error
+		if shop.Server.TLSConfig != nil {
+			err = shop.Server.ListenAndServeTLS("", "")
+		} else {
+			err = shop.Server.ListenAndServe()
+		}
+		if err != nil {
 			log.Println(err)
 		}
 	}()
+	if shop.Redirect != nil {
+		go func() {
+			if err := shop.Redirect.ListenAndServe(); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
 	log.Printf("Total of %d files in your library (%d in titledb section)\n", len(shop.Shop.Collection.Games().Files), len(shop.Shop.Collection.Games().Titledb))
 	var uniqueGames = shop.Shop.Collection.CountGames()
 	log.Printf("Total of %d unique games in your library\n", uniqueGames)
@@ -74,43 +132,62 @@ func main() {
 	// Doesn't block if no connections, but will otherwise wait
 	// until the timeout deadline.
 	_ = shop.Server.Shutdown(ctx)
+	if shop.Redirect != nil {
+		_ = shop.Redirect.Shutdown(ctx)
+	}
+	// Write pending statistics
+	_ = shop.Shop.Stats.Close()
+	// Finish pending webhook deliveries
+	shop.Shop.Webhooks.Close()
 	// Optionally, you could run srv.Shutdown in a goroutine and block on
 	// <-ctx.Done() if your application should wait for other services
 	// to finalize based on context cancellation.
 	log.Println("shutting down")
-	os.Exit(0) //nolint:gocritic
+	return 0
 }
 
-func createShop() TinShop {
+// loadKeys reads the switch keys of the config, the deep scan is disabled without them
+func loadKeys(cfg repository.Config) {
+	prodkeys, _ := keys.InitSwitchKeys(cfg.ProdKeys())
+	if prodkeys == nil || prodkeys.GetKey("header_key") == "" {
+		log.Println("!!NOTE!!: keys file was not found, deep scan is disabled, library will be based on file tags.")
+		keys.UseKey = false
+	}
+}
+
+// createShop builds the shop and its servers, the server listens on listen when it is not empty
+func createShop(listen string) TinShop {
 	var shop = &TinShop{}
 
 	shop.Shop = initShop()
-	creds = shop.Shop.Config.Get_Httpauth()
-
-	authOpts := httpauth.AuthOptions{
-		Realm: "Tinfoil",
-		AuthFunc: HttpAuthCheck,
-	}
+	registerLibraryMetrics(shop.Shop.Sources)
 
 	r := mux.NewRouter()
 
+	apiRoute := r.PathPrefix("/api").Subrouter()
+	apiRoute.Methods(http.MethodOptions).HandlerFunc(func(http.ResponseWriter, *http.Request) {}) // CORS preflight
+	shop.RegisterAPI(apiRoute)
+
+	r.HandleFunc("/metrics", shop.MetricsHandler).Methods(http.MethodGet)
+	r.HandleFunc("/healthz", shop.HealthzHandler).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", shop.ReadyzHandler).Methods(http.MethodGet)
+	r.Handle("/admin", http.RedirectHandler("/admin/", http.StatusMovedPermanently)).Methods(http.MethodGet)
+	r.PathPrefix("/admin/").Handler(shop.AdminUIHandler()).Methods(http.MethodGet)
+	r.MatcherFunc(shop.CatalogMatcher).Methods(http.MethodGet).Handler(shop.AuthMiddleware(shop.CatalogHandler()))
+
 	authRoute := r.Methods(http.MethodGet).Subrouter()
 	authRoute.HandleFunc("/", shop.HomeHandler)
 	authRoute.HandleFunc("/{filter}", shop.FilteringHandler)
 	authRoute.HandleFunc("/{filter}/", shop.FilteringHandler)
-	authRoute.HandleFunc("/api/{endpoint}", shop.APIHandler)
+	authRoute.Use(shop.AuthMiddleware)
 
-	r.HandleFunc("/games/{game}", shop.GamesHandler)
+	r.Handle("/games/{game}", shop.AuthMiddleware(http.HandlerFunc(shop.GamesHandler)))
 	r.NotFoundHandler = http.HandlerFunc(notFound)
 	r.MethodNotAllowedHandler = http.HandlerFunc(notAllowed)
-	
-
-	if len(shop.Shop.Config.Get_Httpauth()) != 0 {
-		authRoute.Use(httpauth.BasicAuth(authOpts))
-	}
 
-	// r.Use(shop.StatsMiddleware)
+	r.Use(shop.MetricsMiddleware)
 	r.Use(shop.TinfoilMiddleware)
+	r.Use(shop.StatsMiddleware)
 	r.Use(shop.CORSMiddleware)
 	http.Handle("/", r)
 
@@ -119,9 +196,13 @@ func createShop() TinShop {
 		port = shop.Shop.Config.Port()
 	}
 
+	if listen == "" {
+		listen = "0.0.0.0:" + strconv.Itoa(port)
+	}
+
 	srv := &http.Server{
 		Handler: r,
-		Addr:    "0.0.0.0:" + strconv.Itoa(port),
+		Addr:    listen,
 
 		// Good practice to set timeouts to avoid Slowloris attacks.
 		WriteTimeout: 0, // Installing large game can take a lot of time
@@ -129,10 +210,46 @@ func createShop() TinShop {
 		IdleTimeout:  time.Second * 60,
 	}
 	shop.Server = srv
+	// Let the event streams end, the server waits for them on shutdown
+	srv.RegisterOnShutdown(events.Default.Close)
+
+	if shop.Shop.Config.TLSEnabled() {
+		reloader, err := certs.New(shop.Shop.Config.TLSCertFile(), shop.Shop.Config.TLSKeyFile())
+		if err != nil {
+			log.Fatal("[TLS] Unable to load certificate: ", err)
+		}
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+
+		if shop.Shop.Config.TLSRedirectPort() != 0 {
+			shop.Redirect = &http.Server{
+				Handler:      http.HandlerFunc(httpsRedirect(port)),
+				Addr:         "0.0.0.0:" + strconv.Itoa(shop.Shop.Config.TLSRedirectPort()),
+				ReadTimeout:  time.Second * 15,
+				WriteTimeout: time.Second * 15,
+			}
+		}
+	}
 
 	return *shop
 }
 
+// httpsRedirect sends every request to the same url on the https listener
+func httpsRedirect(port int) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	}
+}
+
 // ResetTinshop reset the storage for all information
 // func ResetTinshop(myShop repository.Shop) {
 // 	shopData = myShop
@@ -144,20 +261,55 @@ func initShop() repository.Shop {
 	myShop.Config = config.New()
 	myShop.Collection = collection.New(myShop.Config)
 	myShop.Sources = sources.New(myShop.Collection)
-	myShop.Stats = stats.New()
+	myShop.IPFilter = ipfilter.New()
 	myShop.API = api.New()
 
+	// Loading config, the state files are in its data directory
+	if err := myShop.Config.LoadConfig(); err != nil {
+		log.Println("Unable to start with this configuration, check it with `tinshop check-config`:", err)
+		os.Exit(1)
+	}
+	myShop.Users = users.New(myShop.Config.DataPath(usersFile))
+	myShop.Tokens = tokens.New(myShop.Config.DataPath(tokensFile))
+	myShop.Devices = devices.New(myShop.Config.DataPath(devicesFile))
+
 	// Load collection
 	myShop.Collection.Load()
 
-	// Loading config
+	// Loading sources, the security is updated before loading the new sources
+	myShop.Collection.OnConfigUpdate(myShop.Config)
+	myShop.IPFilter.OnConfigUpdate(myShop.Config)
+	myShop.Sources.OnConfigUpdate(myShop.Config)
 	myShop.Config.AddHook(myShop.Collection.OnConfigUpdate)
+	myShop.Config.AddHook(myShop.IPFilter.OnConfigUpdate)
 	myShop.Config.AddHook(myShop.Sources.OnConfigUpdate)
 	myShop.Config.AddBeforeHook(myShop.Sources.BeforeConfigUpdate)
-	myShop.Config.LoadConfig()
 
-	// Loading stats
-	myShop.Stats.Load()
+	// Loading stats, the database path comes from the config
+	myShop.Stats = stats.New(myShop.Config.StatsPath())
+	myShop.Stats.OnConfigUpdate(myShop.Config)
+	myShop.Config.AddHook(myShop.Stats.OnConfigUpdate)
+	if err := myShop.Stats.Load(); err != nil {
+		log.Println("[Stats] Statistics are disabled:", err)
+	}
+
+	// Loading accounts
+	myShop.Users.Load()
+
+	// Loading device registry
+	myShop.Devices.Load()
+
+	// Opening audit log
+	myShop.Audit = audit.New(myShop.Config.DataPath(auditFile), myShop.Config.AuditMaxSize(), myShop.Config.AuditMaxBackups())
+
+	// Loading api tokens
+	myShop.Tokens.Load()
+	bootstrapToken(myShop.Tokens, myShop.Config.DataPath(bootstrapFile))
+
+	// Notifying webhooks, the files already loaded are not new
+	myShop.Webhooks = webhooks.New(myShop.Sources, myShop.Collection)
+	myShop.Webhooks.OnConfigUpdate(myShop.Config)
+	myShop.Config.AddHook(myShop.Webhooks.OnConfigUpdate)
 
 	return myShop
 }
@@ -207,7 +359,61 @@ func (s *TinShop) GamesHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	log.Println("Requesting game", vars["game"])
 
-	s.Shop.Sources.DownloadGame(vars["game"], w, r)
+	downloadEvent := s.newDownloadEvent(vars["game"], r)
+	publishDownload(r, repository.EventDownloadStarted, downloadEvent)
+
+	counter := utils.NewResponseCounter(w)
+	s.Shop.Sources.DownloadGame(vars["game"], counter, r)
+
+	downloadEvent.Status = counter.Status
+	downloadEvent.Bytes = counter.Bytes
+	publishDownload(r, downloadEventType(counter), downloadEvent)
+
+	// Tinfoil downloads a file with many Range requests, only the first one is audited
+	if startsDownload(r) {
+		event := newAuditEvent(repository.AuditDownload, r, r.Header.Get("Range"))
+		event.TitleID = vars["game"]
+		event.Bytes = counter.Bytes
+		s.recordAudit(event)
+	}
+
+	if counter.Status != http.StatusOK && counter.Status != http.StatusPartialContent {
+		return
+	}
+	download := repository.Download{
+		TitleID: vars["game"],
+		Console: repository.Switch{
+			IP:  utils.GetIPFromRequest(r),
+			UID: r.Header.Get("Uid"),
+		},
+		Bytes: counter.Bytes,
+		Size:  downloadSize(counter),
+	}
+	download.Completed = counter.Status == http.StatusOK && download.Size > 0 && counter.Bytes >= download.Size
+	if err := s.Shop.Stats.DownloadServed(download); err != nil {
+		log.Println("[Stats] Unable to record download", err)
+	}
+}
+
+// startsDownload returns if the request asks the file from its first byte
+func startsDownload(r *http.Request) bool {
+	byteRange := r.Header.Get("Range")
+	return byteRange == "" || strings.HasPrefix(strings.TrimSpace(byteRange), "bytes=0-")
+}
+
+// downloadSize returns the size of the whole file served, 0 when unknown
+func downloadSize(counter *utils.ResponseCounter) int64 {
+	header := counter.Header().Get("Content-Length")
+	if counter.Status == http.StatusPartialContent {
+		// Content-Range: bytes 0-1023/146515
+		contentRange := counter.Header().Get("Content-Range")
+		header = contentRange[strings.LastIndex(contentRange, "/")+1:]
+	}
+	size, err := strconv.ParseInt(header, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return size
 }
 
 // FilteringHandler handles filtering games collection
@@ -227,22 +433,24 @@ func (s *TinShop) FilteringHandler(w http.ResponseWriter, r *http.Request) {
 	serveCollection(w, s.Shop.Collection.Filter(vars["filter"]))
 }
 
-// APIHandler handles api calls
-func (s *TinShop) APIHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-
-	if vars["endpoint"] == "stats" {
-		summary, err := s.Shop.Stats.Summary()
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			log.Println(err)
-			return
-		}
-		s.Shop.API.Stats(w, summary)
+// StatsHandler returns the statistics summary with the most downloaded titles
+func (s *TinShop) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	top := 10
+	if value, errTop := strconv.Atoi(r.URL.Query().Get("top")); errTop == nil && value > 0 {
+		top = value
+	}
+	summary, err := s.Shop.Stats.Summary(top)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Println(err)
 		return
 	}
-	// Everything not existing
-	w.WriteHeader(http.StatusBadRequest)
+	for i, device := range summary.Devices {
+		if name := s.deviceName(device.ID); name != device.ID {
+			summary.Devices[i].Name = name
+		}
+	}
+	s.Shop.API.Stats(w, summary)
 }
 
 // StatsMiddleware is a middleware to collect statistics
@@ -257,7 +465,7 @@ func (s *TinShop) StatsMiddleware(next http.Handler) http.Handler {
 				Language: r.Header.Get("Language"),
 			}
 			_ = s.Shop.Stats.ListVisit(console)
-		} else if r.RequestURI[0:7] == "/games/" {
+		} else if strings.HasPrefix(r.RequestURI, "/games/") {
 			vars := mux.Vars(r)
 			if s.Shop.Sources.HasGame(vars["game"]) {
 				_ = s.Shop.Stats.DownloadAsked(utils.GetIPFromRequest(r), vars["game"])
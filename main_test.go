@@ -116,7 +116,8 @@ var _ = Describe("Main", func() {
 				Expect(list.Files).To(HaveLen(1))
 				Expect(list.ThemeBlackList).To(BeNil())
 				Expect(list.Success).To(Equal("Welcome to your own shop!"))
-				Expect(list.Titledb).To(HaveLen(1))
+				// GameType.Titledb is tagged json:"-", the titledb is never sent to tinfoil
+				Expect(list.Titledb).To(BeEmpty())
 			})
 		})
 	})
@@ -264,7 +265,8 @@ var _ = Describe("Main", func() {
 					Expect(list.Files).To(HaveLen(1))
 					Expect(list.ThemeBlackList).To(BeNil())
 					Expect(list.Success).To(Equal("Welcome to your own shop!"))
-					Expect(list.Titledb).To(HaveLen(1))
+					// GameType.Titledb is tagged json:"-", the titledb is never sent to tinfoil
+					Expect(list.Titledb).To(BeEmpty())
 				},
 					Entry("with path 'world'", "world", true),
 					Entry("with path 'world/'", "world/", true),
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ajmandourah/tinshop-ng/library"
+	"github.com/ajmandourah/tinshop-ng/metrics"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/utils"
+	"github.com/gorilla/mux"
+)
+
+// MetricsMiddleware counts the requests by route and status code
+func (s *TinShop) MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		counter := utils.NewResponseCounter(w)
+		next.ServeHTTP(counter, r)
+
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		status := counter.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.HTTPRequests.WithLabelValues(route, strconv.Itoa(status)).Inc()
+	})
+}
+
+// MetricsHandler writes the metrics in the Prometheus text format
+func (s *TinShop) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if token := s.Shop.Config.MetricsToken(); token != "" {
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			countAuthFailure("metrics_token")
+			w.Header().Set("WWW-Authenticate", "Bearer realm=\"TinShop Metrics\"")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := metrics.Default.Write(w); err != nil {
+		log.Println("[Metrics] Unable to write metrics", err)
+	}
+}
+
+// registerLibraryMetrics exposes the size of the library by content type
+func registerLibraryMetrics(sources repository.Sources) {
+	sizes := func(value func(repository.FileDesc) float64) func() []metrics.Sample {
+		return func() []metrics.Sample {
+			totals := map[string]float64{repository.ContentBase: 0, repository.ContentUpdate: 0, repository.ContentDLC: 0}
+			for _, file := range sources.GetFiles() {
+				totals[library.ContentType(file.GameID)] += value(file)
+			}
+			samples := make([]metrics.Sample, 0, len(totals))
+			for kind, total := range totals {
+				samples = append(samples, metrics.Sample{LabelValues: []string{kind}, Value: total})
+			}
+			return samples
+		}
+	}
+
+	metrics.NewGaugeFunc("tinshop_library_files", "Files in the library by content type.",
+		sizes(func(repository.FileDesc) float64 { return 1 }), "type")
+	metrics.NewGaugeFunc("tinshop_library_bytes", "Size of the library by content type.",
+		sizes(func(file repository.FileDesc) float64 { return float64(file.Size) }), "type")
+}
@@ -0,0 +1,306 @@
+// @title tinshop Metrics
+
+// @BasePath /metrics/
+
+// Package metrics provides counters and gauges exposed in the Prometheus text format
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collector is a metric family written in the exposition
+type Collector interface {
+	Name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds all metric families
+type Registry struct {
+	mutex      sync.RWMutex
+	collectors map[string]Collector
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Register adds the collector, replacing the one with the same name
+func (r *Registry) Register(c Collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.collectors[c.Name()] = c
+}
+
+// Write writes all metrics in the Prometheus text format
+func (r *Registry) Write(w io.Writer) error {
+	r.mutex.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	collectors := r.collectors
+	r.mutex.RUnlock()
+	sort.Strings(names)
+
+	buf := bufio.NewWriter(w)
+	for _, name := range names {
+		r.mutex.RLock()
+		c := collectors[name]
+		r.mutex.RUnlock()
+		c.write(buf)
+	}
+	return buf.Flush()
+}
+
+type family struct {
+	name       string
+	help       string
+	metricType string
+	labels     []string
+
+	mutex  sync.Mutex
+	values map[string]*value
+}
+
+type value struct {
+	labelValues []string
+	mutex       sync.Mutex
+	v           float64
+}
+
+func newFamily(name, help, metricType string, labels []string) *family {
+	return &family{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labels:     labels,
+		values:     make(map[string]*value),
+	}
+}
+
+// Name returns the name of the metric family
+func (f *family) Name() string {
+	return f.name
+}
+
+func (f *family) with(labelValues []string) *value {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s expects %d labels, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	v, ok := f.values[key]
+	if !ok {
+		v = &value{labelValues: append([]string{}, labelValues...)}
+		f.values[key] = v
+	}
+	return v
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mutex.Lock()
+	values := make([]*value, 0, len(f.values))
+	for _, v := range f.values {
+		values = append(values, v)
+	}
+	f.mutex.Unlock()
+	sort.Slice(values, func(i, j int) bool {
+		return lessLabels(values[i].labelValues, values[j].labelValues)
+	})
+
+	writeHeader(w, f.name, f.help, f.metricType)
+	for _, v := range values {
+		v.mutex.Lock()
+		current := v.v
+		v.mutex.Unlock()
+		writeSample(w, f.name, f.labels, v.labelValues, current)
+	}
+}
+
+func (v *value) add(delta float64) {
+	v.mutex.Lock()
+	v.v += delta
+	v.mutex.Unlock()
+}
+
+func (v *value) set(newValue float64) {
+	v.mutex.Lock()
+	v.v = newValue
+	v.mutex.Unlock()
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	*family
+}
+
+// Counter only goes up
+type Counter struct {
+	v *value
+}
+
+// NewCounterVec returns a new counter registered in the default registry
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newFamily(name, help, "counter", labels)}
+	Default.Register(c)
+	return c
+}
+
+// WithLabelValues returns the counter of the label values
+func (c *CounterVec) WithLabelValues(labelValues ...string) Counter {
+	return Counter{c.with(labelValues)}
+}
+
+// Inc adds one to the counter
+func (c Counter) Inc() {
+	c.v.add(1)
+}
+
+// Add adds a positive value to the counter
+func (c Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.v.add(delta)
+}
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct {
+	*family
+}
+
+// Gauge can go up and down
+type Gauge struct {
+	v *value
+}
+
+// NewGaugeVec returns a new gauge registered in the default registry
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newFamily(name, help, "gauge", labels)}
+	Default.Register(g)
+	return g
+}
+
+// WithLabelValues returns the gauge of the label values
+func (g *GaugeVec) WithLabelValues(labelValues ...string) Gauge {
+	return Gauge{g.with(labelValues)}
+}
+
+// Set sets the gauge
+func (g Gauge) Set(value float64) {
+	g.v.set(value)
+}
+
+// Inc adds one to the gauge
+func (g Gauge) Inc() {
+	g.v.add(1)
+}
+
+// Dec removes one from the gauge
+func (g Gauge) Dec() {
+	g.v.add(-1)
+}
+
+// GaugeFunc is a gauge computed when scraped
+type GaugeFunc struct {
+	name   string
+	help   string
+	labels []string
+	fn     func() []Sample
+}
+
+// Sample is a value with its label values
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// NewGaugeFunc returns a new gauge computed by fn, registered in the default registry
+func NewGaugeFunc(name, help string, fn func() []Sample, labels ...string) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, labels: labels, fn: fn}
+	Default.Register(g)
+	return g
+}
+
+// Name returns the name of the metric family
+func (g *GaugeFunc) Name() string {
+	return g.name
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	samples := g.fn()
+	sort.Slice(samples, func(i, j int) bool {
+		return lessLabels(samples[i].LabelValues, samples[j].LabelValues)
+	})
+
+	writeHeader(w, g.name, g.help, "gauge")
+	for _, sample := range samples {
+		if len(sample.LabelValues) != len(g.labels) {
+			continue
+		}
+		writeSample(w, g.name, g.labels, sample.LabelValues, sample.Value)
+	}
+}
+
+// lessLabels orders label values one by one
+func lessLabels(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+func writeHeader(w *bufio.Writer, name, help, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+func writeSample(w *bufio.Writer, name string, labels []string, labelValues []string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabel(labelValues[i]))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,84 @@
+package metrics_test
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/metrics"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	var registry *metrics.Registry
+
+	BeforeEach(func() {
+		registry = metrics.NewRegistry()
+	})
+
+	write := func() string {
+		var buf bytes.Buffer
+		Expect(registry.Write(&buf)).To(Succeed())
+		return buf.String()
+	}
+
+	It("writes counters sorted by labels", func() {
+		counter := metrics.NewCounterVec("test_requests_total", "Requests.", "route", "status")
+		registry.Register(counter)
+		counter.WithLabelValues("/games/{game}", "200").Add(2)
+		counter.WithLabelValues("/", "200").Inc()
+		counter.WithLabelValues("/", "200").Add(-5)
+
+		Expect(write()).To(Equal("# HELP test_requests_total Requests.\n" +
+			"# TYPE test_requests_total counter\n" +
+			"test_requests_total{route=\"/\",status=\"200\"} 1\n" +
+			"test_requests_total{route=\"/games/{game}\",status=\"200\"} 2\n"))
+	})
+	It("writes gauges without labels", func() {
+		gauge := metrics.NewGaugeVec("test_in_flight", "In flight.")
+		registry.Register(gauge)
+		gauge.WithLabelValues().Inc()
+		gauge.WithLabelValues().Inc()
+		gauge.WithLabelValues().Dec()
+
+		Expect(write()).To(ContainSubstring("# TYPE test_in_flight gauge\ntest_in_flight 1\n"))
+	})
+	It("escapes label values", func() {
+		counter := metrics.NewCounterVec("test_escape_total", "Escape.", "reason")
+		registry.Register(counter)
+		counter.WithLabelValues("a\"b\\c\nd").Inc()
+
+		Expect(write()).To(ContainSubstring(`test_escape_total{reason="a\"b\\c\nd"} 1`))
+	})
+	It("computes gauge functions when written", func() {
+		value := 1.0
+		registry.Register(metrics.NewGaugeFunc("test_library_files", "Files.", func() []metrics.Sample {
+			return []metrics.Sample{
+				{LabelValues: []string{"update"}, Value: value},
+				{LabelValues: []string{"base"}, Value: value * 2},
+				{LabelValues: []string{"too", "many"}, Value: 3},
+			}
+		}, "type"))
+		value = 5
+
+		Expect(write()).To(HaveSuffix("test_library_files{type=\"base\"} 10\ntest_library_files{type=\"update\"} 5\n"))
+	})
+	It("writes families sorted by name", func() {
+		registry.Register(metrics.NewGaugeVec("test_b", "B."))
+		registry.Register(metrics.NewGaugeVec("test_a", "A."))
+
+		Expect(write()).To(Equal("# HELP test_a A.\n# TYPE test_a gauge\n# HELP test_b B.\n# TYPE test_b gauge\n"))
+	})
+	It("panics on wrong label count", func() {
+		counter := metrics.NewCounterVec("test_labels_total", "Labels.", "route")
+		Expect(func() { counter.WithLabelValues() }).To(Panic())
+	})
+	It("exposes the titledb age", func() {
+		metrics.SetTitleDBTime(time.Now().Add(-time.Hour))
+
+		var buf bytes.Buffer
+		Expect(metrics.Default.Write(&buf)).To(Succeed())
+		Expect(buf.String()).To(MatchRegexp(`tinshop_titledb_age_seconds 360[0-9]\n`))
+		Expect(buf.String()).To(ContainSubstring("# TYPE tinshop_auth_failures_total counter"))
+	})
+})
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Default is the registry exposed on /metrics
+var Default = NewRegistry() //nolint:gochecknoglobals
+
+// Metrics of the shop
+var (
+	// HTTPRequests counts the requests by route and status
+	HTTPRequests = NewCounterVec("tinshop_http_requests_total", "Requests served by route and status code.", "route", "status") //nolint:gochecknoglobals
+	// DownloadsInFlight counts the downloads currently served
+	DownloadsInFlight = NewGaugeVec("tinshop_downloads_in_flight", "Downloads currently being served.") //nolint:gochecknoglobals
+	// BytesServed counts the bytes of games sent by source type
+	BytesServed = NewCounterVec("tinshop_bytes_served_total", "Bytes of games sent by source type.", "source") //nolint:gochecknoglobals
+	// ScanDuration is the duration of the last scan of a source type
+	ScanDuration = NewGaugeVec("tinshop_scan_duration_seconds", "Duration of the last library scan by source type.", "source") //nolint:gochecknoglobals
+	// DecryptionFailures counts the files whose metadata could not be decrypted
+	DecryptionFailures = NewCounterVec("tinshop_decryption_failures_total", "Files whose metadata could not be decrypted.") //nolint:gochecknoglobals
+	// AuthFailures counts the refused requests by reason
+	AuthFailures = NewCounterVec("tinshop_auth_failures_total", "Requests refused by the security checks by reason.", "reason") //nolint:gochecknoglobals
+
+	titleDBTime int64                                                                                 //nolint:gochecknoglobals
+	_           = NewGaugeFunc("tinshop_titledb_age_seconds", "Age of the titledb file.", titleDBAge) //nolint:gochecknoglobals
+)
+
+// SetTitleDBTime records the modification time of the titledb file
+func SetTitleDBTime(modTime time.Time) {
+	atomic.StoreInt64(&titleDBTime, modTime.Unix())
+}
+
+func titleDBAge() []Sample {
+	modTime := atomic.LoadInt64(&titleDBTime)
+	if modTime == 0 {
+		return nil
+	}
+	return []Sample{{Value: float64(time.Now().Unix() - modTime)}}
+}
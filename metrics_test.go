@@ -0,0 +1,65 @@
+package main_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	main "github.com/ajmandourah/tinshop-ng"
+	"github.com/ajmandourah/tinshop-ng/mock_repository"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	var (
+		handler      http.Handler
+		writer       *httptest.ResponseRecorder
+		myMockConfig *mock_repository.MockConfig
+		myShop       *main.TinShop
+	)
+
+	BeforeEach(func() {
+		ctrl := gomock.NewController(GinkgoT())
+		myMockConfig = mock_repository.NewMockConfig(ctrl)
+		myShop = &main.TinShop{}
+		myShop.Shop = repository.Shop{Config: myMockConfig}
+
+		r := mux.NewRouter()
+		r.Use(myShop.MetricsMiddleware)
+		r.HandleFunc("/metrics", myShop.MetricsHandler)
+		handler = r
+		writer = httptest.NewRecorder()
+	})
+
+	It("serves metrics without token", func() {
+		myMockConfig.EXPECT().MetricsToken().Return("").AnyTimes()
+
+		handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		Expect(writer.Code).To(Equal(http.StatusOK))
+		Expect(writer.Header().Get("Content-Type")).To(HavePrefix("text/plain"))
+		Expect(writer.Body.String()).To(ContainSubstring(`tinshop_http_requests_total{route="/metrics",status="200"}`))
+	})
+	It("refuses a missing token", func() {
+		myMockConfig.EXPECT().MetricsToken().Return("secret").AnyTimes()
+
+		handler.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		Expect(writer.Code).To(Equal(http.StatusUnauthorized))
+	})
+	It("serves metrics with the token", func() {
+		myMockConfig.EXPECT().MetricsToken().Return("secret").AnyTimes()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+
+		handler.ServeHTTP(writer, req)
+
+		Expect(writer.Code).To(Equal(http.StatusOK))
+		Expect(writer.Body.String()).To(ContainSubstring(`tinshop_auth_failures_total{reason="metrics_token"}`))
+	})
+})
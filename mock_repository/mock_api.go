@@ -35,6 +35,150 @@ func (m *MockAPI) EXPECT() *MockAPIMockRecorder {
 	return m.recorder
 }
 
+// Audit mocks base method.
+func (m *MockAPI) Audit(arg0 http.ResponseWriter, arg1 []repository.AuditEvent) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Audit", arg0, arg1)
+}
+
+// Audit indicates an expected call of Audit.
+func (mr *MockAPIMockRecorder) Audit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Audit", reflect.TypeOf((*MockAPI)(nil).Audit), arg0, arg1)
+}
+
+// ConfigProblems mocks base method.
+func (m *MockAPI) ConfigProblems(arg0 http.ResponseWriter, arg1 []repository.ConfigProblem) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ConfigProblems", arg0, arg1)
+}
+
+// ConfigProblems indicates an expected call of ConfigProblems.
+func (mr *MockAPIMockRecorder) ConfigProblems(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfigProblems", reflect.TypeOf((*MockAPI)(nil).ConfigProblems), arg0, arg1)
+}
+
+// Device mocks base method.
+func (m *MockAPI) Device(arg0 http.ResponseWriter, arg1 repository.Device) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Device", arg0, arg1)
+}
+
+// Device indicates an expected call of Device.
+func (mr *MockAPIMockRecorder) Device(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Device", reflect.TypeOf((*MockAPI)(nil).Device), arg0, arg1)
+}
+
+// Devices mocks base method.
+func (m *MockAPI) Devices(arg0 http.ResponseWriter, arg1 []repository.Device) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Devices", arg0, arg1)
+}
+
+// Devices indicates an expected call of Devices.
+func (mr *MockAPIMockRecorder) Devices(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Devices", reflect.TypeOf((*MockAPI)(nil).Devices), arg0, arg1)
+}
+
+// FailedFiles mocks base method.
+func (m *MockAPI) FailedFiles(arg0 http.ResponseWriter, arg1 []repository.FailedFile) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "FailedFiles", arg0, arg1)
+}
+
+// FailedFiles indicates an expected call of FailedFiles.
+func (mr *MockAPIMockRecorder) FailedFiles(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailedFiles", reflect.TypeOf((*MockAPI)(nil).FailedFiles), arg0, arg1)
+}
+
+// Files mocks base method.
+func (m *MockAPI) Files(arg0 http.ResponseWriter, arg1 repository.FilesPage) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Files", arg0, arg1)
+}
+
+// Files indicates an expected call of Files.
+func (mr *MockAPIMockRecorder) Files(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Files", reflect.TypeOf((*MockAPI)(nil).Files), arg0, arg1)
+}
+
+// Health mocks base method.
+func (m *MockAPI) Health(arg0 http.ResponseWriter, arg1 repository.Health) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Health", arg0, arg1)
+}
+
+// Health indicates an expected call of Health.
+func (mr *MockAPIMockRecorder) Health(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Health", reflect.TypeOf((*MockAPI)(nil).Health), arg0, arg1)
+}
+
+// Missing mocks base method.
+func (m *MockAPI) Missing(arg0 http.ResponseWriter, arg1 []repository.MissingContent) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Missing", arg0, arg1)
+}
+
+// Missing indicates an expected call of Missing.
+func (mr *MockAPIMockRecorder) Missing(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Missing", reflect.TypeOf((*MockAPI)(nil).Missing), arg0, arg1)
+}
+
+// NewToken mocks base method.
+func (m *MockAPI) NewToken(arg0 http.ResponseWriter, arg1 repository.APIToken, arg2 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "NewToken", arg0, arg1, arg2)
+}
+
+// NewToken indicates an expected call of NewToken.
+func (mr *MockAPIMockRecorder) NewToken(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewToken", reflect.TypeOf((*MockAPI)(nil).NewToken), arg0, arg1, arg2)
+}
+
+// OpenAPI mocks base method.
+func (m *MockAPI) OpenAPI(arg0 http.ResponseWriter, arg1 []repository.APIRoute) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OpenAPI", arg0, arg1)
+}
+
+// OpenAPI indicates an expected call of OpenAPI.
+func (mr *MockAPIMockRecorder) OpenAPI(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenAPI", reflect.TypeOf((*MockAPI)(nil).OpenAPI), arg0, arg1)
+}
+
+// Scan mocks base method.
+func (m *MockAPI) Scan(arg0 http.ResponseWriter, arg1 repository.ScanStatus) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Scan", arg0, arg1)
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *MockAPIMockRecorder) Scan(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockAPI)(nil).Scan), arg0, arg1)
+}
+
+// Series mocks base method.
+func (m *MockAPI) Series(arg0 http.ResponseWriter, arg1 []repository.SeriesPoint) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Series", arg0, arg1)
+}
+
+// Series indicates an expected call of Series.
+func (mr *MockAPIMockRecorder) Series(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Series", reflect.TypeOf((*MockAPI)(nil).Series), arg0, arg1)
+}
+
 // Stats mocks base method.
 func (m *MockAPI) Stats(arg0 http.ResponseWriter, arg1 repository.StatsSummary) {
 	m.ctrl.T.Helper()
@@ -46,3 +190,75 @@ func (mr *MockAPIMockRecorder) Stats(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockAPI)(nil).Stats), arg0, arg1)
 }
+
+// StatsExport mocks base method.
+func (m *MockAPI) StatsExport(arg0 http.ResponseWriter, arg1 repository.StatsExport) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "StatsExport", arg0, arg1)
+}
+
+// StatsExport indicates an expected call of StatsExport.
+func (mr *MockAPIMockRecorder) StatsExport(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StatsExport", reflect.TypeOf((*MockAPI)(nil).StatsExport), arg0, arg1)
+}
+
+// Title mocks base method.
+func (m *MockAPI) Title(arg0 http.ResponseWriter, arg1 repository.LibraryTitle) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Title", arg0, arg1)
+}
+
+// Title indicates an expected call of Title.
+func (mr *MockAPIMockRecorder) Title(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Title", reflect.TypeOf((*MockAPI)(nil).Title), arg0, arg1)
+}
+
+// Titles mocks base method.
+func (m *MockAPI) Titles(arg0 http.ResponseWriter, arg1 repository.TitlesPage) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Titles", arg0, arg1)
+}
+
+// Titles indicates an expected call of Titles.
+func (mr *MockAPIMockRecorder) Titles(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Titles", reflect.TypeOf((*MockAPI)(nil).Titles), arg0, arg1)
+}
+
+// Tokens mocks base method.
+func (m *MockAPI) Tokens(arg0 http.ResponseWriter, arg1 []repository.APIToken) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Tokens", arg0, arg1)
+}
+
+// Tokens indicates an expected call of Tokens.
+func (mr *MockAPIMockRecorder) Tokens(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Tokens", reflect.TypeOf((*MockAPI)(nil).Tokens), arg0, arg1)
+}
+
+// User mocks base method.
+func (m *MockAPI) User(arg0 http.ResponseWriter, arg1 repository.User) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "User", arg0, arg1)
+}
+
+// User indicates an expected call of User.
+func (mr *MockAPIMockRecorder) User(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "User", reflect.TypeOf((*MockAPI)(nil).User), arg0, arg1)
+}
+
+// Users mocks base method.
+func (m *MockAPI) Users(arg0 http.ResponseWriter, arg1 []repository.User) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Users", arg0, arg1)
+}
+
+// Users indicates an expected call of Users.
+func (mr *MockAPIMockRecorder) Users(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Users", reflect.TypeOf((*MockAPI)(nil).Users), arg0, arg1)
+}
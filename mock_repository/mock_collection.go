@@ -88,6 +88,21 @@ func (mr *MockCollectionMockRecorder) Games() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Games", reflect.TypeOf((*MockCollection)(nil).Games))
 }
 
+// GenTitle mocks base method.
+func (m *MockCollection) GenTitle(arg0 string) (string, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GenTitle", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// GenTitle indicates an expected call of GenTitle.
+func (mr *MockCollectionMockRecorder) GenTitle(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenTitle", reflect.TypeOf((*MockCollection)(nil).GenTitle), arg0)
+}
+
 // GetKey mocks base method.
 func (m *MockCollection) GetKey(arg0 string) (string, error) {
 	m.ctrl.T.Helper()
@@ -181,6 +196,18 @@ func (mr *MockCollectionMockRecorder) RemoveGame(arg0 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveGame", reflect.TypeOf((*MockCollection)(nil).RemoveGame), arg0)
 }
 
+// ReplaceGames mocks base method.
+func (m *MockCollection) ReplaceGames(arg0 []repository.FileDesc) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ReplaceGames", arg0)
+}
+
+// ReplaceGames indicates an expected call of ReplaceGames.
+func (mr *MockCollectionMockRecorder) ReplaceGames(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceGames", reflect.TypeOf((*MockCollection)(nil).ReplaceGames), arg0)
+}
+
 // ResetGamesCollection mocks base method.
 func (m *MockCollection) ResetGamesCollection() {
 	m.ctrl.T.Helper()
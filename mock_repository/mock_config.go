@@ -58,6 +58,48 @@ func (mr *MockConfigMockRecorder) AddHook(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddHook", reflect.TypeOf((*MockConfig)(nil).AddHook), arg0)
 }
 
+// AdminAllowedOrigins mocks base method.
+func (m *MockConfig) AdminAllowedOrigins() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminAllowedOrigins")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// AdminAllowedOrigins indicates an expected call of AdminAllowedOrigins.
+func (mr *MockConfigMockRecorder) AdminAllowedOrigins() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminAllowedOrigins", reflect.TypeOf((*MockConfig)(nil).AdminAllowedOrigins))
+}
+
+// AuditMaxBackups mocks base method.
+func (m *MockConfig) AuditMaxBackups() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuditMaxBackups")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// AuditMaxBackups indicates an expected call of AuditMaxBackups.
+func (mr *MockConfigMockRecorder) AuditMaxBackups() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuditMaxBackups", reflect.TypeOf((*MockConfig)(nil).AuditMaxBackups))
+}
+
+// AuditMaxSize mocks base method.
+func (m *MockConfig) AuditMaxSize() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuditMaxSize")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// AuditMaxSize indicates an expected call of AuditMaxSize.
+func (mr *MockConfigMockRecorder) AuditMaxSize() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuditMaxSize", reflect.TypeOf((*MockConfig)(nil).AuditMaxSize))
+}
+
 // BannedTheme mocks base method.
 func (m *MockConfig) BannedTheme() []string {
 	m.ctrl.T.Helper()
@@ -72,6 +114,34 @@ func (mr *MockConfigMockRecorder) BannedTheme() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BannedTheme", reflect.TypeOf((*MockConfig)(nil).BannedTheme))
 }
 
+// CatalogEnabled mocks base method.
+func (m *MockConfig) CatalogEnabled() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CatalogEnabled")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CatalogEnabled indicates an expected call of CatalogEnabled.
+func (mr *MockConfigMockRecorder) CatalogEnabled() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CatalogEnabled", reflect.TypeOf((*MockConfig)(nil).CatalogEnabled))
+}
+
+// CatalogPath mocks base method.
+func (m *MockConfig) CatalogPath() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CatalogPath")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// CatalogPath indicates an expected call of CatalogPath.
+func (mr *MockConfigMockRecorder) CatalogPath() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CatalogPath", reflect.TypeOf((*MockConfig)(nil).CatalogPath))
+}
+
 // CustomDB mocks base method.
 func (m *MockConfig) CustomDB() map[string]repository.TitleDBEntry {
 	m.ctrl.T.Helper()
@@ -86,6 +156,34 @@ func (mr *MockConfigMockRecorder) CustomDB() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CustomDB", reflect.TypeOf((*MockConfig)(nil).CustomDB))
 }
 
+// DataDir mocks base method.
+func (m *MockConfig) DataDir() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DataDir")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// DataDir indicates an expected call of DataDir.
+func (mr *MockConfigMockRecorder) DataDir() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DataDir", reflect.TypeOf((*MockConfig)(nil).DataDir))
+}
+
+// DataPath mocks base method.
+func (m *MockConfig) DataPath(arg0 string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DataPath", arg0)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// DataPath indicates an expected call of DataPath.
+func (mr *MockConfigMockRecorder) DataPath(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DataPath", reflect.TypeOf((*MockConfig)(nil).DataPath), arg0)
+}
+
 // DebugNfs mocks base method.
 func (m *MockConfig) DebugNfs() bool {
 	m.ctrl.T.Helper()
@@ -156,6 +254,62 @@ func (mr *MockConfigMockRecorder) ForwardAuthURL() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForwardAuthURL", reflect.TypeOf((*MockConfig)(nil).ForwardAuthURL))
 }
 
+// GeoIPDatabase mocks base method.
+func (m *MockConfig) GeoIPDatabase() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GeoIPDatabase")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// GeoIPDatabase indicates an expected call of GeoIPDatabase.
+func (mr *MockConfigMockRecorder) GeoIPDatabase() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GeoIPDatabase", reflect.TypeOf((*MockConfig)(nil).GeoIPDatabase))
+}
+
+// Get_Hauth mocks base method.
+func (m *MockConfig) Get_Hauth() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get_Hauth")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Get_Hauth indicates an expected call of Get_Hauth.
+func (mr *MockConfigMockRecorder) Get_Hauth() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get_Hauth", reflect.TypeOf((*MockConfig)(nil).Get_Hauth))
+}
+
+// Get_Httpauth mocks base method.
+func (m *MockConfig) Get_Httpauth() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get_Httpauth")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// Get_Httpauth indicates an expected call of Get_Httpauth.
+func (mr *MockConfigMockRecorder) Get_Httpauth() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get_Httpauth", reflect.TypeOf((*MockConfig)(nil).Get_Httpauth))
+}
+
+// HasUauth mocks base method.
+func (m *MockConfig) HasUauth(arg0 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasUauth", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// HasUauth indicates an expected call of HasUauth.
+func (mr *MockConfigMockRecorder) HasUauth(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasUauth", reflect.TypeOf((*MockConfig)(nil).HasUauth), arg0)
+}
+
 // Host mocks base method.
 func (m *MockConfig) Host() string {
 	m.ctrl.T.Helper()
@@ -170,6 +324,34 @@ func (mr *MockConfigMockRecorder) Host() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Host", reflect.TypeOf((*MockConfig)(nil).Host))
 }
 
+// IPFilterDownload mocks base method.
+func (m *MockConfig) IPFilterDownload() repository.IPPolicy {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IPFilterDownload")
+	ret0, _ := ret[0].(repository.IPPolicy)
+	return ret0
+}
+
+// IPFilterDownload indicates an expected call of IPFilterDownload.
+func (mr *MockConfigMockRecorder) IPFilterDownload() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IPFilterDownload", reflect.TypeOf((*MockConfig)(nil).IPFilterDownload))
+}
+
+// IPFilterIndex mocks base method.
+func (m *MockConfig) IPFilterIndex() repository.IPPolicy {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IPFilterIndex")
+	ret0, _ := ret[0].(repository.IPPolicy)
+	return ret0
+}
+
+// IPFilterIndex indicates an expected call of IPFilterIndex.
+func (mr *MockConfigMockRecorder) IPFilterIndex() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IPFilterIndex", reflect.TypeOf((*MockConfig)(nil).IPFilterIndex))
+}
+
 // IsBannedTheme mocks base method.
 func (m *MockConfig) IsBannedTheme(arg0 string) bool {
 	m.ctrl.T.Helper()
@@ -198,6 +380,34 @@ func (mr *MockConfigMockRecorder) IsBlacklisted(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsBlacklisted", reflect.TypeOf((*MockConfig)(nil).IsBlacklisted), arg0)
 }
 
+// IsValidHostUauth mocks base method.
+func (m *MockConfig) IsValidHostUauth(arg0, arg1 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsValidHostUauth", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsValidHostUauth indicates an expected call of IsValidHostUauth.
+func (mr *MockConfigMockRecorder) IsValidHostUauth(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsValidHostUauth", reflect.TypeOf((*MockConfig)(nil).IsValidHostUauth), arg0, arg1)
+}
+
+// IsValidUauth mocks base method.
+func (m *MockConfig) IsValidUauth(arg0, arg1 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsValidUauth", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsValidUauth indicates an expected call of IsValidUauth.
+func (mr *MockConfigMockRecorder) IsValidUauth(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsValidUauth", reflect.TypeOf((*MockConfig)(nil).IsValidUauth), arg0, arg1)
+}
+
 // IsWhitelisted mocks base method.
 func (m *MockConfig) IsWhitelisted(arg0 string) bool {
 	m.ctrl.T.Helper()
@@ -213,9 +423,11 @@ func (mr *MockConfigMockRecorder) IsWhitelisted(arg0 interface{}) *gomock.Call {
 }
 
 // LoadConfig mocks base method.
-func (m *MockConfig) LoadConfig() {
+func (m *MockConfig) LoadConfig() error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "LoadConfig")
+	ret := m.ctrl.Call(m, "LoadConfig")
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // LoadConfig indicates an expected call of LoadConfig.
@@ -224,6 +436,20 @@ func (mr *MockConfigMockRecorder) LoadConfig() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadConfig", reflect.TypeOf((*MockConfig)(nil).LoadConfig))
 }
 
+// MetricsToken mocks base method.
+func (m *MockConfig) MetricsToken() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MetricsToken")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// MetricsToken indicates an expected call of MetricsToken.
+func (mr *MockConfigMockRecorder) MetricsToken() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MetricsToken", reflect.TypeOf((*MockConfig)(nil).MetricsToken))
+}
+
 // NfsShares mocks base method.
 func (m *MockConfig) NfsShares() []string {
 	m.ctrl.T.Helper()
@@ -266,6 +492,20 @@ func (mr *MockConfigMockRecorder) Port() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Port", reflect.TypeOf((*MockConfig)(nil).Port))
 }
 
+// ProdKeys mocks base method.
+func (m *MockConfig) ProdKeys() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProdKeys")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// ProdKeys indicates an expected call of ProdKeys.
+func (mr *MockConfigMockRecorder) ProdKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProdKeys", reflect.TypeOf((*MockConfig)(nil).ProdKeys))
+}
+
 // Protocol mocks base method.
 func (m *MockConfig) Protocol() string {
 	m.ctrl.T.Helper()
@@ -280,6 +520,48 @@ func (mr *MockConfigMockRecorder) Protocol() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Protocol", reflect.TypeOf((*MockConfig)(nil).Protocol))
 }
 
+// ReadConfig mocks base method.
+func (m *MockConfig) ReadConfig() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadConfig")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReadConfig indicates an expected call of ReadConfig.
+func (mr *MockConfigMockRecorder) ReadConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadConfig", reflect.TypeOf((*MockConfig)(nil).ReadConfig))
+}
+
+// Reload mocks base method.
+func (m *MockConfig) Reload() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reload")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reload indicates an expected call of Reload.
+func (mr *MockConfigMockRecorder) Reload() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reload", reflect.TypeOf((*MockConfig)(nil).Reload))
+}
+
+// Rename mocks base method.
+func (m *MockConfig) Rename() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rename")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Rename indicates an expected call of Rename.
+func (mr *MockConfigMockRecorder) Rename() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rename", reflect.TypeOf((*MockConfig)(nil).Rename))
+}
+
 // ReverseProxy mocks base method.
 func (m *MockConfig) ReverseProxy() bool {
 	m.ctrl.T.Helper()
@@ -374,6 +656,118 @@ func (mr *MockConfigMockRecorder) Sources() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sources", reflect.TypeOf((*MockConfig)(nil).Sources))
 }
 
+// StatsDailyRetention mocks base method.
+func (m *MockConfig) StatsDailyRetention() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StatsDailyRetention")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// StatsDailyRetention indicates an expected call of StatsDailyRetention.
+func (mr *MockConfigMockRecorder) StatsDailyRetention() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StatsDailyRetention", reflect.TypeOf((*MockConfig)(nil).StatsDailyRetention))
+}
+
+// StatsHourlyRetention mocks base method.
+func (m *MockConfig) StatsHourlyRetention() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StatsHourlyRetention")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// StatsHourlyRetention indicates an expected call of StatsHourlyRetention.
+func (mr *MockConfigMockRecorder) StatsHourlyRetention() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StatsHourlyRetention", reflect.TypeOf((*MockConfig)(nil).StatsHourlyRetention))
+}
+
+// StatsPath mocks base method.
+func (m *MockConfig) StatsPath() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StatsPath")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// StatsPath indicates an expected call of StatsPath.
+func (mr *MockConfigMockRecorder) StatsPath() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StatsPath", reflect.TypeOf((*MockConfig)(nil).StatsPath))
+}
+
+// TLSCertFile mocks base method.
+func (m *MockConfig) TLSCertFile() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TLSCertFile")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// TLSCertFile indicates an expected call of TLSCertFile.
+func (mr *MockConfigMockRecorder) TLSCertFile() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TLSCertFile", reflect.TypeOf((*MockConfig)(nil).TLSCertFile))
+}
+
+// TLSEnabled mocks base method.
+func (m *MockConfig) TLSEnabled() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TLSEnabled")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// TLSEnabled indicates an expected call of TLSEnabled.
+func (mr *MockConfigMockRecorder) TLSEnabled() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TLSEnabled", reflect.TypeOf((*MockConfig)(nil).TLSEnabled))
+}
+
+// TLSKeyFile mocks base method.
+func (m *MockConfig) TLSKeyFile() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TLSKeyFile")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// TLSKeyFile indicates an expected call of TLSKeyFile.
+func (mr *MockConfigMockRecorder) TLSKeyFile() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TLSKeyFile", reflect.TypeOf((*MockConfig)(nil).TLSKeyFile))
+}
+
+// TLSRedirectPort mocks base method.
+func (m *MockConfig) TLSRedirectPort() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TLSRedirectPort")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// TLSRedirectPort indicates an expected call of TLSRedirectPort.
+func (mr *MockConfigMockRecorder) TLSRedirectPort() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TLSRedirectPort", reflect.TypeOf((*MockConfig)(nil).TLSRedirectPort))
+}
+
+// TrustedProxies mocks base method.
+func (m *MockConfig) TrustedProxies() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TrustedProxies")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// TrustedProxies indicates an expected call of TrustedProxies.
+func (mr *MockConfigMockRecorder) TrustedProxies() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TrustedProxies", reflect.TypeOf((*MockConfig)(nil).TrustedProxies))
+}
+
 // VerifyNSP mocks base method.
 func (m *MockConfig) VerifyNSP() bool {
 	m.ctrl.T.Helper()
@@ -388,6 +782,20 @@ func (mr *MockConfigMockRecorder) VerifyNSP() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyNSP", reflect.TypeOf((*MockConfig)(nil).VerifyNSP))
 }
 
+// Webhooks mocks base method.
+func (m *MockConfig) Webhooks() []repository.Webhook {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Webhooks")
+	ret0, _ := ret[0].([]repository.Webhook)
+	return ret0
+}
+
+// Webhooks indicates an expected call of Webhooks.
+func (mr *MockConfigMockRecorder) Webhooks() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Webhooks", reflect.TypeOf((*MockConfig)(nil).Webhooks))
+}
+
 // WelcomeMessage mocks base method.
 func (m *MockConfig) WelcomeMessage() string {
 	m.ctrl.T.Helper()
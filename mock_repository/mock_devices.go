@@ -0,0 +1,159 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/ajmandourah/tinshop-ng/repository (interfaces: Devices)
+
+// Package mock_repository is a generated GoMock package.
+package mock_repository
+
+import (
+	reflect "reflect"
+
+	repository "github.com/ajmandourah/tinshop-ng/repository"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockDevices is a mock of Devices interface.
+type MockDevices struct {
+	ctrl     *gomock.Controller
+	recorder *MockDevicesMockRecorder
+}
+
+// MockDevicesMockRecorder is the mock recorder for MockDevices.
+type MockDevicesMockRecorder struct {
+	mock *MockDevices
+}
+
+// NewMockDevices creates a new mock instance.
+func NewMockDevices(ctrl *gomock.Controller) *MockDevices {
+	mock := &MockDevices{ctrl: ctrl}
+	mock.recorder = &MockDevicesMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDevices) EXPECT() *MockDevicesMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockDevices) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockDevicesMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockDevices)(nil).Close))
+}
+
+// Delete mocks base method.
+func (m *MockDevices) Delete(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockDevicesMockRecorder) Delete(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockDevices)(nil).Delete), arg0)
+}
+
+// DisplayName mocks base method.
+func (m *MockDevices) DisplayName(arg0 string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisplayName", arg0)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// DisplayName indicates an expected call of DisplayName.
+func (mr *MockDevicesMockRecorder) DisplayName(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisplayName", reflect.TypeOf((*MockDevices)(nil).DisplayName), arg0)
+}
+
+// Get mocks base method.
+func (m *MockDevices) Get(arg0 string) (repository.Device, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", arg0)
+	ret0, _ := ret[0].(repository.Device)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockDevicesMockRecorder) Get(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockDevices)(nil).Get), arg0)
+}
+
+// IsBlocked mocks base method.
+func (m *MockDevices) IsBlocked(arg0 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsBlocked", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsBlocked indicates an expected call of IsBlocked.
+func (mr *MockDevicesMockRecorder) IsBlocked(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsBlocked", reflect.TypeOf((*MockDevices)(nil).IsBlocked), arg0)
+}
+
+// List mocks base method.
+func (m *MockDevices) List() ([]repository.Device, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List")
+	ret0, _ := ret[0].([]repository.Device)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockDevicesMockRecorder) List() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockDevices)(nil).List))
+}
+
+// Load mocks base method.
+func (m *MockDevices) Load() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Load")
+}
+
+// Load indicates an expected call of Load.
+func (mr *MockDevicesMockRecorder) Load() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Load", reflect.TypeOf((*MockDevices)(nil).Load))
+}
+
+// Save mocks base method.
+func (m *MockDevices) Save(arg0 repository.Device) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockDevicesMockRecorder) Save(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockDevices)(nil).Save), arg0)
+}
+
+// Seen mocks base method.
+func (m *MockDevices) Seen(arg0 repository.Switch) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Seen", arg0)
+}
+
+// Seen indicates an expected call of Seen.
+func (mr *MockDevicesMockRecorder) Seen(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Seen", reflect.TypeOf((*MockDevices)(nil).Seen), arg0)
+}
@@ -0,0 +1,75 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/ajmandourah/tinshop-ng/repository (interfaces: IPFilter)
+
+// Package mock_repository is a generated GoMock package.
+package mock_repository
+
+import (
+	reflect "reflect"
+
+	repository "github.com/ajmandourah/tinshop-ng/repository"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockIPFilter is a mock of IPFilter interface.
+type MockIPFilter struct {
+	ctrl     *gomock.Controller
+	recorder *MockIPFilterMockRecorder
+}
+
+// MockIPFilterMockRecorder is the mock recorder for MockIPFilter.
+type MockIPFilterMockRecorder struct {
+	mock *MockIPFilter
+}
+
+// NewMockIPFilter creates a new mock instance.
+func NewMockIPFilter(ctrl *gomock.Controller) *MockIPFilter {
+	mock := &MockIPFilter{ctrl: ctrl}
+	mock.recorder = &MockIPFilterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIPFilter) EXPECT() *MockIPFilterMockRecorder {
+	return m.recorder
+}
+
+// AllowDownload mocks base method.
+func (m *MockIPFilter) AllowDownload(arg0 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllowDownload", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// AllowDownload indicates an expected call of AllowDownload.
+func (mr *MockIPFilterMockRecorder) AllowDownload(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllowDownload", reflect.TypeOf((*MockIPFilter)(nil).AllowDownload), arg0)
+}
+
+// AllowIndex mocks base method.
+func (m *MockIPFilter) AllowIndex(arg0 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllowIndex", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// AllowIndex indicates an expected call of AllowIndex.
+func (mr *MockIPFilterMockRecorder) AllowIndex(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllowIndex", reflect.TypeOf((*MockIPFilter)(nil).AllowIndex), arg0)
+}
+
+// OnConfigUpdate mocks base method.
+func (m *MockIPFilter) OnConfigUpdate(arg0 repository.Config) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnConfigUpdate", arg0)
+}
+
+// OnConfigUpdate indicates an expected call of OnConfigUpdate.
+func (mr *MockIPFilterMockRecorder) OnConfigUpdate(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnConfigUpdate", reflect.TypeOf((*MockIPFilter)(nil).OnConfigUpdate), arg0)
+}
@@ -47,6 +47,20 @@ func (mr *MockSourceMockRecorder) Download(arg0, arg1, arg2, arg3 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Download", reflect.TypeOf((*MockSource)(nil).Download), arg0, arg1, arg2, arg3)
 }
 
+// FailedFiles mocks base method.
+func (m *MockSource) FailedFiles() []repository.FailedFile {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailedFiles")
+	ret0, _ := ret[0].([]repository.FailedFile)
+	return ret0
+}
+
+// FailedFiles indicates an expected call of FailedFiles.
+func (mr *MockSourceMockRecorder) FailedFiles() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailedFiles", reflect.TypeOf((*MockSource)(nil).FailedFiles))
+}
+
 // GetFiles mocks base method.
 func (m *MockSource) GetFiles() []repository.FileDesc {
 	m.ctrl.T.Helper()
@@ -73,6 +87,30 @@ func (mr *MockSourceMockRecorder) Load(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Load", reflect.TypeOf((*MockSource)(nil).Load), arg0, arg1)
 }
 
+// RemovePath mocks base method.
+func (m *MockSource) RemovePath(arg0 string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RemovePath", arg0)
+}
+
+// RemovePath indicates an expected call of RemovePath.
+func (mr *MockSourceMockRecorder) RemovePath(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemovePath", reflect.TypeOf((*MockSource)(nil).RemovePath), arg0)
+}
+
+// ReplacePath mocks base method.
+func (m *MockSource) ReplacePath(arg0 string, arg1 repository.Source) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ReplacePath", arg0, arg1)
+}
+
+// ReplacePath indicates an expected call of ReplacePath.
+func (mr *MockSourceMockRecorder) ReplacePath(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplacePath", reflect.TypeOf((*MockSource)(nil).ReplacePath), arg0, arg1)
+}
+
 // Reset mocks base method.
 func (m *MockSource) Reset() {
 	m.ctrl.T.Helper()
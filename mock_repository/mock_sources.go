@@ -47,6 +47,20 @@ func (mr *MockSourcesMockRecorder) BeforeConfigUpdate(arg0 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeforeConfigUpdate", reflect.TypeOf((*MockSources)(nil).BeforeConfigUpdate), arg0)
 }
 
+// CancelScan mocks base method.
+func (m *MockSources) CancelScan() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelScan")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CancelScan indicates an expected call of CancelScan.
+func (mr *MockSourcesMockRecorder) CancelScan() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelScan", reflect.TypeOf((*MockSources)(nil).CancelScan))
+}
+
 // DownloadGame mocks base method.
 func (m *MockSources) DownloadGame(arg0 string, arg1 http.ResponseWriter, arg2 *http.Request) {
 	m.ctrl.T.Helper()
@@ -59,6 +73,20 @@ func (mr *MockSourcesMockRecorder) DownloadGame(arg0, arg1, arg2 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadGame", reflect.TypeOf((*MockSources)(nil).DownloadGame), arg0, arg1, arg2)
 }
 
+// FailedFiles mocks base method.
+func (m *MockSources) FailedFiles() []repository.FailedFile {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailedFiles")
+	ret0, _ := ret[0].([]repository.FailedFile)
+	return ret0
+}
+
+// FailedFiles indicates an expected call of FailedFiles.
+func (mr *MockSourcesMockRecorder) FailedFiles() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailedFiles", reflect.TypeOf((*MockSources)(nil).FailedFiles))
+}
+
 // GetFiles mocks base method.
 func (m *MockSources) GetFiles() []repository.FileDesc {
 	m.ctrl.T.Helper()
@@ -87,6 +115,20 @@ func (mr *MockSourcesMockRecorder) HasGame(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasGame", reflect.TypeOf((*MockSources)(nil).HasGame), arg0)
 }
 
+// Health mocks base method.
+func (m *MockSources) Health() []repository.HealthCheck {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Health")
+	ret0, _ := ret[0].([]repository.HealthCheck)
+	return ret0
+}
+
+// Health indicates an expected call of Health.
+func (mr *MockSourcesMockRecorder) Health() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Health", reflect.TypeOf((*MockSources)(nil).Health))
+}
+
 // OnConfigUpdate mocks base method.
 func (m *MockSources) OnConfigUpdate(arg0 repository.Config) {
 	m.ctrl.T.Helper()
@@ -98,3 +140,32 @@ func (mr *MockSourcesMockRecorder) OnConfigUpdate(arg0 interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnConfigUpdate", reflect.TypeOf((*MockSources)(nil).OnConfigUpdate), arg0)
 }
+
+// Rescan mocks base method.
+func (m *MockSources) Rescan(arg0, arg1 string) (repository.ScanStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rescan", arg0, arg1)
+	ret0, _ := ret[0].(repository.ScanStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rescan indicates an expected call of Rescan.
+func (mr *MockSourcesMockRecorder) Rescan(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rescan", reflect.TypeOf((*MockSources)(nil).Rescan), arg0, arg1)
+}
+
+// ScanStatus mocks base method.
+func (m *MockSources) ScanStatus() repository.ScanStatus {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScanStatus")
+	ret0, _ := ret[0].(repository.ScanStatus)
+	return ret0
+}
+
+// ScanStatus indicates an expected call of ScanStatus.
+func (mr *MockSourcesMockRecorder) ScanStatus() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScanStatus", reflect.TypeOf((*MockSources)(nil).ScanStatus))
+}
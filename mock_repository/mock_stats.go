@@ -62,6 +62,49 @@ func (mr *MockStatsMockRecorder) DownloadAsked(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadAsked", reflect.TypeOf((*MockStats)(nil).DownloadAsked), arg0, arg1)
 }
 
+// DownloadServed mocks base method.
+func (m *MockStats) DownloadServed(arg0 repository.Download) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DownloadServed", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DownloadServed indicates an expected call of DownloadServed.
+func (mr *MockStatsMockRecorder) DownloadServed(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DownloadServed", reflect.TypeOf((*MockStats)(nil).DownloadServed), arg0)
+}
+
+// Export mocks base method.
+func (m *MockStats) Export() (repository.StatsExport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export")
+	ret0, _ := ret[0].(repository.StatsExport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockStatsMockRecorder) Export() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockStats)(nil).Export))
+}
+
+// Import mocks base method.
+func (m *MockStats) Import(arg0 repository.StatsExport) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Import indicates an expected call of Import.
+func (mr *MockStatsMockRecorder) Import(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockStats)(nil).Import), arg0)
+}
+
 // ListVisit mocks base method.
 func (m *MockStats) ListVisit(arg0 *repository.Switch) error {
 	m.ctrl.T.Helper()
@@ -77,9 +120,11 @@ func (mr *MockStatsMockRecorder) ListVisit(arg0 interface{}) *gomock.Call {
 }
 
 // Load mocks base method.
-func (m *MockStats) Load() {
+func (m *MockStats) Load() error {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "Load")
+	ret := m.ctrl.Call(m, "Load")
+	ret0, _ := ret[0].(error)
+	return ret0
 }
 
 // Load indicates an expected call of Load.
@@ -88,17 +133,58 @@ func (mr *MockStatsMockRecorder) Load() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Load", reflect.TypeOf((*MockStats)(nil).Load))
 }
 
+// OnConfigUpdate mocks base method.
+func (m *MockStats) OnConfigUpdate(arg0 repository.Config) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnConfigUpdate", arg0)
+}
+
+// OnConfigUpdate indicates an expected call of OnConfigUpdate.
+func (mr *MockStatsMockRecorder) OnConfigUpdate(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnConfigUpdate", reflect.TypeOf((*MockStats)(nil).OnConfigUpdate), arg0)
+}
+
+// Ping mocks base method.
+func (m *MockStats) Ping() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockStatsMockRecorder) Ping() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStats)(nil).Ping))
+}
+
+// Series mocks base method.
+func (m *MockStats) Series(arg0 repository.SeriesQuery) ([]repository.SeriesPoint, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Series", arg0)
+	ret0, _ := ret[0].([]repository.SeriesPoint)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Series indicates an expected call of Series.
+func (mr *MockStatsMockRecorder) Series(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Series", reflect.TypeOf((*MockStats)(nil).Series), arg0)
+}
+
 // Summary mocks base method.
-func (m *MockStats) Summary() (repository.StatsSummary, error) {
+func (m *MockStats) Summary(arg0 int) (repository.StatsSummary, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Summary")
+	ret := m.ctrl.Call(m, "Summary", arg0)
 	ret0, _ := ret[0].(repository.StatsSummary)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Summary indicates an expected call of Summary.
-func (mr *MockStatsMockRecorder) Summary() *gomock.Call {
+func (mr *MockStatsMockRecorder) Summary(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Summary", reflect.TypeOf((*MockStats)(nil).Summary))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Summary", reflect.TypeOf((*MockStats)(nil).Summary), arg0)
 }
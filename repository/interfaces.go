@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // GameID interface
@@ -32,8 +33,14 @@ type Config interface {
 	Host() string
 	Protocol() string
 	ProdKeys() string
+	DataDir() string
+	DataPath(string) string
 	Rename() bool
 	Port() int
+	TLSEnabled() bool
+	TLSCertFile() string
+	TLSKeyFile() string
+	TLSRedirectPort() int
 	ReverseProxy() bool
 	WelcomeMessage() string
 	NoWelcomeMessage() bool
@@ -49,8 +56,26 @@ type Config interface {
 	ShopTemplateData() ShopTemplate
 	SetShopTemplateData(ShopTemplate)
 
+	AdminAllowedOrigins() []string
+	AuditMaxSize() int
+	AuditMaxBackups() int
+	StatsHourlyRetention() int
+	StatsDailyRetention() int
+	StatsPath() string
+	MetricsToken() string
+
 	ForwardAuthURL() string
+	TrustedProxies() []string
+	IPFilterIndex() IPPolicy
+	IPFilterDownload() IPPolicy
+	GeoIPDatabase() string
+	Webhooks() []Webhook
+	CatalogEnabled() bool
+	CatalogPath() string
 	Get_Hauth() string
+	IsValidUauth(string, string) bool
+	IsValidHostUauth(string, string) bool
+	HasUauth(string) bool
 	Get_Httpauth() []string
 	IsBlacklisted(string) bool
 	IsWhitelisted(string) bool
@@ -62,7 +87,9 @@ type Config interface {
 
 	AddHook(f func(Config))
 	AddBeforeHook(f func(Config))
-	LoadConfig()
+	LoadConfig() error
+	ReadConfig() error
+	Reload() error
 }
 
 // ShopTemplate contains all variables used for shop template
@@ -170,6 +197,16 @@ type Source interface {
 	UnWatchAll()
 	Reset()
 	GetFiles() []FileDesc
+	FailedFiles() []FailedFile
+	ReplacePath(string, Source)
+	RemovePath(string)
+}
+
+// FailedFile is a game file of a source which could not be identified or verified
+type FailedFile struct {
+	Path   string   `json:"path"`
+	Source HostType `json:"source"`
+	Reason string   `json:"reason"`
 }
 
 // Sources describes all function to handle all sources
@@ -177,8 +214,68 @@ type Sources interface {
 	OnConfigUpdate(Config)
 	BeforeConfigUpdate(Config)
 	GetFiles() []FileDesc
+	FailedFiles() []FailedFile
 	HasGame(string) bool
 	DownloadGame(string, http.ResponseWriter, *http.Request)
+	Rescan(string, string) (ScanStatus, error)
+	ScanStatus() ScanStatus
+	CancelScan() bool
+	Health() []HealthCheck
+}
+
+// States of a health check
+const (
+	HealthOK     = "ok"
+	HealthFailed = "failed"
+)
+
+// HealthCheck is the result of a check of the readiness of the shop
+type HealthCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Health is the state of the shop with the details of its checks
+type Health struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks,omitempty"`
+}
+
+// ScanTracker follows the files of a scan and tells when it has been cancelled
+type ScanTracker interface {
+	FileSeen()
+	FileIdentified()
+	FileFailed()
+	Cancelled() bool
+}
+
+// Targets of a library scan
+const (
+	ScanAll       = "all"
+	ScanDirectory = "directory"
+	ScanNFS       = "nfs"
+	ScanPath      = "path"
+)
+
+// States of a library scan
+const (
+	ScanRunning   = "running"
+	ScanFinished  = "finished"
+	ScanCancelled = "cancelled"
+)
+
+// ScanStatus holds the progress of a library scan
+type ScanStatus struct {
+	ID         int        `json:"id"`
+	State      string     `json:"state,omitempty"`
+	Target     string     `json:"target,omitempty"`
+	Path       string     `json:"path,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Seen       int64      `json:"seen"`
+	Identified int64      `json:"identified"`
+	Failed     int64      `json:"failed"`
 }
 
 // Collection describes all information about collection
@@ -189,6 +286,7 @@ type Collection interface {
 	RemoveGame(string)
 	CountGames() int
 	AddNewGames([]FileDesc)
+	ReplaceGames([]FileDesc)
 	Library() map[string]TitleDBEntry
 	HasGameIDInLibrary(string) bool
 	IsBaseGame(string) bool
@@ -198,6 +296,81 @@ type Collection interface {
 	GenTitle(string) (string, bool)
 }
 
+// Content types of a file of the library
+const (
+	ContentBase   = "base"
+	ContentUpdate = "update"
+	ContentDLC    = "dlc"
+)
+
+// LibraryFile is a file of the library with its titledb information
+type LibraryFile struct {
+	TitleID   string   `json:"titleId"`
+	BaseID    string   `json:"baseId"`
+	Type      string   `json:"type"`
+	Name      string   `json:"name,omitempty"`
+	Version   int      `json:"version"`
+	Size      int64    `json:"size"`
+	Extension string   `json:"extension,omitempty"`
+	Path      string   `json:"path"`
+	Source    HostType `json:"source"`
+}
+
+// LibraryTitle is a base title of the library with all its files
+type LibraryTitle struct {
+	TitleDBEntry
+	InTitleDB     bool          `json:"inTitleDb"`
+	TotalSize     int64         `json:"totalSize"`
+	LatestVersion int           `json:"latestVersion"`
+	Files         []LibraryFile `json:"files"`
+	Updates       []LibraryFile `json:"updates"`
+	DLC           []LibraryFile `json:"dlc"`
+}
+
+// TitleQuery holds the pagination, sort and filters of a title listing
+type TitleQuery struct {
+	Offset    int
+	Limit     int
+	Sort      string
+	Desc      bool
+	Name      string
+	Language  string
+	Publisher string
+	Type      string
+}
+
+// TitlesPage is a page of titles
+type TitlesPage struct {
+	Total  int            `json:"total"`
+	Offset int            `json:"offset"`
+	Limit  int            `json:"limit"`
+	Titles []LibraryTitle `json:"titles"`
+}
+
+// FilesPage is a page of files
+type FilesPage struct {
+	Total  int           `json:"total"`
+	Offset int           `json:"offset"`
+	Limit  int           `json:"limit"`
+	Files  []LibraryFile `json:"files"`
+}
+
+// MissingContent holds the update and DLC of a library title found in the titledb but not in the library
+type MissingContent struct {
+	ID            string       `json:"id"`
+	Name          string       `json:"name,omitempty"`
+	IconURL       string       `json:"iconUrl,omitempty"`
+	Version       int          `json:"version"`
+	LatestVersion int          `json:"latestVersion,omitempty"`
+	DLC           []MissingDLC `json:"dlc"`
+}
+
+// MissingDLC is a DLC of the titledb not in the library
+type MissingDLC struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
 // Switch holds all information about the switch
 type Switch struct {
 	IP       string
@@ -209,20 +382,386 @@ type Switch struct {
 
 // StatsSummary holds all information about tinshop
 type StatsSummary struct {
-	Visit           uint64                 `json:"visit,omitempty"`
-	UniqueSwitch    uint64                 `json:"uniqueSwitch,omitempty"`
-	VisitPerSwitch  map[string]interface{} `json:"visitPerSwitch,omitempty"`
-	DownloadAsked   uint64                 `json:"downloadAsked,omitempty"`
-	DownloadDetails map[string]interface{} `json:"downloadDetails,omitempty"`
+	Visit             uint64                 `json:"visit,omitempty"`
+	UniqueSwitch      uint64                 `json:"uniqueSwitch,omitempty"`
+	VisitPerSwitch    map[string]interface{} `json:"visitPerSwitch,omitempty"`
+	DownloadAsked     uint64                 `json:"downloadAsked,omitempty"`
+	DownloadCompleted uint64                 `json:"downloadCompleted,omitempty"`
+	DownloadAborted   uint64                 `json:"downloadAborted,omitempty"`
+	BytesServed       uint64                 `json:"bytesServed,omitempty"`
+	TopTitles         []TitleStats           `json:"topTitles,omitempty"`
+	Devices           []DeviceStats          `json:"devices,omitempty"`
+}
+
+// Download holds all information about a download served to a switch
+type Download struct {
+	TitleID   string
+	Console   Switch
+	Bytes     int64
+	Size      int64
+	Completed bool
+	Time      time.Time
+}
+
+// TitleStats holds the download counters of a title
+type TitleStats struct {
+	TitleID   string    `json:"titleId"`
+	Asked     uint64    `json:"asked"`
+	Completed uint64    `json:"completed"`
+	Aborted   uint64    `json:"aborted"`
+	Bytes     uint64    `json:"bytes"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// DeviceStats holds the counters of a switch
+type DeviceStats struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	Theme     string            `json:"theme,omitempty"`
+	Version   string            `json:"version,omitempty"`
+	Language  string            `json:"language,omitempty"`
+	Visits    uint64            `json:"visits"`
+	Completed uint64            `json:"completed"`
+	Aborted   uint64            `json:"aborted"`
+	Bytes     uint64            `json:"bytes"`
+	Titles    map[string]uint64 `json:"titles,omitempty"`
+	FirstSeen time.Time         `json:"firstSeen"`
+	LastSeen  time.Time         `json:"lastSeen"`
+}
+
+const (
+	// SeriesHourly returns one point per hour
+	SeriesHourly = "hourly"
+	// SeriesDaily returns one point per day
+	SeriesDaily = "daily"
+)
+
+// SeriesPoint holds the counters of an hour or a day
+type SeriesPoint struct {
+	Time      time.Time `json:"time"`
+	Visits    uint64    `json:"visits"`
+	Downloads uint64    `json:"downloads"`
+	Completed uint64    `json:"completed"`
+	Aborted   uint64    `json:"aborted"`
+	Bytes     uint64    `json:"bytes"`
+}
+
+// StatsExport holds the whole statistics database, used to move it between hosts
+type StatsExport struct {
+	Version           int           `json:"version"`
+	ExportedAt        time.Time     `json:"exportedAt"`
+	Visit             uint64        `json:"visit"`
+	DownloadAsked     uint64        `json:"downloadAsked"`
+	DownloadCompleted uint64        `json:"downloadCompleted"`
+	DownloadAborted   uint64        `json:"downloadAborted"`
+	BytesServed       uint64        `json:"bytesServed"`
+	Titles            []TitleStats  `json:"titles"`
+	Devices           []DeviceStats `json:"devices"`
+	Hourly            []SeriesPoint `json:"hourly"`
+	Daily             []SeriesPoint `json:"daily"`
+}
+
+// SeriesQuery holds the range and resolution of a series
+type SeriesQuery struct {
+	From       time.Time
+	To         time.Time
+	Resolution string
 }
 
 // Stats holds all information about statistics
 type Stats interface {
-	Load()
+	Load() error
 	Close() error
 	ListVisit(*Switch) error
 	DownloadAsked(string, string) error
-	Summary() (StatsSummary, error)
+	DownloadServed(Download) error
+	Summary(int) (StatsSummary, error)
+	Series(SeriesQuery) ([]SeriesPoint, error)
+	Export() (StatsExport, error)
+	Import(StatsExport) error
+	OnConfigUpdate(Config)
+	Ping() error
+}
+
+// User holds all information about a shop account
+type User struct {
+	Name        string     `json:"name"`
+	Password    string     `json:"password,omitempty"`
+	Enabled     bool       `json:"enabled"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	MaxDevices  int        `json:"maxDevices"`
+	Collections []string   `json:"collections,omitempty"`
+	Devices     []string   `json:"devices,omitempty"`
+}
+
+// Users holds all function to manage shop accounts
+type Users interface {
+	Load()
+	Close() error
+	Count() int
+	List() ([]User, error)
+	Get(string) (User, error)
+	Save(User) error
+	Delete(string) error
+	Authenticate(string, string, string) (User, error)
+	Check(string, string) (User, error)
+	UnbindDevice(string, string) error
+}
+
+// Device holds what is known about a switch seen by the shop
+type Device struct {
+	UID       string    `json:"uid"`
+	Name      string    `json:"name,omitempty"`
+	Blocked   bool      `json:"blocked"`
+	IP        string    `json:"ip,omitempty"`
+	Theme     string    `json:"theme,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	Language  string    `json:"language,omitempty"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// Devices stores every switch seen with its friendly name
+type Devices interface {
+	Load()
+	Close() error
+	Seen(Switch)
+	List() ([]Device, error)
+	Get(string) (Device, error)
+	Save(Device) error
+	Delete(string) error
+	DisplayName(string) string
+	IsBlocked(string) bool
+}
+
+// Scopes available for admin api tokens
+const (
+	// ScopeReadStats allows to read statistics
+	ScopeReadStats = "read-stats"
+	// ScopeManageStats allows to import statistics
+	ScopeManageStats = "manage-stats"
+	// ScopeReadLibrary allows to browse the library
+	ScopeReadLibrary = "read-library"
+	// ScopeManageLibrary allows to manage games sources and library
+	ScopeManageLibrary = "manage-library"
+	// ScopeManageUsers allows to manage accounts and api tokens
+	ScopeManageUsers = "manage-users"
+	// ScopeReload allows to reload the configuration
+	ScopeReload = "reload"
+	// ScopeReadAudit allows to query the audit log
+	ScopeReadAudit = "read-audit"
+	// ScopeReadEvents allows to follow the live events
+	ScopeReadEvents = "read-events"
+)
+
+// AllScopes returns every scope an api token can have
+func AllScopes() []string {
+	return []string{ScopeReadStats, ScopeManageStats, ScopeReadLibrary, ScopeManageLibrary, ScopeManageUsers, ScopeReload, ScopeReadAudit, ScopeReadEvents}
+}
+
+// APIToken holds all information about an admin api token
+type APIToken struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash,omitempty"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Tokens holds all function to manage admin api tokens
+type Tokens interface {
+	Load()
+	Close() error
+	Count() int
+	List() ([]APIToken, error)
+	Create(string, []string) (APIToken, string, error)
+	Bootstrap([]string) (APIToken, string, error)
+	Revoke(string) error
+	Verify(string) (APIToken, error)
+}
+
+// AuditType describes the kind of audit event
+type AuditType string
+
+const (
+	// AuditBlockedDevice is recorded when a blacklisted switch is refused
+	AuditBlockedDevice AuditType = "blocked_device"
+	// AuditBannedTheme is recorded when a banned theme is refused
+	AuditBannedTheme AuditType = "banned_theme"
+	// AuditHauthMismatch is recorded when the Hauth header does not match
+	AuditHauthMismatch AuditType = "hauth_mismatch"
+	// AuditUauthMismatch is recorded when the Uauth header is not the one of the requested shop url
+	AuditUauthMismatch AuditType = "uauth_mismatch"
+	// AuditForgedRequest is recorded when a request is not coming from tinfoil
+	AuditForgedRequest AuditType = "forged_request"
+	// AuditFailedLogin is recorded when credentials are refused
+	AuditFailedLogin AuditType = "failed_login"
+	// AuditBlockedIP is recorded when the client ip is refused by the ip filter
+	AuditBlockedIP AuditType = "blocked_ip"
+	// AuditDownload is recorded for every download served
+	AuditDownload AuditType = "download"
+)
+
+// AuditEvent holds all information about a security or download event
+type AuditEvent struct {
+	Time    time.Time `json:"time"`
+	Type    AuditType `json:"type"`
+	UID     string    `json:"uid,omitempty"`
+	Device  string    `json:"device,omitempty"`
+	IP      string    `json:"ip,omitempty"`
+	User    string    `json:"user,omitempty"`
+	TitleID string    `json:"titleId,omitempty"`
+	Bytes   int64     `json:"bytes,omitempty"`
+	Detail  string    `json:"detail,omitempty"`
+}
+
+// AuditQuery holds the filters to query the audit log
+type AuditQuery struct {
+	From  time.Time
+	To    time.Time
+	UID   string
+	Type  AuditType
+	Limit int
+}
+
+// Audit holds all function to record and query the audit log
+type Audit interface {
+	Record(AuditEvent)
+	Query(AuditQuery) ([]AuditEvent, error)
+	Close() error
+}
+
+// EventType describes the kind of live event
+type EventType string
+
+// Live events pushed on /api/events
+const (
+	// EventTitleAdded is sent when a file is added to the library
+	EventTitleAdded EventType = "title_added"
+	// EventTitleRemoved is sent when a file is removed from the library
+	EventTitleRemoved EventType = "title_removed"
+	// EventScanStarted is sent when a rescan starts
+	EventScanStarted EventType = "scan_started"
+	// EventScanFinished is sent when a rescan is over or cancelled
+	EventScanFinished EventType = "scan_finished"
+	// EventDownloadStarted is sent when a switch starts a download
+	EventDownloadStarted EventType = "download_started"
+	// EventDownloadCompleted is sent when all the requested bytes have been sent
+	EventDownloadCompleted EventType = "download_completed"
+	// EventDownloadAborted is sent when a download failed or the switch stopped it
+	EventDownloadAborted EventType = "download_aborted"
+	// EventSecurityBlocked is sent when a request is refused by the security checks
+	EventSecurityBlocked EventType = "security_blocked"
+)
+
+// Event holds a live event, Data depends on the type
+type Event struct {
+	ID   uint64      `json:"id"`
+	Type EventType   `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// TitleEvent is the data of title added and removed events
+type TitleEvent struct {
+	ID     string   `json:"id"`
+	Path   string   `json:"path,omitempty"`
+	Size   int64    `json:"size,omitempty"`
+	Source HostType `json:"source,omitempty"`
+}
+
+// DownloadEvent is the data of download events
+type DownloadEvent struct {
+	TitleID string `json:"titleId"`
+	UID     string `json:"uid,omitempty"`
+	Device  string `json:"device,omitempty"`
+	IP      string `json:"ip,omitempty"`
+	Range   string `json:"range,omitempty"`
+	Status  int    `json:"status,omitempty"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// Severities of a problem of the configuration
+const (
+	ConfigError   = "error"
+	ConfigWarning = "warning"
+)
+
+// ConfigProblem is an invalid or suspicious value of a field of the configuration
+type ConfigProblem struct {
+	Field    string `json:"field"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// Webhook holds an url notified of some events, all events when none is listed
+type Webhook struct {
+	URL    string   `mapstructure:"url"`
+	Secret string   `mapstructure:"secret"`
+	Events []string `mapstructure:"events"`
+}
+
+// Events sent to webhooks
+const (
+	// WebhookTitleAdded is sent when a new base title is added to the library
+	WebhookTitleAdded = "title_added"
+	// WebhookUpdateAdded is sent when a new update of a game in the library is added
+	WebhookUpdateAdded = "update_added"
+	// WebhookDeviceBlocked is sent when a blocked switch tries to use the shop
+	WebhookDeviceBlocked = "device_blocked"
+	// WebhookFailedLoginBurst is sent when an ip fails to log in many times in a row
+	WebhookFailedLoginBurst = "failed_login_burst"
+	// WebhookScanError is sent when a rescan could not identify or verify some files
+	WebhookScanError = "scan_error"
+)
+
+// WebhookEvents returns every event a webhook can receive
+func WebhookEvents() []string {
+	return []string{WebhookTitleAdded, WebhookUpdateAdded, WebhookDeviceBlocked, WebhookFailedLoginBurst, WebhookScanError}
+}
+
+// WebhookPayload is the JSON body sent to webhooks
+type WebhookPayload struct {
+	ID    string      `json:"id"`
+	Event string      `json:"event"`
+	Time  time.Time   `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+// FailedLoginBurst is the data of a failed login burst webhook
+type FailedLoginBurst struct {
+	IP       string    `json:"ip"`
+	Attempts int       `json:"attempts"`
+	Since    time.Time `json:"since"`
+}
+
+// Webhooks holds all function to notify webhooks of the shop events
+type Webhooks interface {
+	OnConfigUpdate(Config)
+	Close()
+}
+
+// Uauth is the value sent by tinfoil for a shop url, it differs for each url added in tinfoil
+type Uauth struct {
+	URL   string `mapstructure:"url"`
+	Value string `mapstructure:"value"`
+}
+
+// IPPolicy holds the allow and deny rules of a route
+type IPPolicy struct {
+	Allow          []string `mapstructure:"allow"`
+	Deny           []string `mapstructure:"deny"`
+	AllowCountries []string `mapstructure:"allowCountries"`
+	DenyCountries  []string `mapstructure:"denyCountries"`
+}
+
+// IPFilter holds all function to filter clients by ip
+type IPFilter interface {
+	OnConfigUpdate(Config)
+	AllowIndex(string) bool
+	AllowDownload(string) bool
 }
 
 // Shop holds all tinshop information
@@ -231,10 +770,97 @@ type Shop struct {
 	Sources    Sources
 	Config     Config
 	Stats      Stats
+	Users      Users
+	Tokens     Tokens
+	Devices    Devices
+	Audit      Audit
+	IPFilter   IPFilter
+	Webhooks   Webhooks
 	API        API
 }
 
+// UserRequest holds the fields accepted when creating or updating an account
+type UserRequest struct {
+	Name        string     `json:"name,omitempty"`
+	Password    string     `json:"password,omitempty"`
+	Enabled     *bool      `json:"enabled,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	MaxDevices  *int       `json:"maxDevices,omitempty"`
+	Collections []string   `json:"collections,omitempty"`
+}
+
+// TokenRequest holds the name and scopes of a new api token
+type TokenRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreatedToken is a new api token with its secret, only returned once
+type CreatedToken struct {
+	APIToken
+	Token string `json:"token"`
+}
+
+// DeviceRequest holds the fields an admin can change on a device
+type DeviceRequest struct {
+	Name    *string `json:"name,omitempty"`
+	Blocked *bool   `json:"blocked,omitempty"`
+}
+
+// ScanRequest tells what must be scanned, the whole library by default
+type ScanRequest struct {
+	Source string `json:"source,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+// APIParam describes a query parameter of the api
+type APIParam struct {
+	Name        string
+	Description string
+	// Type is string, integer or boolean
+	Type     string
+	Enum     []string
+	Required bool
+}
+
+// APIRoute describes an endpoint of the api, it is used to register the handler and document it
+type APIRoute struct {
+	ID      string
+	Method  string
+	Path    string
+	Scope   string
+	Summary string
+	Query   []APIParam
+	// Request and Response are values of the types sent and returned, nil when there is no body
+	Request  interface{}
+	Response interface{}
+	// ContentType of the response when it is not json
+	ContentType string
+	// Status returned on success, 200 when not set
+	Status int
+	// Errors are the status returned on errors besides the authentication ones
+	Errors []int
+}
+
 // API holds all function for api
 type API interface {
+	OpenAPI(http.ResponseWriter, []APIRoute)
+	ConfigProblems(http.ResponseWriter, []ConfigProblem)
+	Health(http.ResponseWriter, Health)
 	Stats(http.ResponseWriter, StatsSummary)
+	Users(http.ResponseWriter, []User)
+	User(http.ResponseWriter, User)
+	Tokens(http.ResponseWriter, []APIToken)
+	NewToken(http.ResponseWriter, APIToken, string)
+	Audit(http.ResponseWriter, []AuditEvent)
+	Series(http.ResponseWriter, []SeriesPoint)
+	Devices(http.ResponseWriter, []Device)
+	Titles(http.ResponseWriter, TitlesPage)
+	Title(http.ResponseWriter, LibraryTitle)
+	Files(http.ResponseWriter, FilesPage)
+	Missing(http.ResponseWriter, []MissingContent)
+	FailedFiles(http.ResponseWriter, []FailedFile)
+	Scan(http.ResponseWriter, ScanStatus)
+	Device(http.ResponseWriter, Device)
+	StatsExport(http.ResponseWriter, StatsExport)
 }
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/ajmandourah/tinshop-ng/library"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/stats"
+	"github.com/gorilla/mux"
+)
+
+// apiRoute is a documented route of the api with its handler
+type apiRoute struct {
+	repository.APIRoute
+	handler http.HandlerFunc
+}
+
+// Query parameters shared by several routes
+var (
+	offsetParam = repository.APIParam{Name: "offset", Type: "integer", Description: "Number of items skipped"}                                //nolint:gochecknoglobals
+	limitParam  = repository.APIParam{Name: "limit", Type: "integer", Description: "Number of items returned, 50 by default and 500 at most"} //nolint:gochecknoglobals
+	fromParam   = repository.APIParam{Name: "from", Type: "string", Description: "Start of the range (RFC 3339)"}                             //nolint:gochecknoglobals
+	toParam     = repository.APIParam{Name: "to", Type: "string", Description: "End of the range (RFC 3339)"}                                 //nolint:gochecknoglobals
+)
+
+// apiRoutes returns all the routes of the api, each one is registered and documented in the OpenAPI specification
+func (s *TinShop) apiRoutes() []apiRoute {
+	return []apiRoute{
+		{repository.APIRoute{
+			ID: "getOpenAPI", Method: http.MethodGet, Path: "/openapi.json",
+			Summary:  "OpenAPI specification of the api",
+			Response: map[string]interface{}{},
+		}, s.OpenAPIHandler},
+
+		// Statistics
+		{repository.APIRoute{
+			ID: "getStats", Method: http.MethodGet, Path: "/stats", Scope: repository.ScopeReadStats,
+			Summary:  "Statistics summary with the most downloaded titles",
+			Query:    []repository.APIParam{{Name: "top", Type: "integer", Description: "Number of titles returned, 10 by default"}},
+			Response: repository.StatsSummary{},
+			Errors:   []int{http.StatusInternalServerError},
+		}, s.StatsHandler},
+		{repository.APIRoute{
+			ID: "getStatsSeries", Method: http.MethodGet, Path: "/stats/series", Scope: repository.ScopeReadStats,
+			Summary: "Hourly or daily statistics of a time range",
+			Query: []repository.APIParam{
+				{Name: "resolution", Type: "string", Enum: []string{repository.SeriesHourly, repository.SeriesDaily}},
+				fromParam, toParam,
+			},
+			Response: []repository.SeriesPoint{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}, s.StatsSeriesHandler},
+		{repository.APIRoute{
+			ID: "exportStats", Method: http.MethodGet, Path: "/stats/export", Scope: repository.ScopeReadStats,
+			Summary: "Export the whole statistics as json, or one table as csv",
+			Query: []repository.APIParam{
+				{Name: "format", Type: "string", Enum: []string{"json", "csv"}},
+				{Name: "table", Type: "string", Enum: stats.CSVTables, Description: "Table exported in csv"},
+			},
+			Response:    repository.StatsExport{},
+			ContentType: "text/csv",
+			Errors:      []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}, s.StatsExportHandler},
+		{repository.APIRoute{
+			ID: "importStats", Method: http.MethodPost, Path: "/stats/import", Scope: repository.ScopeManageStats,
+			Summary: "Merge a json export into the statistics",
+			Request: repository.StatsExport{},
+			Status:  http.StatusNoContent,
+			Errors:  []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}, s.StatsImportHandler},
+
+		// Library
+		{repository.APIRoute{
+			ID: "listTitles", Method: http.MethodGet, Path: "/titles", Scope: repository.ScopeReadLibrary,
+			Summary: "Base titles of the library with their files, updates and dlc",
+			Query: []repository.APIParam{
+				offsetParam, limitParam,
+				{Name: "sort", Type: "string", Enum: []string{library.SortName, library.SortID, library.SortSize, library.SortReleaseDate}},
+				{Name: "order", Type: "string", Enum: []string{"asc", "desc"}},
+				{Name: "name", Type: "string", Description: "Part of the name"},
+				{Name: "language", Type: "string"},
+				{Name: "publisher", Type: "string", Description: "Part of the publisher"},
+				{Name: "type", Type: "string", Enum: []string{repository.ContentBase, repository.ContentUpdate, repository.ContentDLC}, Description: "Keep the titles having this type of content"},
+			},
+			Response: repository.TitlesPage{},
+			Errors:   []int{http.StatusBadRequest},
+		}, s.TitlesHandler},
+		{repository.APIRoute{
+			ID: "getTitle", Method: http.MethodGet, Path: "/titles/{id}", Scope: repository.ScopeReadLibrary,
+			Summary:  "A title from its base, update or dlc id",
+			Response: repository.LibraryTitle{},
+			Errors:   []int{http.StatusNotFound},
+		}, s.TitleHandler},
+		{repository.APIRoute{
+			ID: "searchTitles", Method: http.MethodGet, Path: "/search", Scope: repository.ScopeReadLibrary,
+			Summary:  "Titles whose name, or the name of one of their dlc, contains the text",
+			Query:    []repository.APIParam{{Name: "q", Type: "string", Required: true}, limitParam},
+			Response: repository.TitlesPage{},
+			Errors:   []int{http.StatusBadRequest},
+		}, s.SearchHandler},
+		{repository.APIRoute{
+			ID: "listFiles", Method: http.MethodGet, Path: "/files", Scope: repository.ScopeReadLibrary,
+			Summary: "Files of the sources with their path, type, version and size",
+			Query: []repository.APIParam{
+				{Name: "source", Type: "string", Enum: []string{string(repository.LocalFile), string(repository.NFSShare)}},
+				offsetParam, limitParam,
+			},
+			Response: repository.FilesPage{},
+			Errors:   []int{http.StatusBadRequest},
+		}, s.FilesHandler},
+		{repository.APIRoute{
+			ID: "listUnidentifiedFiles", Method: http.MethodGet, Path: "/files/unidentified", Scope: repository.ScopeReadLibrary,
+			Summary:  "Files of the sources which could not be added to the library",
+			Response: []repository.FailedFile{},
+		}, s.UnidentifiedHandler},
+		{repository.APIRoute{
+			ID: "listMissingContent", Method: http.MethodGet, Path: "/library/missing", Scope: repository.ScopeReadLibrary,
+			Summary:  "Updates and dlc of the titledb missing in the library",
+			Response: []repository.MissingContent{},
+		}, s.MissingHandler},
+		{repository.APIRoute{
+			ID: "getScan", Method: http.MethodGet, Path: "/scan", Scope: repository.ScopeManageLibrary,
+			Summary:  "Progress of the running or last scan",
+			Response: repository.ScanStatus{},
+		}, s.ScanHandler},
+		{repository.APIRoute{
+			ID: "startScan", Method: http.MethodPost, Path: "/scan", Scope: repository.ScopeManageLibrary,
+			Summary:  "Start a scan of the library in background",
+			Request:  repository.ScanRequest{},
+			Response: repository.ScanStatus{},
+			Errors:   []int{http.StatusBadRequest, http.StatusConflict, http.StatusServiceUnavailable},
+		}, s.ScanHandler},
+		{repository.APIRoute{
+			ID: "cancelScan", Method: http.MethodDelete, Path: "/scan", Scope: repository.ScopeManageLibrary,
+			Summary: "Cancel the running scan",
+			Status:  http.StatusNoContent,
+			Errors:  []int{http.StatusNotFound},
+		}, s.ScanHandler},
+
+		// Devices
+		{repository.APIRoute{
+			ID: "listDevices", Method: http.MethodGet, Path: "/devices", Scope: repository.ScopeManageUsers,
+			Summary:  "Switches seen by the shop",
+			Response: []repository.Device{},
+			Errors:   []int{http.StatusInternalServerError},
+		}, s.DevicesHandler},
+		{repository.APIRoute{
+			ID: "getDevice", Method: http.MethodGet, Path: "/devices/{uid}", Scope: repository.ScopeManageUsers,
+			Summary:  "A switch of the registry",
+			Response: repository.Device{},
+			Errors:   []int{http.StatusNotFound},
+		}, s.DeviceHandler},
+		{repository.APIRoute{
+			ID: "updateDevice", Method: http.MethodPut, Path: "/devices/{uid}", Scope: repository.ScopeManageUsers,
+			Summary:  "Name or block a switch, it may not have been seen yet",
+			Request:  repository.DeviceRequest{},
+			Response: repository.Device{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}, s.DeviceHandler},
+		{repository.APIRoute{
+			ID: "deleteDevice", Method: http.MethodDelete, Path: "/devices/{uid}", Scope: repository.ScopeManageUsers,
+			Summary: "Remove a switch from the registry",
+			Status:  http.StatusNoContent,
+			Errors:  []int{http.StatusNotFound, http.StatusInternalServerError},
+		}, s.DeviceHandler},
+
+		// Accounts
+		{repository.APIRoute{
+			ID: "listUsers", Method: http.MethodGet, Path: "/users", Scope: repository.ScopeManageUsers,
+			Summary:  "Accounts of the shop",
+			Response: []repository.User{},
+			Errors:   []int{http.StatusInternalServerError},
+		}, s.UsersHandler},
+		{repository.APIRoute{
+			ID: "createUser", Method: http.MethodPost, Path: "/users", Scope: repository.ScopeManageUsers,
+			Summary:  "Create an account, name and password are required",
+			Request:  repository.UserRequest{},
+			Response: repository.User{},
+			Errors:   []int{http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError},
+		}, s.UsersHandler},
+		{repository.APIRoute{
+			ID: "getUser", Method: http.MethodGet, Path: "/users/{name}", Scope: repository.ScopeManageUsers,
+			Summary:  "An account",
+			Response: repository.User{},
+			Errors:   []int{http.StatusNotFound},
+		}, s.UserHandler},
+		{repository.APIRoute{
+			ID: "updateUser", Method: http.MethodPut, Path: "/users/{name}", Scope: repository.ScopeManageUsers,
+			Summary:  "Update the fields sent of an account",
+			Request:  repository.UserRequest{},
+			Response: repository.User{},
+			Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+		}, s.UserHandler},
+		{repository.APIRoute{
+			ID: "deleteUser", Method: http.MethodDelete, Path: "/users/{name}", Scope: repository.ScopeManageUsers,
+			Summary: "Delete an account",
+			Status:  http.StatusNoContent,
+			Errors:  []int{http.StatusNotFound, http.StatusInternalServerError},
+		}, s.UserHandler},
+		{repository.APIRoute{
+			ID: "unbindUserDevice", Method: http.MethodDelete, Path: "/users/{name}/devices/{uid}", Scope: repository.ScopeManageUsers,
+			Summary: "Unbind a switch from an account",
+			Status:  http.StatusNoContent,
+			Errors:  []int{http.StatusBadRequest, http.StatusNotFound},
+		}, s.UserDeviceHandler},
+		{repository.APIRoute{
+			ID: "listTokens", Method: http.MethodGet, Path: "/tokens", Scope: repository.ScopeManageUsers,
+			Summary:  "Api tokens, without their secret",
+			Response: []repository.APIToken{},
+			Errors:   []int{http.StatusInternalServerError},
+		}, s.TokensHandler},
+		{repository.APIRoute{
+			ID: "createToken", Method: http.MethodPost, Path: "/tokens", Scope: repository.ScopeManageUsers,
+			Summary:  "Create an api token with some of the scopes of the caller, its secret is only returned once",
+			Request:  repository.TokenRequest{},
+			Response: repository.CreatedToken{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}, s.TokensHandler},
+		{repository.APIRoute{
+			ID: "revokeToken", Method: http.MethodDelete, Path: "/tokens/{id}", Scope: repository.ScopeManageUsers,
+			Summary: "Revoke an api token having only scopes of the caller",
+			Status:  http.StatusNoContent,
+			Errors:  []int{http.StatusNotFound, http.StatusInternalServerError},
+		}, s.TokenHandler},
+
+		// Administration
+		{repository.APIRoute{
+			ID: "reloadConfig", Method: http.MethodPost, Path: "/reload", Scope: repository.ScopeReload,
+			Summary: "Reload the configuration, an invalid one is not applied and its problems are returned",
+			Status:  http.StatusNoContent,
+			Errors:  []int{http.StatusUnprocessableEntity, http.StatusInternalServerError},
+		}, s.ReloadHandler},
+		{repository.APIRoute{
+			ID: "followEvents", Method: http.MethodGet, Path: "/events", Scope: repository.ScopeReadEvents,
+			Summary:     "Live events as Server-Sent Events, the Last-Event-ID header replays the missed ones",
+			Query:       []repository.APIParam{{Name: "types", Type: "string", Description: "Comma separated event types"}},
+			ContentType: "text/event-stream",
+		}, s.EventsHandler},
+		{repository.APIRoute{
+			ID: "queryAudit", Method: http.MethodGet, Path: "/audit", Scope: repository.ScopeReadAudit,
+			Summary: "Security events of the audit log",
+			Query: []repository.APIParam{
+				fromParam, toParam,
+				{Name: "uid", Type: "string", Description: "Uid of the switch"},
+				{Name: "type", Type: "string", Description: "Type of event"},
+				{Name: "limit", Type: "integer", Description: "Number of latest events returned, 100 by default and 1000 at most"},
+			},
+			Response: []repository.AuditEvent{},
+			Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+		}, s.AuditHandler},
+	}
+}
+
+// RegisterAPI registers the handlers of the api routes, behind a token granted their scope
+func (s *TinShop) RegisterAPI(router *mux.Router) {
+	for _, route := range s.apiRoutes() {
+		var handler http.Handler = route.handler
+		if route.Scope != "" {
+			handler = s.RequireScope(route.Scope, route.handler)
+		}
+		router.Handle(route.Path, handler).Methods(route.Method)
+	}
+}
+
+// OpenAPIHandler returns the OpenAPI specification of the api
+func (s *TinShop) OpenAPIHandler(w http.ResponseWriter, _ *http.Request) {
+	routes := make([]repository.APIRoute, 0)
+	for _, route := range s.apiRoutes() {
+		routes = append(routes, route.APIRoute)
+	}
+	s.Shop.API.OpenAPI(w, routes)
+}
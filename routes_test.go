@@ -0,0 +1,69 @@
+package main_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	main "github.com/ajmandourah/tinshop-ng"
+	"github.com/ajmandourah/tinshop-ng/api"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/gorilla/mux"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Routes", func() {
+	var (
+		router *mux.Router
+		spec   api.Document
+	)
+
+	BeforeEach(func() {
+		myShop := &main.TinShop{}
+		myShop.Shop = repository.Shop{API: api.New()}
+		router = mux.NewRouter()
+		myShop.RegisterAPI(router.PathPrefix("/api").Subrouter())
+
+		writer := httptest.NewRecorder()
+		router.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+		Expect(writer.Code).To(Equal(http.StatusOK))
+		Expect(json.Unmarshal(writer.Body.Bytes(), &spec)).To(Succeed())
+	})
+
+	It("documents every route of the api once", func() {
+		operations := make(map[string]bool)
+		for path, methods := range spec.Paths {
+			for method, operation := range methods {
+				Expect(operation.OperationID).NotTo(BeEmpty(), method+" "+path)
+				Expect(operations).NotTo(HaveKey(operation.OperationID))
+				operations[operation.OperationID] = true
+			}
+		}
+		Expect(spec.Paths).To(HaveKey("/titles/{id}"))
+		Expect(spec.Paths["/scan"]).To(HaveLen(3))
+		Expect(spec.Components.Schemas).To(HaveKey("LibraryTitle"))
+	})
+	It("protects every documented route with its scope", func() {
+		for path, methods := range spec.Paths {
+			for method, operation := range methods {
+				url := "/api" + strings.NewReplacer("{id}", "1", "{uid}", "1", "{name}", "1").Replace(path)
+				writer := httptest.NewRecorder()
+				router.ServeHTTP(writer, httptest.NewRequest(strings.ToUpper(method), url, nil))
+
+				if operation.Scope == "" {
+					Expect(writer.Code).To(Equal(http.StatusOK), method+" "+path)
+				} else {
+					Expect(writer.Code).To(Equal(http.StatusUnauthorized), method+" "+path)
+				}
+			}
+		}
+	})
+	It("does not route undocumented paths", func() {
+		writer := httptest.NewRecorder()
+		router.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, "/api/unknown", nil))
+
+		Expect(writer.Code).To(Equal(http.StatusNotFound))
+	})
+})
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/sources"
+)
+
+// ScanHandler handles starting, following and cancelling a library scan
+func (s *TinShop) ScanHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.Shop.API.Scan(w, s.Shop.Sources.ScanStatus())
+	case http.MethodPost:
+		var req repository.ScanRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+		status, err := s.Shop.Sources.Rescan(req.Source, req.Path)
+		switch {
+		case errors.Is(err, sources.ErrScanRunning):
+			w.WriteHeader(http.StatusConflict)
+			return
+		case errors.Is(err, sources.ErrUnknownTarget), errors.Is(err, sources.ErrPathOutside):
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Println("[API] Unable to start scan", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		log.Printf("[API] Scan %d of '%s' requested\n", status.ID, status.Target)
+		s.Shop.API.Scan(w, status)
+	case http.MethodDelete:
+		if !s.Shop.Sources.CancelScan() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		log.Println("[API] Scan cancelled")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,129 @@
+// @title tinshop Scan
+
+// @BasePath /scan/
+
+// Package scan provides the progress of a library scan
+package scan
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+// None is the tracker of loads not followed by a scan
+var None repository.ScanTracker = none{} //nolint:gochecknoglobals
+
+type none struct{}
+
+func (none) FileSeen()       {}
+func (none) FileIdentified() {}
+func (none) FileFailed()     {}
+func (none) Cancelled() bool { return false }
+
+// Progress counts the files of a running scan
+type Progress struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	seen       int64
+	identified int64
+	failed     int64
+
+	mutex     sync.Mutex
+	committed bool
+	status    repository.ScanStatus
+}
+
+// New returns the progress of a scan starting now
+func New(id int, target string, path string) *Progress {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Progress{
+		ctx:    ctx,
+		cancel: cancel,
+		status: repository.ScanStatus{
+			ID:        id,
+			State:     repository.ScanRunning,
+			Target:    target,
+			Path:      path,
+			StartedAt: time.Now().UTC(),
+		},
+	}
+}
+
+// FileSeen counts a game file found
+func (p *Progress) FileSeen() {
+	atomic.AddInt64(&p.seen, 1)
+}
+
+// FileIdentified counts a game file added to the library
+func (p *Progress) FileIdentified() {
+	atomic.AddInt64(&p.identified, 1)
+}
+
+// FileFailed counts a game file which could not be identified or verified
+func (p *Progress) FileFailed() {
+	atomic.AddInt64(&p.failed, 1)
+}
+
+// Cancelled tells if the scan must stop
+func (p *Progress) Cancelled() bool {
+	return p.ctx.Err() != nil
+}
+
+// Cancel asks the scan to stop, it returns false when the scan is already over
+func (p *Progress) Cancel() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.status.State != repository.ScanRunning || p.committed {
+		return false
+	}
+	p.cancel()
+	return true
+}
+
+// Commit marks the scan result as applied, it returns false when the scan
+// has been cancelled and its result must be dropped
+func (p *Progress) Commit() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.Cancelled() {
+		return false
+	}
+	p.committed = true
+	return true
+}
+
+// Finish ends the scan, as cancelled when it has been asked to stop
+func (p *Progress) Finish() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	now := time.Now().UTC()
+	p.status.FinishedAt = &now
+	p.status.State = repository.ScanFinished
+	if !p.committed && p.Cancelled() {
+		p.status.State = repository.ScanCancelled
+	}
+	p.cancel()
+}
+
+// Running tells if the scan is not over
+func (p *Progress) Running() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.status.State == repository.ScanRunning
+}
+
+// Status returns the current state and counters of the scan
+func (p *Progress) Status() repository.ScanStatus {
+	p.mutex.Lock()
+	status := p.status
+	p.mutex.Unlock()
+	status.Seen = atomic.LoadInt64(&p.seen)
+	status.Identified = atomic.LoadInt64(&p.identified)
+	status.Failed = atomic.LoadInt64(&p.failed)
+	return status
+}
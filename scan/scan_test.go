@@ -0,0 +1,65 @@
+package scan_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/scan"
+)
+
+var _ = Describe("Scan", func() {
+	var progress *scan.Progress
+	BeforeEach(func() {
+		progress = scan.New(3, repository.ScanPath, "/games/sub")
+	})
+	It("Never cancels loads without scan", func() {
+		Expect(scan.None.Cancelled()).To(BeFalse())
+	})
+	It("Starts running", func() {
+		status := progress.Status()
+
+		Expect(progress.Running()).To(BeTrue())
+		Expect(status.ID).To(Equal(3))
+		Expect(status.State).To(Equal(repository.ScanRunning))
+		Expect(status.Target).To(Equal(repository.ScanPath))
+		Expect(status.Path).To(Equal("/games/sub"))
+		Expect(status.StartedAt).NotTo(BeZero())
+		Expect(status.FinishedAt).To(BeNil())
+	})
+	It("Counts files", func() {
+		progress.FileSeen()
+		progress.FileSeen()
+		progress.FileIdentified()
+		progress.FileFailed()
+
+		status := progress.Status()
+		Expect(status.Seen).To(Equal(int64(2)))
+		Expect(status.Identified).To(Equal(int64(1)))
+		Expect(status.Failed).To(Equal(int64(1)))
+	})
+	It("Finishes", func() {
+		Expect(progress.Commit()).To(BeTrue())
+		progress.Finish()
+
+		Expect(progress.Running()).To(BeFalse())
+		Expect(progress.Status().State).To(Equal(repository.ScanFinished))
+		Expect(progress.Status().FinishedAt).NotTo(BeNil())
+		Expect(progress.Cancel()).To(BeFalse())
+	})
+	It("Is cancelled", func() {
+		Expect(progress.Cancel()).To(BeTrue())
+		Expect(progress.Cancelled()).To(BeTrue())
+		Expect(progress.Commit()).To(BeFalse())
+		progress.Finish()
+
+		Expect(progress.Status().State).To(Equal(repository.ScanCancelled))
+	})
+	It("Can not be cancelled once committed", func() {
+		Expect(progress.Commit()).To(BeTrue())
+
+		Expect(progress.Cancel()).To(BeFalse())
+		progress.Finish()
+		Expect(progress.Status().State).To(Equal(repository.ScanFinished))
+	})
+})
@@ -1,20 +1,50 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"html/template"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 
+	"github.com/ajmandourah/tinshop-ng/config"
+	"github.com/ajmandourah/tinshop-ng/events"
+	"github.com/ajmandourah/tinshop-ng/metrics"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/tokens"
+	"github.com/ajmandourah/tinshop-ng/users"
 	"github.com/ajmandourah/tinshop-ng/utils"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/goji/httpauth"
 )
 
+// allowIP applies the ip filter policy of the requested route
+func (s *TinShop) allowIP(r *http.Request) bool {
+	if s.Shop.IPFilter == nil {
+		return true
+	}
+	if strings.HasPrefix(r.URL.Path, "/games/") {
+		return s.Shop.IPFilter.AllowDownload(utils.GetIPFromRequest(r))
+	}
+	if r.URL.Path == "/" || utils.IsValidFilter(cleanPath(r.RequestURI)) {
+		return s.Shop.IPFilter.AllowIndex(utils.GetIPFromRequest(r))
+	}
+	return true
+}
+
 // CORSMiddleware is a middleware to ensure right CORS headers
 func (s *TinShop) CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(r.RequestURI, "/api/") {
-			w.Header().Set("Access-Control-Allow-Origin", s.Shop.Config.RootShop())
+			origin := r.Header.Get("Origin")
+			if origin != "" && utils.Contains(s.Shop.Config.AdminAllowedOrigins(), origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", s.Shop.Config.RootShop())
+			}
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 			w.Header().Set("Vary", "Origin")
 		} else {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -33,6 +63,14 @@ func (s *TinShop) TinfoilMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Verify all headers
 		headers := r.Header
+
+		// Check client ip before anything else
+		if !s.allowIP(r) {
+			log.Println("[Security] Access refused by ip filter", utils.GetIPFromRequest(r))
+			s.recordAudit(newAuditEvent(repository.AuditBlockedIP, r, r.URL.Path))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
 		
 		//Skip security checks if Nosecurity is true
 		if s.Shop.Config.DebugNoSecurity() {
@@ -44,13 +82,22 @@ func (s *TinShop) TinfoilMiddleware(next http.Handler) http.Handler {
 		if strings.Contains(r.RequestURI, "/games") {
 			//insuring basic headers available while downloading a game
 			if headers["Hauth"] == nil || headers["Uauth"] == nil || headers["Tinshop-Ng"] == nil {
-				log.Println("An attempt to download one of your content from non-Tinfoil Client was Blocked.", r.RemoteAddr)
+				log.Println("An attempt to download one of your content from non-Tinfoil Client was Blocked.", utils.GetIPFromRequest(r))
+				s.recordAudit(newAuditEvent(repository.AuditForgedRequest, r, "download from non-Tinfoil client"))
 				return
 			}
 
 			//Hauth check
 			if s.Shop.Config.Get_Hauth() != "" && r.Header.Get("Hauth") != s.Shop.Config.Get_Hauth(){
-				log.Println("Hauth header mismatch. Possible attempt to access shop from a possible forged request. ", r.RemoteAddr)
+				log.Println("Hauth header mismatch. Possible attempt to access shop from a possible forged request. ", utils.GetIPFromRequest(r))
+				s.recordAudit(newAuditEvent(repository.AuditHauthMismatch, r, r.Header.Get("Hauth")))
+				return
+			}
+
+			//Uauth check, the download is sent with the Uauth of the shop url listing it
+			if !s.Shop.Config.IsValidHostUauth(r.Host, r.Header.Get("Uauth")) {
+				log.Println("[Security] Uauth header mismatch on a download. Possible attempt to access shop from a possible forged request.", r.Host, utils.GetIPFromRequest(r))
+				s.recordAudit(newAuditEvent(repository.AuditUauthMismatch, r, r.Header.Get("Uauth")))
 				return
 			}
 		}
@@ -67,8 +114,10 @@ func (s *TinShop) TinfoilMiddleware(next http.Handler) http.Handler {
 	
 			// Check for blacklist/whitelist
 			var uid = strings.Join(headers["Uid"], "")
-			if s.Shop.Config.IsBlacklisted(uid) {
-				log.Println("[Security] Blacklisted switch detected...", uid)
+			s.recordDevice(r)
+			if s.isBlacklisted(uid) {
+				log.Println("[Security] Blacklisted switch detected...", s.deviceName(uid))
+				s.recordAudit(newAuditEvent(repository.AuditBlockedDevice, r, ""))
 				_ = shopTemplate.Execute(w, s.Shop.Config.ShopTemplateData())
 				return
 			}
@@ -78,7 +127,8 @@ func (s *TinShop) TinfoilMiddleware(next http.Handler) http.Handler {
 			// Check for banned theme
 			var theme = strings.Join(headers["Theme"], "")
 			if s.Shop.Config.IsBannedTheme(theme) {
-				log.Println("[Security] Banned theme detected...", uid, theme)
+				log.Println("[Security] Banned theme detected...", s.deviceName(uid), theme)
+				s.recordAudit(newAuditEvent(repository.AuditBannedTheme, r, theme))
 				_ = shopTemplate.Execute(w, s.Shop.Config.ShopTemplateData())
 				return
 			}
@@ -100,14 +150,25 @@ func (s *TinShop) TinfoilMiddleware(next http.Handler) http.Handler {
 
 			//Hauth check
 			if s.Shop.Config.Get_Hauth() != "" && r.Header.Get("Hauth") != s.Shop.Config.Get_Hauth(){
-				log.Println("Hauth header mismatch. Possible attempt to access shop from a possible forged request. ", r.RemoteAddr)
+				log.Println("Hauth header mismatch. Possible attempt to access shop from a possible forged request. ", utils.GetIPFromRequest(r))
+				s.recordAudit(newAuditEvent(repository.AuditHauthMismatch, r, r.Header.Get("Hauth")))
 				return
 			}
 
+			//Uauth check, the value depends on the shop url added in tinfoil
+			shopURL := requestShopURL(r)
+			if !s.Shop.Config.HasUauth(shopURL) {
+				showUauth(shopURL, r.Header.Get("Uauth"))
+			}
+			if !s.Shop.Config.IsValidUauth(shopURL, r.Header.Get("Uauth")) {
+				log.Println("[Security] Uauth header mismatch. Possible attempt to access shop from a possible forged request.", shopURL, utils.GetIPFromRequest(r))
+				s.recordAudit(newAuditEvent(repository.AuditUauthMismatch, r, r.Header.Get("Uauth")))
+				_ = shopTemplate.Execute(w, s.Shop.Config.ShopTemplateData())
+				return
+			}
 
 			// Enforce true tinfoil queries
-			// TODO: Check Uauth and Hauth headers
-			log.Printf("Switch %s requesting %s", headers["Uid"], r.RequestURI)
+			log.Printf("Switch %s requesting %s", s.deviceName(uid), r.RequestURI)
 
 			// Check user password
 			if s.Shop.Config.ForwardAuthURL() != "" && headers["Authorization"] != nil {
@@ -124,7 +185,9 @@ func (s *TinShop) TinfoilMiddleware(next http.Handler) http.Handler {
 				}
 				defer resp.Body.Close()
 				if resp.StatusCode != 200 {
-					log.Println("Wrong credentials enterd from switch ",r.Header.Get("Uid"), " " ,r.RemoteAddr)
+					log.Println("Wrong credentials enterd from switch ",r.Header.Get("Uid"), " " ,utils.GetIPFromRequest(r))
+					s.recordAudit(newAuditEvent(repository.AuditFailedLogin, r, "forward auth refused"))
+					countAuthFailure("forward_auth")
 					_ = shopTemplate.Execute(w, s.Shop.Config.ShopTemplateData())
 					return
 				}
@@ -136,6 +199,40 @@ func (s *TinShop) TinfoilMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// maxShownUauth is the number of pairs remembered, the next ones are printed again
+const maxShownUauth = 100
+
+// shownUauth holds the shop url and Uauth pairs already printed in the logs
+var (
+	shownUauth      = make(map[string]struct{}) //nolint:gochecknoglobals
+	shownUauthMutex sync.Mutex                  //nolint:gochecknoglobals
+)
+
+// requestShopURL returns the shop url added in tinfoil for an index request, ie "shop.example.com/switch"
+func requestShopURL(r *http.Request) string {
+	return config.NormalizeShopURL(r.Host + r.URL.Path)
+}
+
+// showUauth prints the Uauth of a shop url without accepted values once, to be added to them
+func showUauth(shopURL string, uauth string) {
+	if uauth == "" {
+		return
+	}
+	key := shopURL + " " + uauth
+	shownUauthMutex.Lock()
+	_, shown := shownUauth[key]
+	if !shown {
+		if len(shownUauth) >= maxShownUauth {
+			shownUauth = make(map[string]struct{})
+		}
+		shownUauth[key] = struct{}{}
+	}
+	shownUauthMutex.Unlock()
+	if !shown {
+		log.Println("UAUTH for ", shopURL, " is: ", uauth)
+	}
+}
+
 func cleanPath(path string) string {
 	actualPath := path[1:]
 	if path[len(path)-1:] == "/" {
@@ -144,19 +241,192 @@ func cleanPath(path string) string {
 	return actualPath
 }
 
+// AuthMiddleware is a middleware asking credentials when static credentials or accounts exist
+func (s *TinShop) AuthMiddleware(next http.Handler) http.Handler {
+	protected := httpauth.BasicAuth(httpauth.AuthOptions{
+		Realm:    "Tinfoil",
+		AuthFunc: s.HttpAuthCheck,
+	})(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.Shop.Config.Get_Httpauth()) == 0 && (s.Shop.Users == nil || s.Shop.Users.Count() == 0) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		protected.ServeHTTP(w, r)
+	})
+}
+
+// RequireScope ensure the api is queried with a bearer token granted the scope
+func (s *TinShop) RequireScope(scope string, handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, "Bearer ") {
+			countAuthFailure("missing_token")
+			w.Header().Set("WWW-Authenticate", "Bearer realm=\"TinShop API\"")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		token, err := s.Shop.Tokens.Verify(strings.TrimPrefix(authorization, "Bearer "))
+		if err != nil {
+			log.Println("[Security] Invalid api token used from", utils.GetIPFromRequest(r))
+			countAuthFailure("invalid_token")
+			w.Header().Set("WWW-Authenticate", "Bearer realm=\"TinShop API\", error=\"invalid_token\"")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !tokens.HasScope(token, scope) {
+			log.Println("[Security] Api token", token.Name, "is missing scope", scope)
+			countAuthFailure("missing_scope")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		handler(w, r.WithContext(context.WithValue(r.Context(), tokenKey{}, token)))
+	})
+}
+
+// tokenKey is the context key of the api token verified by RequireScope
+type tokenKey struct{}
+
+// requestToken returns the api token the request has been authorized with
+func requestToken(r *http.Request) (repository.APIToken, bool) {
+	token, ok := r.Context().Value(tokenKey{}).(repository.APIToken)
+	return token, ok
+}
+
 // HttpAuthCheck function checks for correct credentials
-func HttpAuthCheck(user ,pass string, r *http.Request) bool {
-	for _,cred := range creds {
-		splitted := strings.Split(cred,":")
-		if splitted[0] == user {
-			err := bcrypt.CompareHashAndPassword([]byte(splitted[1]),[]byte(pass))
-			if err == nil {
+func (s *TinShop) HttpAuthCheck(user, pass string, r *http.Request) bool {
+	if s.checkStaticCredentials(user, pass) {
+		return true
+	}
+
+	if s.Shop.Users != nil && s.Shop.Users.Count() != 0 {
+		// Browsers send no Uid, the catalog is listed without binding a device
+		catalog := s.CatalogMatcher(r, nil)
+		var account repository.User
+		var err error
+		if catalog {
+			account, err = s.Shop.Users.Check(user, pass)
+		} else {
+			account, err = s.Shop.Users.Authenticate(user, pass, r.Header.Get("Uid"))
+		}
+		reason := loginFailureReason(err)
+		if err == nil {
+			// A download is not part of a collection, the account only has to be valid.
+			// The catalog only lists the games of the collections of the account
+			if strings.HasPrefix(r.URL.Path, "/games/") || catalog || users.CanAccess(account, collectionFromPath(r.URL.Path)) {
 				return true
 			}
-		
+			err = errors.New("collection not allowed")
+			reason = "collection_not_allowed"
 		}
+		log.Println("[Security] Access refused for", user, s.deviceName(r.Header.Get("Uid")), ":", err)
+		countAuthFailure(reason)
+		event := newAuditEvent(repository.AuditFailedLogin, r, err.Error())
+		event.User = user
+		s.recordAudit(event)
+		return false
 	}
+
 	log.Println("An attempt to access the shop with username: ", user)
+	event := newAuditEvent(repository.AuditFailedLogin, r, "wrong credentials")
+	event.User = user
+	s.recordAudit(event)
+	countAuthFailure("wrong_credentials")
+	return false
+}
+
+// loginFailureReason returns the metrics label of an authentication error
+func loginFailureReason(err error) string {
+	switch {
+	case errors.Is(err, users.ErrUnknownUser):
+		return "unknown_user"
+	case errors.Is(err, users.ErrWrongPassword):
+		return "wrong_password"
+	case errors.Is(err, users.ErrDisabled):
+		return "account_disabled"
+	case errors.Is(err, users.ErrExpired):
+		return "account_expired"
+	case errors.Is(err, users.ErrMissingDevice):
+		return "missing_device"
+	case errors.Is(err, users.ErrTooManyDevices):
+		return "too_many_devices"
+	}
+	return "error"
+}
+
+// countAuthFailure increments the auth failures metric
+func countAuthFailure(reason string) {
+	metrics.AuthFailures.WithLabelValues(reason).Inc()
+}
+
+func (s *TinShop) checkStaticCredentials(user, pass string) bool {
+	for _, cred := range s.Shop.Config.Get_Httpauth() {
+		// Entries without password are reported by the config validation
+		name, hash, ok := strings.Cut(cred, ":")
+		if ok && name == user {
+			if users.ComparePassword(hash, pass) {
+				return true
+			}
+		}
+	}
 	return false
+}
+
+// maxAuditDetail is the longest detail of an audit event
+const maxAuditDetail = 256
+
+// newAuditEvent returns an audit event filled with the request information.
+// The detail comes from the request, it is truncated to keep the lines of the log short.
+func newAuditEvent(eventType repository.AuditType, r *http.Request, detail string) repository.AuditEvent {
+	user, _, _ := r.BasicAuth()
+	if len(detail) > maxAuditDetail {
+		detail = strings.ToValidUTF8(detail[:maxAuditDetail], "")
+	}
+	return repository.AuditEvent{
+		Type:   eventType,
+		UID:    r.Header.Get("Uid"),
+		IP:     utils.GetIPFromRequest(r),
+		User:   user,
+		Detail: detail,
+	}
+}
 
+// recordAudit records the event if the audit log is available.
+// Refused requests are counted in the metrics, failed logins are counted with a finer reason.
+// Refused requests are also pushed to the live events.
+func (s *TinShop) recordAudit(event repository.AuditEvent) {
+	if event.Type != repository.AuditDownload && event.Type != repository.AuditFailedLogin {
+		countAuthFailure(string(event.Type))
+	}
+	if name := s.deviceName(event.UID); name != event.UID {
+		event.Device = name
+	}
+	if s.Shop.Audit != nil {
+		s.Shop.Audit.Record(event)
+	}
+	if event.Type != repository.AuditDownload {
+		events.Publish(repository.EventSecurityBlocked, event)
+	}
+}
+
+// requestAccount returns the account of the credentials of an authenticated request,
+// none for the static credentials of the config
+func (s *TinShop) requestAccount(r *http.Request) (repository.User, bool) {
+	name, password, ok := r.BasicAuth()
+	if !ok || s.Shop.Users == nil || s.checkStaticCredentials(name, password) {
+		return repository.User{}, false
+	}
+	account, err := s.Shop.Users.Get(name)
+	return account, err == nil
+}
+
+// collectionFromPath returns the filter used by a listing path
+func collectionFromPath(path string) string {
+	if path == "/" || path == "" {
+		return "world"
+	}
+	return strings.ToLower(cleanPath(path))
 }
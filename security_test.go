@@ -2,8 +2,10 @@ package main_test
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 
 	main "github.com/ajmandourah/tinshop-ng"
 	"github.com/ajmandourah/tinshop-ng/mock_repository"
@@ -12,6 +14,7 @@ import (
 	"github.com/gorilla/mux"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var _ = Describe("Security", func() {
@@ -81,6 +84,168 @@ var _ = Describe("Security", func() {
 				Expect(list.Titledb).To(HaveLen(0))
 			})
 		})
+		Context("With ip filter", func() {
+			var myMockIPFilter *mock_repository.MockIPFilter
+
+			BeforeEach(func() {
+				myMockIPFilter = mock_repository.NewMockIPFilter(ctrl)
+				r := mux.NewRouter()
+				r.Use(myShop.TinfoilMiddleware)
+				r.HandleFunc("/", myShop.HomeHandler)
+				r.HandleFunc("/games/{game}", myShop.HomeHandler) // Testing purpose
+				handler = r
+				writer = httptest.NewRecorder()
+
+				myMockCollection.EXPECT().
+					Games().
+					Return(repository.GameType{}).
+					AnyTimes()
+				myMockConfig.EXPECT().
+					DebugNoSecurity().
+					Return(true).
+					AnyTimes()
+			})
+			JustBeforeEach(func() {
+				myShop.Shop.IPFilter = myMockIPFilter
+			})
+			It("refuses the index to a denied ip", func() {
+				req = httptest.NewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				myMockIPFilter.EXPECT().
+					AllowIndex("192.0.2.1").
+					Return(false).
+					Times(1)
+
+				handler.ServeHTTP(writer, req)
+				Expect(writer.Code).To(Equal(http.StatusForbidden))
+			})
+			It("serves the index to an allowed ip", func() {
+				req = httptest.NewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = "192.168.1.2:1234"
+				myMockIPFilter.EXPECT().
+					AllowIndex("192.168.1.2").
+					Return(true).
+					Times(1)
+
+				handler.ServeHTTP(writer, req)
+				Expect(writer.Code).To(Equal(http.StatusOK))
+			})
+			It("uses the download policy for games", func() {
+				req = httptest.NewRequest(http.MethodGet, "/games/0100000000010000", nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				myMockIPFilter.EXPECT().
+					AllowDownload("192.0.2.1").
+					Return(false).
+					Times(1)
+
+				handler.ServeHTTP(writer, req)
+				Expect(writer.Code).To(Equal(http.StatusForbidden))
+			})
+		})
+		Context("With uauth", func() {
+			var served bool
+
+			BeforeEach(func() {
+				served = false
+				r := mux.NewRouter()
+				r.Use(myShop.TinfoilMiddleware)
+				r.HandleFunc("/{filter}/", func(w http.ResponseWriter, r *http.Request) { served = true })    // Testing purpose
+				r.HandleFunc("/games/{game}", func(w http.ResponseWriter, r *http.Request) { served = true }) // Testing purpose
+				handler = r
+				writer = httptest.NewRecorder()
+
+				myMockConfig.EXPECT().DebugNoSecurity().Return(false).AnyTimes()
+				myMockConfig.EXPECT().ForwardAuthURL().Return("").AnyTimes()
+				myMockConfig.EXPECT().IsBlacklisted(gomock.Any()).Return(false).AnyTimes()
+				myMockConfig.EXPECT().IsBannedTheme(gomock.Any()).Return(false).AnyTimes()
+				myMockConfig.EXPECT().Get_Hauth().Return("").AnyTimes()
+				myMockConfig.EXPECT().HasUauth(gomock.Any()).Return(true).AnyTimes()
+				myMockConfig.EXPECT().ShopTemplateData().Return(repository.ShopTemplate{}).AnyTimes()
+			})
+			It("checks the index against its shop url", func() {
+				req = httptest.NewRequest(http.MethodGet, "/fr/", nil)
+				req.Host = "Shop.example.com"
+				for _, header := range []string{"Theme", "Uid", "Version", "Language", "Hauth"} {
+					req.Header.Set(header, "XX")
+				}
+				req.Header.Set("Uauth", "other-url")
+				myMockConfig.EXPECT().
+					IsValidUauth("shop.example.com/fr", "other-url").
+					Return(false).
+					Times(1)
+
+				handler.ServeHTTP(writer, req)
+				Expect(served).To(BeFalse())
+			})
+			It("checks the downloads against the host", func() {
+				req = httptest.NewRequest(http.MethodGet, "/games/0100000000010000", nil)
+				req.Host = "shop.example.com"
+				req.Header.Set("Hauth", "XX")
+				req.Header.Set("Uauth", "forged")
+				req.Header.Set("Tinshop-Ng", "XX")
+				myMockConfig.EXPECT().
+					IsValidHostUauth("shop.example.com", "forged").
+					Return(false).
+					Times(1)
+
+				handler.ServeHTTP(writer, req)
+				Expect(served).To(BeFalse())
+			})
+		})
+		Context("With device registry", func() {
+			var myMockDevices *mock_repository.MockDevices
+
+			BeforeEach(func() {
+				myMockDevices = mock_repository.NewMockDevices(ctrl)
+				r := mux.NewRouter()
+				r.Use(myShop.TinfoilMiddleware)
+				r.HandleFunc("/", myShop.HomeHandler)
+				handler = r
+				writer = httptest.NewRecorder()
+
+				myMockConfig.EXPECT().
+					DebugNoSecurity().
+					Return(false).
+					AnyTimes()
+				myMockConfig.EXPECT().
+					IsBlacklisted("BLOCKED").
+					Return(false).
+					AnyTimes()
+				myMockConfig.EXPECT().
+					ShopTemplateData().
+					Return(repository.ShopTemplate{ShopTitle: "Unit Test"}).
+					AnyTimes()
+			})
+			JustBeforeEach(func() {
+				myShop.Shop.Devices = myMockDevices
+			})
+			It("refuses a switch blocked from the registry", func() {
+				req = httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Uid", "BLOCKED")
+				req.Header.Set("Theme", "THEME")
+				myMockDevices.EXPECT().
+					Seen(gomock.Any()).
+					Do(func(console repository.Switch) {
+						Expect(console.UID).To(Equal("BLOCKED"))
+						Expect(console.Theme).To(Equal("THEME"))
+					}).
+					Times(1)
+				myMockDevices.EXPECT().
+					IsBlocked("BLOCKED").
+					Return(true).
+					Times(1)
+				myMockDevices.EXPECT().
+					DisplayName("BLOCKED").
+					Return("Kid's Lite").
+					AnyTimes()
+
+				handler.ServeHTTP(writer, req)
+				Expect(writer.Code).To(Equal(http.StatusOK))
+
+				var list repository.GameType
+				Expect(json.NewDecoder(writer.Body).Decode(&list)).NotTo(Succeed())
+			})
+		})
 		Context("With security", func() {
 			BeforeEach(func() {
 				r := mux.NewRouter()
@@ -373,6 +538,26 @@ var _ = Describe("Security", func() {
 					Return(false).
 					AnyTimes()
 
+				myMockConfig.EXPECT().
+					Get_Hauth().
+					Return("").
+					AnyTimes()
+
+				myMockConfig.EXPECT().
+					IsValidUauth(gomock.Any(), gomock.Any()).
+					Return(true).
+					AnyTimes()
+
+				myMockConfig.EXPECT().
+					IsValidHostUauth(gomock.Any(), gomock.Any()).
+					Return(true).
+					AnyTimes()
+
+				myMockConfig.EXPECT().
+					HasUauth(gomock.Any()).
+					Return(false).
+					AnyTimes()
+
 				shopTemplateData := &repository.ShopTemplate{
 					ShopTitle: "Unit Test",
 				}
@@ -419,4 +604,182 @@ var _ = Describe("Security", func() {
 			)
 		})
 	})
+	Describe("HttpAuthCheck", func() {
+		var (
+			myMockConfig *mock_repository.MockConfig
+			myShop       *main.TinShop
+			req          *http.Request
+		)
+
+		BeforeEach(func() {
+			ctrl := gomock.NewController(GinkgoT())
+			myMockConfig = mock_repository.NewMockConfig(ctrl)
+			myShop = &main.TinShop{}
+			myShop.Shop.Config = myMockConfig
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+
+			hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+			Expect(err).NotTo(HaveOccurred())
+			myMockConfig.EXPECT().Get_Httpauth().Return([]string{"broken", "admin:" + string(hash)}).AnyTimes()
+		})
+
+		It("Accepts the credentials of the config", func() {
+			Expect(myShop.HttpAuthCheck("admin", "secret", req)).To(BeTrue())
+		})
+		It("Ignores an entry without password", func() {
+			Expect(myShop.HttpAuthCheck("broken", "", req)).To(BeFalse())
+			Expect(myShop.HttpAuthCheck("admin", "wrong", req)).To(BeFalse())
+		})
+		Context("With accounts", func() {
+			var myMockUsers *mock_repository.MockUsers
+
+			BeforeEach(func() {
+				myMockUsers = mock_repository.NewMockUsers(gomock.NewController(GinkgoT()))
+				myMockUsers.EXPECT().Count().Return(1).AnyTimes()
+				myShop.Shop.Users = myMockUsers
+				myMockConfig.EXPECT().CatalogEnabled().Return(true).AnyTimes()
+				myMockConfig.EXPECT().CatalogPath().Return("/catalog").AnyTimes()
+			})
+
+			It("Lets an account download outside of its collections", func() {
+				myMockUsers.EXPECT().Authenticate("kid", "secret", "").Return(repository.User{Name: "kid", Collections: []string{"fr"}}, nil).Times(2)
+
+				Expect(myShop.HttpAuthCheck("kid", "secret", httptest.NewRequest(http.MethodGet, "/games/0100000000010000", nil))).To(BeTrue())
+				Expect(myShop.HttpAuthCheck("kid", "secret", httptest.NewRequest(http.MethodGet, "/multi", nil))).To(BeFalse())
+			})
+			It("Lets an account browse the catalog without a device", func() {
+				myMockUsers.EXPECT().Check("kid", "secret").Return(repository.User{Name: "kid", MaxDevices: 1, Collections: []string{"fr"}}, nil)
+
+				Expect(myShop.HttpAuthCheck("kid", "secret", httptest.NewRequest(http.MethodGet, "/catalog/", nil))).To(BeTrue())
+			})
+			It("Refuses a download to a disabled account", func() {
+				myMockUsers.EXPECT().Authenticate("kid", "secret", "").Return(repository.User{}, errors.New("account disabled"))
+
+				Expect(myShop.HttpAuthCheck("kid", "secret", httptest.NewRequest(http.MethodGet, "/games/0100000000010000", nil))).To(BeFalse())
+			})
+		})
+	})
+	Describe("RequireScope", func() {
+		var (
+			writer        *httptest.ResponseRecorder
+			myMockTokens  *mock_repository.MockTokens
+			ctrl          *gomock.Controller
+			myShop        *main.TinShop
+			handler       http.Handler
+			handlerCalled bool
+		)
+
+		BeforeEach(func() {
+			ctrl = gomock.NewController(GinkgoT())
+			myMockTokens = mock_repository.NewMockTokens(ctrl)
+			myShop = &main.TinShop{}
+			myShop.Shop.Tokens = myMockTokens
+			handlerCalled = false
+			handler = myShop.RequireScope(repository.ScopeReadStats, func(w http.ResponseWriter, r *http.Request) {
+				handlerCalled = true
+			})
+			writer = httptest.NewRecorder()
+		})
+
+		It("Refuse request without bearer token", func() {
+			req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+			req.SetBasicAuth("admin", "admin")
+			handler.ServeHTTP(writer, req)
+
+			Expect(writer.Code).To(Equal(http.StatusUnauthorized))
+			Expect(handlerCalled).To(BeFalse())
+		})
+		It("Refuse invalid token", func() {
+			myMockTokens.EXPECT().
+				Verify("tsk_bad_token").
+				Return(repository.APIToken{}, errors.New("invalid api token")).
+				Times(1)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+			req.Header.Set("Authorization", "Bearer tsk_bad_token")
+			handler.ServeHTTP(writer, req)
+
+			Expect(writer.Code).To(Equal(http.StatusUnauthorized))
+			Expect(handlerCalled).To(BeFalse())
+		})
+		It("Refuse token without the scope", func() {
+			myMockTokens.EXPECT().
+				Verify("tsk_good_token").
+				Return(repository.APIToken{Name: "reload only", Scopes: []string{repository.ScopeReload}}, nil).
+				Times(1)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+			req.Header.Set("Authorization", "Bearer tsk_good_token")
+			handler.ServeHTTP(writer, req)
+
+			Expect(writer.Code).To(Equal(http.StatusForbidden))
+			Expect(handlerCalled).To(BeFalse())
+		})
+		It("Accept token with the scope", func() {
+			myMockTokens.EXPECT().
+				Verify("tsk_good_token").
+				Return(repository.APIToken{Name: "stats", Scopes: []string{repository.ScopeReadStats}}, nil).
+				Times(1)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+			req.Header.Set("Authorization", "Bearer tsk_good_token")
+			handler.ServeHTTP(writer, req)
+
+			Expect(writer.Code).To(Equal(http.StatusOK))
+			Expect(handlerCalled).To(BeTrue())
+		})
+		It("Refuse to create a token with more scopes than the caller", func() {
+			myMockTokens.EXPECT().
+				Verify("tsk_users_token").
+				Return(repository.APIToken{Name: "users", Scopes: []string{repository.ScopeManageUsers}}, nil).
+				Times(2)
+			myMockTokens.EXPECT().
+				Create("helper", []string{repository.ScopeManageUsers}).
+				Return(repository.APIToken{Name: "helper"}, "tsk_new", nil).
+				Times(1)
+			myMockAPI := mock_repository.NewMockAPI(ctrl)
+			myMockAPI.EXPECT().NewToken(gomock.Any(), gomock.Any(), "tsk_new").Times(1)
+			myShop.Shop.API = myMockAPI
+			handler = myShop.RequireScope(repository.ScopeManageUsers, myShop.TokensHandler)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/tokens", strings.NewReader(`{"name": "admin", "scopes": ["manage-users", "reload"]}`))
+			req.Header.Set("Authorization", "Bearer tsk_users_token")
+			handler.ServeHTTP(writer, req)
+			Expect(writer.Code).To(Equal(http.StatusForbidden))
+
+			writer = httptest.NewRecorder()
+			req = httptest.NewRequest(http.MethodPost, "/api/tokens", strings.NewReader(`{"name": "helper", "scopes": ["manage-users"]}`))
+			req.Header.Set("Authorization", "Bearer tsk_users_token")
+			handler.ServeHTTP(writer, req)
+			Expect(writer.Code).To(Equal(http.StatusOK))
+		})
+		It("Refuse to revoke a token with more scopes than the caller", func() {
+			myMockTokens.EXPECT().
+				Verify("tsk_users_token").
+				Return(repository.APIToken{Name: "users", Scopes: []string{repository.ScopeManageUsers}}, nil).
+				Times(2)
+			myMockTokens.EXPECT().
+				List().
+				Return([]repository.APIToken{
+					{ID: "admin", Scopes: []string{repository.ScopeManageUsers, repository.ScopeReload}},
+					{ID: "helper", Scopes: []string{repository.ScopeManageUsers}},
+				}, nil).
+				Times(2)
+			myMockTokens.EXPECT().Revoke("helper").Return(nil).Times(1)
+			handler = myShop.RequireScope(repository.ScopeManageUsers, myShop.TokenHandler)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/tokens/admin", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "admin"})
+			req.Header.Set("Authorization", "Bearer tsk_users_token")
+			handler.ServeHTTP(writer, req)
+			Expect(writer.Code).To(Equal(http.StatusForbidden))
+
+			writer = httptest.NewRecorder()
+			req = httptest.NewRequest(http.MethodDelete, "/api/tokens/helper", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": "helper"})
+			req.Header.Set("Authorization", "Bearer tsk_users_token")
+			handler.ServeHTTP(writer, req)
+			Expect(writer.Code).To(Equal(http.StatusNoContent))
+		})
+	})
 })
@@ -1,6 +1,7 @@
 package directory
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -9,6 +10,8 @@ import (
 	"time"
 
 	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/scan"
+	"github.com/ajmandourah/tinshop-ng/utils"
 	"gopkg.in/fsnotify.v1"
 )
 
@@ -16,16 +19,25 @@ type directorySource struct {
 	gameFiles          []repository.FileDesc
 	collection         repository.Collection
 	config             repository.Config
+	tracker            repository.ScanTracker
 	watcherDirectories *fsnotify.Watcher
+	watched            map[string]bool
+	watchedMutex       sync.Mutex
 	mutex		   sync.Mutex
+	failedFiles        []repository.FailedFile
+	failedMutex        sync.Mutex
 }
 
-// New create a directory source
-func New(collection repository.Collection, config repository.Config) repository.Source {
+// New create a directory source, the tracker follows the files loaded
+func New(collection repository.Collection, config repository.Config, tracker repository.ScanTracker) repository.Source {
+	if tracker == nil {
+		tracker = scan.None
+	}
 	return &directorySource{
 		gameFiles:  make([]repository.FileDesc, 0),
 		collection: collection,
 		config:     config,
+		tracker:    tracker,
 	}
 }
 
@@ -50,6 +62,10 @@ func (src *directorySource) Load(directories []string, uniqueSource bool) {
 	for _, directory := range directories {
 		err := src.loadGamesDirectory(directory)
 
+		if errors.Is(err, errCancelled) {
+			log.Println("Scan cancelled while loading", directory)
+			return
+		}
 		if err != nil {
 			if strings.Contains(err.Error(), "no such file or directory") {
 				if len(directories) == 1 && uniqueSource {
@@ -64,7 +80,15 @@ func (src *directorySource) Load(directories []string, uniqueSource bool) {
 
 func (src *directorySource) Reset() {
 	src.watcherDirectories = src.newWatcher()
+	src.watchedMutex.Lock()
+	src.watched = make(map[string]bool)
+	src.watchedMutex.Unlock()
+	src.mutex.Lock()
 	src.gameFiles = make([]repository.FileDesc, 0)
+	src.mutex.Unlock()
+	src.failedMutex.Lock()
+	src.failedFiles = make([]repository.FailedFile, 0)
+	src.failedMutex.Unlock()
 }
 
 func (src *directorySource) UnWatchAll() {
@@ -72,5 +96,68 @@ func (src *directorySource) UnWatchAll() {
 }
 
 func (src *directorySource) GetFiles() []repository.FileDesc {
+	src.mutex.Lock()
+	defer src.mutex.Unlock()
 	return src.gameFiles
 }
+
+// FailedFiles returns the game files which could not be added
+func (src *directorySource) FailedFiles() []repository.FailedFile {
+	src.failedMutex.Lock()
+	defer src.failedMutex.Unlock()
+	return append([]repository.FailedFile(nil), src.failedFiles...)
+}
+
+// ReplacePath swaps the files found under path with the files of a new scan
+func (src *directorySource) ReplacePath(path string, scanned repository.Source) {
+	files := scanned.GetFiles()
+	src.mutex.Lock()
+	gameFiles := make([]repository.FileDesc, 0, len(src.gameFiles)+len(files))
+	for _, file := range src.gameFiles {
+		if !utils.IsInPath(path, file.Path) {
+			gameFiles = append(gameFiles, file)
+		}
+	}
+	src.gameFiles = append(gameFiles, files...)
+	src.mutex.Unlock()
+
+	src.failedMutex.Lock()
+	src.failedFiles = replaceFailedFiles(src.failedFiles, path, scanned.FailedFiles())
+	src.failedMutex.Unlock()
+}
+
+// RemovePath drops the files found under path and stops watching its directories
+func (src *directorySource) RemovePath(path string) {
+	src.watchedMutex.Lock()
+	for directory := range src.watched {
+		if utils.IsInPath(path, directory) {
+			_ = src.watcherDirectories.Remove(directory)
+			delete(src.watched, directory)
+		}
+	}
+	src.watchedMutex.Unlock()
+
+	src.ReplacePath(path, New(src.collection, src.config, nil))
+}
+
+func replaceFailedFiles(failedFiles []repository.FailedFile, path string, scanned []repository.FailedFile) []repository.FailedFile {
+	kept := make([]repository.FailedFile, 0, len(failedFiles)+len(scanned))
+	for _, file := range failedFiles {
+		if !utils.IsInPath(path, file.Path) {
+			kept = append(kept, file)
+		}
+	}
+	return append(kept, scanned...)
+}
+
+// Reachable checks the directory exists and can be read
+func Reachable(directory string) error {
+	info, err := os.Stat(directory)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New(directory + " is not a directory")
+	}
+	return nil
+}
@@ -14,6 +14,9 @@ import (
 	"github.com/charlievieth/fastwalk"
 )
 
+// errCancelled stops walking a directory when the scan is cancelled
+var errCancelled = errors.New("scan cancelled")
+
 func (src *directorySource) removeGamesWatcherDirectories() {
 	log.Println("Removing watcher from all directories")
 	if src.watcherDirectories != nil {
@@ -21,21 +24,42 @@ func (src *directorySource) removeGamesWatcherDirectories() {
 	}
 }
 
+// fileFailed keeps the game file which could not be added to the library
+func (src *directorySource) fileFailed(path string, reason string) {
+	src.tracker.FileFailed()
+	src.failedMutex.Lock()
+	defer src.failedMutex.Unlock()
+	src.failedFiles = append(src.failedFiles, repository.FailedFile{Path: path, Source: repository.LocalFile, Reason: reason})
+}
+
 func (src *directorySource) removeEntriesFromDirectory(directory string) {
 	log.Println("removeEntriesFromDirectory", directory)
-	for index, game := range src.gameFiles {
+	src.failedMutex.Lock()
+	src.failedFiles = replaceFailedFiles(src.failedFiles, directory, nil)
+	src.failedMutex.Unlock()
+
+	// Need to remove games, the list is replaced as GetFiles callers may still read it
+	src.mutex.Lock()
+	gameFiles := make([]repository.FileDesc, 0, len(src.gameFiles))
+	removed := make([]repository.FileDesc, 0)
+	for _, game := range src.gameFiles {
 		if game.HostType == repository.LocalFile && strings.Contains(game.Path, directory) {
-			// Need to remove game
-			src.gameFiles = utils.RemoveFileDesc(src.gameFiles, index)
-
-			// Stop watching of directories
-			if directory == filepath.Dir(directory) {
-				_ = src.watcherDirectories.Remove(filepath.Dir(game.Path))
-			}
+			removed = append(removed, game)
+		} else {
+			gameFiles = append(gameFiles, game)
+		}
+	}
+	src.gameFiles = gameFiles
+	src.mutex.Unlock()
 
-			// Remove entry from collection
-			src.collection.RemoveGame(game.GameID)
+	for _, game := range removed {
+		// Stop watching of directories
+		if directory == filepath.Dir(directory) {
+			_ = src.watcherDirectories.Remove(filepath.Dir(game.Path))
 		}
+
+		// Remove entry from collection
+		src.collection.RemoveGame(game.GameID)
 	}
 }
 
@@ -44,6 +68,7 @@ func (src *directorySource) addDirectoryGame(gameFiles []repository.FileDesc, ex
 	newGameFiles = append(newGameFiles, gameFiles...)
 
 	if extension == ".nsp" || extension == ".nsz" || extension == ".xci" {
+		src.tracker.FileSeen()
 		newFile := repository.FileDesc{Size: size, Path: path}
 		names, decrypted := utils.ExtractGameID(path)
 		//Rename the file if decrypted and option is enabled
@@ -73,14 +98,18 @@ func (src *directorySource) addDirectoryGame(gameFiles []repository.FileDesc, ex
 				valid, errTicket := src.nspCheck(newFile)
 				if valid || (errTicket != nil && errTicket.Error() == "TitleDBKey for game "+newFile.GameID+" is not found") {
 					newGameFiles = append(newGameFiles, newFile)
+					src.tracker.FileIdentified()
 				} else {
 					log.Println(errTicket)
+					src.fileFailed(path, errTicket.Error())
 				}
 			} else {
 				newGameFiles = append(newGameFiles, newFile)
+				src.tracker.FileIdentified()
 			}
 		} else {
 			log.Println("Ignoring file because parsing failed", path)
+			src.fileFailed(path, "file name could not be parsed")
 		}
 	}
 
@@ -102,6 +131,9 @@ func (src *directorySource) loadGamesDirectory(directory string) error {
 			if err != nil {
 				return err
 			}
+			if src.tracker.Cancelled() {
+				return errCancelled
+			}
 			if !info.IsDir() {
 				extension := filepath.Ext(info.Name())
 				fileInfo, err := info.Info()
@@ -126,7 +158,9 @@ func (src *directorySource) loadGamesDirectory(directory string) error {
 	if err != nil {
 		return err
 	}
+	src.mutex.Lock()
 	src.gameFiles = append(src.gameFiles, newGameFiles...)
+	src.mutex.Unlock()
 	// Add all files
 	if len(newGameFiles) > 0 {
 		src.collection.AddNewGames(newGameFiles)
@@ -19,6 +19,12 @@ func (src *directorySource) newWatcher() *fsnotify.Watcher {
 }
 
 func (src *directorySource) watchDirectory(directory string) {
+	src.watchedMutex.Lock()
+	if src.watched != nil {
+		src.watched[directory] = true
+	}
+	src.watchedMutex.Unlock()
+
 	initWG := sync.WaitGroup{}
 	initWG.Add(1)
 	go func() {
@@ -37,7 +43,9 @@ func (src *directorySource) watchDirectory(directory string) {
 
 					if event.Op&fsnotify.Create != 0 {
 						newGames := src.addDirectoryGame(make([]repository.FileDesc, 0), filepath.Ext(event.Name), 0, event.Name)
+						src.mutex.Lock()
 						src.gameFiles = append(src.gameFiles, newGames...)
+						src.mutex.Unlock()
 						src.collection.AddNewGames(newGames)
 					} else if event.Op&fsnotify.Remove != 0 {
 						src.removeEntriesFromDirectory(event.Name)
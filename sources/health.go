@@ -0,0 +1,141 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/sources/directory"
+	"github.com/ajmandourah/tinshop-ng/sources/nfs"
+)
+
+const (
+	// healthTimeout bounds the time given to a source to answer
+	healthTimeout = 5 * time.Second
+	// healthCacheTime is how long the result of a check is reused, probes are frequent
+	healthCacheTime = 10 * time.Second
+)
+
+// reachable tells if a configured source can be accessed, giving up when the context ends
+type reachable func(context.Context, string) error
+
+// probe is the last check of a source, a source is never checked twice at the same time
+// so a source which does not answer does not pile up the checks
+type probe struct {
+	mutex   sync.Mutex
+	err     error
+	checked time.Time
+	running chan struct{}
+}
+
+// Health checks the initial scan is complete and every configured source is reachable.
+// The checks never hold the paths of the sources, only their position in the configuration.
+func (s *allSources) Health() []repository.HealthCheck {
+	s.mutex.RLock()
+	cfg := s.config
+	s.mutex.RUnlock()
+
+	if cfg == nil {
+		return []repository.HealthCheck{{Name: "scan", Status: repository.HealthFailed, Message: "initial scan not complete"}}
+	}
+
+	checks := []repository.HealthCheck{{
+		Name:    "scan",
+		Status:  repository.HealthOK,
+		Message: fmt.Sprintf("%d files", len(s.GetFiles())),
+	}}
+
+	directories := cfg.Directories()
+	shares := cfg.NfsShares()
+	results := make([]repository.HealthCheck, len(directories)+len(shares))
+	var wg sync.WaitGroup
+	for i, dir := range directories {
+		wg.Add(1)
+		go func(i int, dir string) {
+			defer wg.Done()
+			results[i] = s.checkSource(fmt.Sprintf("directory.%d", i), dir, reachableDirectory)
+		}(i, dir)
+	}
+	for i, share := range shares {
+		wg.Add(1)
+		go func(i int, share string) {
+			defer wg.Done()
+			results[len(directories)+i] = s.checkSource(fmt.Sprintf("nfs.%d", i), share, nfs.Reachable)
+		}(i, share)
+	}
+	wg.Wait()
+
+	return append(checks, results...)
+}
+
+// reachableDirectory checks a directory, a local check cannot be interrupted
+func reachableDirectory(_ context.Context, dir string) error {
+	return directory.Reachable(dir)
+}
+
+// probe returns the probe of the source
+func (s *allSources) probe(source string) *probe {
+	s.probesMutex.Lock()
+	defer s.probesMutex.Unlock()
+	if s.probes == nil {
+		s.probes = make(map[string]*probe)
+	}
+	if _, ok := s.probes[source]; !ok {
+		s.probes[source] = &probe{}
+	}
+	return s.probes[source]
+}
+
+// checkSource returns the recent result of the source or checks it within healthTimeout
+func (s *allSources) checkSource(name string, source string, check reachable) repository.HealthCheck {
+	p := s.probe(name + "|" + source)
+
+	p.mutex.Lock()
+	if !p.checked.IsZero() && time.Since(p.checked) < healthCacheTime {
+		err := p.err
+		p.mutex.Unlock()
+		return sourceHealth(name, err)
+	}
+	if p.running == nil {
+		running := make(chan struct{})
+		p.running = running
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+			defer cancel()
+			err := check(ctx, source)
+			if err != nil {
+				log.Printf("[Health] Source %s (%s) is not reachable: %s\n", name, source, err)
+			}
+
+			p.mutex.Lock()
+			p.err = err
+			p.checked = time.Now()
+			p.running = nil
+			p.mutex.Unlock()
+			close(running)
+		}()
+	}
+	running := p.running
+	p.mutex.Unlock()
+
+	select {
+	case <-running:
+		p.mutex.Lock()
+		err := p.err
+		p.mutex.Unlock()
+		return sourceHealth(name, err)
+	case <-time.After(healthTimeout):
+		log.Printf("[Health] Source %s (%s) did not answer in %s\n", name, source, healthTimeout)
+		return repository.HealthCheck{Name: name, Status: repository.HealthFailed, Message: "timeout"}
+	}
+}
+
+func sourceHealth(name string, err error) repository.HealthCheck {
+	if err != nil {
+		return repository.HealthCheck{Name: name, Status: repository.HealthFailed, Message: "not reachable"}
+	}
+	return repository.HealthCheck{Name: name, Status: repository.HealthOK}
+}
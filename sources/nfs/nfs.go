@@ -1,14 +1,21 @@
 package nfs
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/scan"
+	"github.com/ajmandourah/tinshop-ng/utils"
+	"github.com/vmware/go-nfs-client/nfs"
+	"github.com/vmware/go-nfs-client/nfs/rpc"
 	"github.com/vmware/go-nfs-client/nfs/util"
 )
 
@@ -16,14 +23,23 @@ type nfsSource struct {
 	gameFiles  []repository.FileDesc
 	collection repository.Collection
 	config     repository.Config
+	tracker    repository.ScanTracker
+	mutex      sync.Mutex
+
+	failedFiles []repository.FailedFile
+	failedMutex sync.Mutex
 }
 
-// New create a nfs source
-func New(collection repository.Collection, config repository.Config) repository.Source {
+// New create a nfs source, the tracker follows the files loaded
+func New(collection repository.Collection, config repository.Config, tracker repository.ScanTracker) repository.Source {
+	if tracker == nil {
+		tracker = scan.None
+	}
 	return &nfsSource{
 		gameFiles:  make([]repository.FileDesc, 0),
 		collection: collection,
 		config:     config,
+		tracker:    tracker,
 	}
 }
 
@@ -101,11 +117,18 @@ func (src *nfsSource) Download(w http.ResponseWriter, r *http.Request, _ string,
 }
 func (src *nfsSource) Load(shares []string, _ bool) {
 	for _, share := range shares {
+		if src.tracker.Cancelled() {
+			log.Println("Scan cancelled before loading", share)
+			return
+		}
 		src.loadGamesNfs(share)
 	}
 }
 func (src *nfsSource) Reset() {
 	src.gameFiles = make([]repository.FileDesc, 0)
+	src.failedMutex.Lock()
+	src.failedFiles = make([]repository.FailedFile, 0)
+	src.failedMutex.Unlock()
 }
 
 func (src *nfsSource) UnWatchAll() {
@@ -113,5 +136,92 @@ func (src *nfsSource) UnWatchAll() {
 }
 
 func (src *nfsSource) GetFiles() []repository.FileDesc {
+	src.mutex.Lock()
+	defer src.mutex.Unlock()
 	return src.gameFiles
 }
+
+// FailedFiles returns the game files which could not be added
+func (src *nfsSource) FailedFiles() []repository.FailedFile {
+	src.failedMutex.Lock()
+	defer src.failedMutex.Unlock()
+	return append([]repository.FailedFile(nil), src.failedFiles...)
+}
+
+// RemovePath drops the files found under the share
+func (src *nfsSource) RemovePath(share string) {
+	src.ReplacePath(share, New(src.collection, src.config, nil))
+}
+
+// ReplacePath swaps the files found under path with the files of a new scan
+func (src *nfsSource) ReplacePath(path string, scanned repository.Source) {
+	files := scanned.GetFiles()
+	src.mutex.Lock()
+	gameFiles := make([]repository.FileDesc, 0, len(src.gameFiles)+len(files))
+	for _, file := range src.gameFiles {
+		if !utils.IsInPath(path, file.Path) {
+			gameFiles = append(gameFiles, file)
+		}
+	}
+	src.gameFiles = append(gameFiles, files...)
+	src.mutex.Unlock()
+
+	src.failedMutex.Lock()
+	defer src.failedMutex.Unlock()
+	kept := make([]repository.FailedFile, 0, len(src.failedFiles))
+	for _, file := range src.failedFiles {
+		if !utils.IsInPath(path, file.Path) {
+			kept = append(kept, file)
+		}
+	}
+	src.failedFiles = append(kept, scanned.FailedFiles()...)
+}
+
+// portmapperPort is where the nfs client asks the port of the MOUNT service
+const portmapperPort = "111"
+
+// Reachable checks the nfs share can be mounted, the nfs client has no timeout
+// so the host is dialed first and the connection is closed once the context ends
+func Reachable(ctx context.Context, share string) error {
+	host, target, err := getHostTarget(share)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, portmapperPort))
+	if err != nil {
+		return fmt.Errorf("unable to reach the portmapper: %w", err)
+	}
+	_ = conn.Close()
+
+	mount, err := nfs.DialMount(host)
+	if err != nil {
+		return fmt.Errorf("unable to dial MOUNT service: %w", err)
+	}
+	// The mount is closed by the context or once checked, whichever comes first
+	var closeOnce sync.Once
+	closeMount := func() { closeOnce.Do(func() { _ = mount.Close() }) }
+	defer closeMount()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			closeMount()
+		case <-done:
+		}
+	}()
+
+	v, err := mount.Mount(target, rpc.AuthNull)
+	if err != nil {
+		return fmt.Errorf("unable to mount volume: %w", err)
+	}
+	errClose := v.Close()
+	// Remove the entry of the server, the check runs again and again
+	if err := mount.Unmount(); err != nil {
+		return fmt.Errorf("unable to unmount volume: %w", err)
+	}
+	return errClose
+}
@@ -45,7 +45,12 @@ func (src *nfsSource) loadGamesNfs(share string) {
 	nfsGames := src.lookIntoNfsDirectory(v, share, ".")
 
 	mount.Close()
+	if src.tracker.Cancelled() {
+		return
+	}
+	src.mutex.Lock()
 	src.gameFiles = append(src.gameFiles, nfsGames...)
+	src.mutex.Unlock()
 
 	// Add all files
 	if len(nfsGames) > 0 {
@@ -81,6 +86,10 @@ func (src *nfsSource) lookIntoNfsDirectory(v *nfs.Target, share, path string) []
 	var newGameFiles []repository.FileDesc
 
 	for _, dir := range dirs {
+		if src.tracker.Cancelled() {
+			return newGameFiles
+		}
+
 		// Handle recursive directories
 		if dir.IsDir() && dir.FileName != "." && dir.FileName != ".." {
 			subDirGameFiles := src.lookIntoNfsDirectory(v, share, computePath(path, dir))
@@ -94,6 +103,7 @@ func (src *nfsSource) lookIntoNfsDirectory(v *nfs.Target, share, path string) []
 			continue
 		}
 
+		src.tracker.FileSeen()
 		nfsRootPath := computeFullPath(share, path)
 		newFile := repository.FileDesc{Size: dir.Size(), Path: nfsRootPath + "/" + dir.FileName}
 		names, _ := utils.ExtractGameID(dir.FileName)
@@ -101,6 +111,7 @@ func (src *nfsSource) lookIntoNfsDirectory(v *nfs.Target, share, path string) []
 		if names.ShortID() == "" {
 			// Useful to rename you file according to readme
 			log.Println("Ignoring file because parsing failed", dir.FileName)
+			src.fileFailed(newFile.Path, "file name could not be parsed")
 			continue
 		}
 		newFile.GameID = names.ShortID()
@@ -115,14 +126,24 @@ func (src *nfsSource) lookIntoNfsDirectory(v *nfs.Target, share, path string) []
 		}
 		if valid || (errTicket != nil && errTicket.Error() == "TitleDBKey for game "+newFile.GameID+" is not found") {
 			newGameFiles = append(newGameFiles, newFile)
+			src.tracker.FileIdentified()
 		} else {
 			log.Println(errTicket)
+			src.fileFailed(newFile.Path, errTicket.Error())
 		}
 	}
 
 	return newGameFiles
 }
 
+// fileFailed keeps the game file which could not be added to the library
+func (src *nfsSource) fileFailed(path string, reason string) {
+	src.tracker.FileFailed()
+	src.failedMutex.Lock()
+	defer src.failedMutex.Unlock()
+	src.failedFiles = append(src.failedFiles, repository.FailedFile{Path: path, Source: repository.NFSShare, Reason: reason})
+}
+
 func computeFullPath(share, path string) string {
 	nfsRootPath := share
 	if path != "." {
@@ -0,0 +1,222 @@
+package sources
+
+import (
+	"errors"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/events"
+	"github.com/ajmandourah/tinshop-ng/metrics"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/scan"
+	"github.com/ajmandourah/tinshop-ng/sources/directory"
+	"github.com/ajmandourah/tinshop-ng/sources/nfs"
+	"github.com/ajmandourah/tinshop-ng/utils"
+)
+
+var (
+	// ErrScanRunning is returned when a scan is asked while another one is running
+	ErrScanRunning = errors.New("a scan is already running")
+	// ErrUnknownTarget is returned when the scan target is not a known source
+	ErrUnknownTarget = errors.New("unknown scan target")
+	// ErrPathOutside is returned when the path to scan is not inside a configured directory
+	ErrPathOutside = errors.New("path is not inside a configured directory")
+	// ErrNotLoaded is returned when a scan is asked before the sources are loaded
+	ErrNotLoaded = errors.New("sources are not loaded yet")
+)
+
+// Rescan starts a scan of the target in background, the current files are
+// served until the scan is over
+func (s *allSources) Rescan(target string, path string) (repository.ScanStatus, error) {
+	s.scanMutex.Lock()
+	defer s.scanMutex.Unlock()
+
+	if s.scan != nil && s.scan.Running() {
+		return s.scan.Status(), ErrScanRunning
+	}
+
+	s.mutex.RLock()
+	cfg := s.config
+	s.mutex.RUnlock()
+	if cfg == nil {
+		return repository.ScanStatus{}, ErrNotLoaded
+	}
+
+	if target == "" {
+		target = repository.ScanAll
+	}
+	switch target {
+	case repository.ScanAll, repository.ScanDirectory, repository.ScanNFS:
+		path = ""
+	case repository.ScanPath:
+		path = filepath.Clean(path)
+		if !isInDirectories(cfg.Directories(), path) {
+			return repository.ScanStatus{}, ErrPathOutside
+		}
+	default:
+		return repository.ScanStatus{}, ErrUnknownTarget
+	}
+
+	s.scanID++
+	progress := scan.New(s.scanID, target, path)
+	s.scan = progress
+	status := progress.Status()
+	events.Publish(repository.EventScanStarted, status)
+	go s.runScan(cfg, progress)
+
+	return status, nil
+}
+
+// ScanStatus returns the progress of the last scan
+func (s *allSources) ScanStatus() repository.ScanStatus {
+	s.scanMutex.Lock()
+	defer s.scanMutex.Unlock()
+
+	if s.scan == nil {
+		return repository.ScanStatus{}
+	}
+	return s.scan.Status()
+}
+
+// CancelScan stops the running scan, the current files are kept
+func (s *allSources) CancelScan() bool {
+	s.scanMutex.Lock()
+	defer s.scanMutex.Unlock()
+
+	if s.scan == nil {
+		return false
+	}
+	return s.scan.Cancel()
+}
+
+func (s *allSources) runScan(cfg repository.Config, progress *scan.Progress) {
+	defer func() {
+		progress.Finish()
+		events.Publish(repository.EventScanFinished, progress.Status())
+	}()
+
+	status := progress.Status()
+	log.Printf("[Scan] Scan %d of '%s' started\n", status.ID, status.Target)
+	staging := &stagingCollection{Collection: s.collection}
+
+	if status.Target == repository.ScanPath {
+		s.scanPath(cfg, staging, progress, status.Path)
+	} else {
+		s.scanSources(cfg, staging, progress, status.Target)
+	}
+
+	status = progress.Status()
+	log.Printf("[Scan] Scan %d ended: %d seen, %d identified, %d failed\n", status.ID, status.Seen, status.Identified, status.Failed)
+}
+
+func (s *allSources) scanSources(cfg repository.Config, staging *stagingCollection, progress *scan.Progress, target string) {
+	var srcDirectories, srcNFS repository.Source
+
+	if target == repository.ScanAll || target == repository.ScanDirectory {
+		start := time.Now()
+		srcDirectories = directory.New(staging, cfg, progress)
+		srcDirectories.Reset()
+		srcDirectories.Load(cfg.Directories(), false)
+		metrics.ScanDuration.WithLabelValues(string(repository.LocalFile)).Set(time.Since(start).Seconds())
+	}
+	if target == repository.ScanAll || target == repository.ScanNFS {
+		start := time.Now()
+		srcNFS = nfs.New(staging, cfg, progress)
+		srcNFS.Reset()
+		srcNFS.Load(cfg.NfsShares(), false)
+		metrics.ScanDuration.WithLabelValues(string(repository.NFSShare)).Set(time.Since(start).Seconds())
+	}
+
+	if !progress.Commit() {
+		log.Println("[Scan] Scan cancelled, keeping the current library")
+		for _, src := range []repository.Source{srcDirectories, srcNFS} {
+			if src != nil {
+				src.UnWatchAll()
+			}
+		}
+		return
+	}
+
+	s.mutex.Lock()
+	if srcDirectories != nil {
+		if s.sourcesProvider.Directory != nil {
+			s.sourcesProvider.Directory.UnWatchAll()
+		}
+		s.sourcesProvider.Directory = srcDirectories
+	}
+	if srcNFS != nil {
+		if s.sourcesProvider.NFS != nil {
+			s.sourcesProvider.NFS.UnWatchAll()
+		}
+		s.sourcesProvider.NFS = srcNFS
+	}
+	s.mutex.Unlock()
+
+	staging.commit()
+	s.collection.ReplaceGames(s.GetFiles())
+}
+
+func (s *allSources) scanPath(cfg repository.Config, staging *stagingCollection, progress *scan.Progress, path string) {
+	srcPath := directory.New(staging, cfg, progress)
+	srcPath.Reset()
+	srcPath.Load([]string{path}, false)
+	// The directory source already watches this path
+	srcPath.UnWatchAll()
+
+	if !progress.Commit() {
+		log.Println("[Scan] Scan cancelled, keeping the current library")
+		return
+	}
+
+	s.mutex.RLock()
+	srcDirectories := s.sourcesProvider.Directory
+	s.mutex.RUnlock()
+	if srcDirectories == nil {
+		return
+	}
+	srcDirectories.ReplacePath(path, srcPath)
+	s.collection.ReplaceGames(s.GetFiles())
+}
+
+func isInDirectories(directories []string, path string) bool {
+	for _, directory := range directories {
+		if utils.IsInPath(directory, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// stagingCollection keeps the games found by a scan away from the collection
+// until the scan is committed, then the watchers update the collection
+type stagingCollection struct {
+	repository.Collection
+	mutex     sync.RWMutex
+	committed bool
+}
+
+func (c *stagingCollection) commit() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.committed = true
+}
+
+func (c *stagingCollection) isCommitted() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.committed
+}
+
+func (c *stagingCollection) AddNewGames(files []repository.FileDesc) {
+	if c.isCommitted() {
+		c.Collection.AddNewGames(files)
+	}
+}
+
+func (c *stagingCollection) RemoveGame(gameID string) {
+	if c.isCommitted() {
+		c.Collection.RemoveGame(gameID)
+	}
+}
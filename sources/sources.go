@@ -8,8 +8,12 @@ package sources
 import (
 	"log"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/ajmandourah/tinshop-ng/metrics"
 	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/scan"
 	"github.com/ajmandourah/tinshop-ng/sources/directory"
 	"github.com/ajmandourah/tinshop-ng/sources/nfs"
 	"github.com/ajmandourah/tinshop-ng/utils"
@@ -22,8 +26,18 @@ type SourceProvider struct {
 }
 
 type allSources struct {
+	mutex           sync.RWMutex
 	sourcesProvider SourceProvider
 	collection      repository.Collection
+	config          repository.Config
+	state           sourcesState
+
+	scanMutex sync.Mutex
+	scan      *scan.Progress
+	scanID    int
+
+	probesMutex sync.Mutex
+	probes      map[string]*probe
 }
 
 // New create a new collection
@@ -33,35 +47,145 @@ func New(collection repository.Collection) repository.Sources {
 	}
 }
 
-// OnConfigUpdate from all sources
+// sourcesState is what the files of the sources depend on in the configuration,
+// kept as the configuration is updated in place
+type sourcesState struct {
+	directories []string
+	shares      []string
+	verifyNSP   bool
+	debugTicket bool
+}
+
+func newSourcesState(cfg repository.Config) sourcesState {
+	return sourcesState{
+		directories: append([]string(nil), cfg.Directories()...),
+		shares:      append([]string(nil), cfg.NfsShares()...),
+		verifyNSP:   cfg.VerifyNSP(),
+		debugTicket: cfg.DebugTicket(),
+	}
+}
+
+// OnConfigUpdate from all sources, only the sources added or removed are loaded or dropped
 func (s *allSources) OnConfigUpdate(cfg repository.Config) {
+	state := newSourcesState(cfg)
+
+	s.mutex.RLock()
+	loaded := s.config != nil
+	previous := s.state
+	s.mutex.RUnlock()
+
+	if !loaded || state.verifyNSP != previous.verifyNSP || state.debugTicket != previous.debugTicket {
+		s.loadAll(cfg, state, !loaded)
+		return
+	}
+	s.update(cfg, previous, state)
+}
+
+// loadAll replaces the sources with new ones loading every directory and share
+func (s *allSources) loadAll(cfg repository.Config, state sourcesState, firstLoad bool) {
 	log.Println("Sources loading...")
+	// A scan of the previous configuration must not replace the new one
+	s.CancelScan()
+	staging := &stagingCollection{Collection: s.collection}
 
 	// Directories
-	srcDirectories := directory.New(s.collection, cfg)
+	start := time.Now()
+	srcDirectories := directory.New(staging, cfg, nil)
 	srcDirectories.Reset()
-	srcDirectories.Load(cfg.Directories(), len(cfg.NfsShares()) == 0)
-	s.sourcesProvider.Directory = srcDirectories
+	srcDirectories.Load(state.directories, firstLoad && len(state.shares) == 0)
+	metrics.ScanDuration.WithLabelValues(string(repository.LocalFile)).Set(time.Since(start).Seconds())
 
 	// NFS
-	srcNFS := nfs.New(s.collection, cfg)
+	start = time.Now()
+	srcNFS := nfs.New(staging, cfg, nil)
 	srcNFS.Reset()
-	srcNFS.Load(cfg.NfsShares(), false)
+	srcNFS.Load(state.shares, false)
+	metrics.ScanDuration.WithLabelValues(string(repository.NFSShare)).Set(time.Since(start).Seconds())
+
+	s.mutex.Lock()
+	for _, src := range []repository.Source{s.sourcesProvider.Directory, s.sourcesProvider.NFS} {
+		if src != nil {
+			src.UnWatchAll()
+		}
+	}
+	s.sourcesProvider.Directory = srcDirectories
 	s.sourcesProvider.NFS = srcNFS
+	s.config = cfg
+	s.state = state
+	s.mutex.Unlock()
+
+	staging.commit()
+	s.collection.ReplaceGames(s.GetFiles())
 }
 
-// BeforeConfigUpdate from all sources
-func (s *allSources) BeforeConfigUpdate(_ repository.Config) {
-	if s.sourcesProvider.Directory != nil {
-		s.sourcesProvider.Directory.UnWatchAll()
+// update drops the sources removed from the configuration and loads the added ones,
+// the others keep their files and watchers
+func (s *allSources) update(cfg repository.Config, previous sourcesState, state sourcesState) {
+	addedDirectories, removedDirectories := diff(previous.directories, state.directories)
+	addedShares, removedShares := diff(previous.shares, state.shares)
+	if len(addedDirectories)+len(removedDirectories)+len(addedShares)+len(removedShares) > 0 {
+		// A scan of the previous configuration must not replace the new one
+		s.CancelScan()
 	}
-	if s.sourcesProvider.NFS != nil {
-		s.sourcesProvider.NFS.UnWatchAll()
+
+	s.mutex.Lock()
+	provider := s.sourcesProvider
+	s.config = cfg
+	s.state = state
+	s.mutex.Unlock()
+
+	for _, dir := range removedDirectories {
+		// Still served by a parent directory
+		if isInDirectories(state.directories, dir) {
+			continue
+		}
+		log.Println("Sources removing directory", dir)
+		provider.Directory.RemovePath(dir)
+	}
+	for _, share := range removedShares {
+		log.Println("Sources removing nfs share", share)
+		provider.NFS.RemovePath(share)
+	}
+	if len(addedDirectories) > 0 {
+		start := time.Now()
+		provider.Directory.Load(addedDirectories, false)
+		metrics.ScanDuration.WithLabelValues(string(repository.LocalFile)).Set(time.Since(start).Seconds())
 	}
+	if len(addedShares) > 0 {
+		start := time.Now()
+		provider.NFS.Load(addedShares, false)
+		metrics.ScanDuration.WithLabelValues(string(repository.NFSShare)).Set(time.Since(start).Seconds())
+	}
+
+	// The url and titledb information of the games may have changed
+	s.collection.ReplaceGames(s.GetFiles())
+}
+
+// diff returns the values only in next and the values only in previous
+func diff(previous []string, next []string) (added []string, removed []string) {
+	for _, value := range next {
+		if !utils.Contains(previous, value) {
+			added = append(added, value)
+		}
+	}
+	for _, value := range previous {
+		if !utils.Contains(next, value) {
+			removed = append(removed, value)
+		}
+	}
+	return added, removed
+}
+
+// BeforeConfigUpdate from all sources, nothing is released as the sources
+// are compared with the previous configuration on update
+func (s *allSources) BeforeConfigUpdate(_ repository.Config) {
 }
 
 // GetFiles returns all games files in various sources
 func (s *allSources) GetFiles() []repository.FileDesc {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	mergedGameFiles := make([]repository.FileDesc, 0)
 	if s.sourcesProvider.Directory != nil {
 		mergedGameFiles = append(mergedGameFiles, s.sourcesProvider.Directory.GetFiles()...)
@@ -72,33 +196,79 @@ func (s *allSources) GetFiles() []repository.FileDesc {
 	return mergedGameFiles
 }
 
+// FailedFiles returns the game files of all sources which could not be added
+func (s *allSources) FailedFiles() []repository.FailedFile {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	failedFiles := make([]repository.FailedFile, 0)
+	if s.sourcesProvider.Directory != nil {
+		failedFiles = append(failedFiles, s.sourcesProvider.Directory.FailedFiles()...)
+	}
+	if s.sourcesProvider.NFS != nil {
+		failedFiles = append(failedFiles, s.sourcesProvider.NFS.FailedFiles()...)
+	}
+	return failedFiles
+}
+
+// findGame looks for the file of the game in each source, without merging their files
+func (s *allSources) findGame(gameID string) (repository.FileDesc, bool) {
+	s.mutex.RLock()
+	provider := s.sourcesProvider
+	s.mutex.RUnlock()
+
+	for _, src := range []repository.Source{provider.Directory, provider.NFS} {
+		if src == nil {
+			continue
+		}
+		files := src.GetFiles()
+		idx := utils.Search(len(files), func(index int) bool {
+			return files[index].GameID == gameID
+		})
+		if idx != -1 {
+			return files[idx], true
+		}
+	}
+	return repository.FileDesc{}, false
+}
+
+// HasGame tells if one of the sources has the file of the game
 func (s *allSources) HasGame(gameID string) bool {
-	idx := utils.Search(len(s.GetFiles()), func(index int) bool {
-		return s.GetFiles()[index].GameID == gameID
-	})
-	return idx != -1
+	_, found := s.findGame(gameID)
+	return found
 }
 
 // DownloadGame method provide the file based on the source storage
 func (s *allSources) DownloadGame(gameID string, w http.ResponseWriter, r *http.Request) {
-	idx := utils.Search(len(s.GetFiles()), func(index int) bool {
-		return s.GetFiles()[index].GameID == gameID
-	})
-
-	if idx == -1 {
+	file, found := s.findGame(gameID)
+	if !found {
 		w.WriteHeader(http.StatusNotFound)
 		log.Printf("Game '%s' not found!", gameID)
 		return
 	}
-	log.Println("Retrieving from location '" + s.GetFiles()[idx].Path + "'")
-	switch s.GetFiles()[idx].HostType {
+	log.Println("Retrieving from location '" + file.Path + "'")
+	hostType := file.HostType
+	inFlight := metrics.DownloadsInFlight.WithLabelValues()
+	inFlight.Inc()
+	counter := utils.NewResponseCounter(w)
+	defer func() {
+		inFlight.Dec()
+		metrics.BytesServed.WithLabelValues(string(hostType)).Add(float64(counter.Bytes))
+	}()
+
+	path := file.Path
+	s.mutex.RLock()
+	provider := s.sourcesProvider
+	s.mutex.RUnlock()
+
+	switch hostType {
 	case repository.LocalFile:
-		s.sourcesProvider.Directory.Download(w, r, gameID, s.GetFiles()[idx].Path)
+		provider.Directory.Download(counter, r, gameID, path)
 	case repository.NFSShare:
-		s.sourcesProvider.NFS.Download(w, r, gameID, s.GetFiles()[idx].Path)
+		provider.NFS.Download(counter, r, gameID, path)
 
 	default:
 		w.WriteHeader(http.StatusNotImplemented)
-		log.Printf("The type '%s' is not implemented to download game", s.GetFiles()[idx].HostType)
+		log.Printf("The type '%s' is not implemented to download game", hostType)
 	}
 }
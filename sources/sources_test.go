@@ -1,9 +1,14 @@
 package sources_test
 
 import (
+	"os"
+	"path/filepath"
+
+	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	"github.com/ajmandourah/tinshop-ng/mock_repository"
 	"github.com/ajmandourah/tinshop-ng/repository"
 	"github.com/ajmandourah/tinshop-ng/sources"
 )
@@ -33,4 +38,155 @@ var _ = Describe("Sources", func() {
 	// 	Expect(firstFile.Size).To(Equal(int64(42)))
 
 	// })
+	Describe("Rescan", func() {
+		var (
+			ctrl           *gomock.Controller
+			myMockConfig   *mock_repository.MockConfig
+			myMockCollect  *mock_repository.MockCollection
+			gamesDirectory string
+			directories    []string
+			replaced       [][]repository.FileDesc
+		)
+		BeforeEach(func() {
+			ctrl = gomock.NewController(GinkgoT())
+			gamesDirectory = GinkgoT().TempDir()
+			directories = []string{gamesDirectory}
+			replaced = nil
+
+			myMockConfig = mock_repository.NewMockConfig(ctrl)
+			myMockConfig.EXPECT().Directories().DoAndReturn(func() []string { return directories }).AnyTimes()
+			myMockConfig.EXPECT().NfsShares().Return(nil).AnyTimes()
+			myMockConfig.EXPECT().VerifyNSP().Return(false).AnyTimes()
+			myMockConfig.EXPECT().DebugTicket().Return(false).AnyTimes()
+
+			myMockCollect = mock_repository.NewMockCollection(ctrl)
+			myMockCollect.EXPECT().AddNewGames(gomock.Any()).AnyTimes()
+			myMockCollect.EXPECT().RemoveGame(gomock.Any()).AnyTimes()
+			myMockCollect.EXPECT().ReplaceGames(gomock.Any()).Do(func(files []repository.FileDesc) {
+				replaced = append(replaced, files)
+			}).AnyTimes()
+
+			allSources = sources.New(myMockCollect)
+		})
+		AfterEach(func() {
+			// Dropping the directories stops their watchers
+			directories = nil
+			allSources.OnConfigUpdate(myMockConfig)
+		})
+		It("Refuses to scan before the sources are loaded", func() {
+			_, err := allSources.Rescan(repository.ScanAll, "")
+
+			Expect(err).To(MatchError(sources.ErrNotLoaded))
+		})
+		It("Is not healthy before the sources are loaded", func() {
+			checks := allSources.Health()
+
+			Expect(checks).To(HaveLen(1))
+			Expect(checks[0].Name).To(Equal("scan"))
+			Expect(checks[0].Status).To(Equal(repository.HealthFailed))
+		})
+		Context("With loaded sources", func() {
+			BeforeEach(func() {
+				Expect(os.WriteFile(filepath.Join(gamesDirectory, "Game [0100000000010000][v0].nsp"), []byte("game"), 0o600)).To(Succeed())
+				allSources.OnConfigUpdate(myMockConfig)
+				replaced = nil
+			})
+			It("Refuses an unknown target", func() {
+				_, err := allSources.Rescan("somewhere", "")
+
+				Expect(err).To(MatchError(sources.ErrUnknownTarget))
+			})
+			It("Refuses a path outside of the directories", func() {
+				_, err := allSources.Rescan(repository.ScanPath, "/somewhere/else")
+
+				Expect(err).To(MatchError(sources.ErrPathOutside))
+			})
+			It("Checks the directories are reachable", func() {
+				checks := allSources.Health()
+
+				Expect(checks).To(HaveLen(2))
+				Expect(checks[0]).To(Equal(repository.HealthCheck{Name: "scan", Status: repository.HealthOK, Message: "1 files"}))
+				Expect(checks[1]).To(Equal(repository.HealthCheck{Name: "directory.0", Status: repository.HealthOK}))
+			})
+			It("Reports a missing directory without its path", func() {
+				Expect(os.RemoveAll(gamesDirectory)).To(Succeed())
+
+				checks := allSources.Health()
+
+				Expect(checks[1].Status).To(Equal(repository.HealthFailed))
+				Expect(checks[1].Message).NotTo(ContainSubstring(gamesDirectory))
+			})
+			It("Reuses a recent check of the directories", func() {
+				Expect(allSources.Health()[1].Status).To(Equal(repository.HealthOK))
+				Expect(os.RemoveAll(gamesDirectory)).To(Succeed())
+
+				Expect(allSources.Health()[1].Status).To(Equal(repository.HealthOK))
+			})
+			It("Loads an added directory and keeps the others", func() {
+				otherDirectory := GinkgoT().TempDir()
+				Expect(os.WriteFile(filepath.Join(otherDirectory, "Other [0100000000020000][v0].nsp"), []byte("game"), 0o600)).To(Succeed())
+				directories = []string{gamesDirectory, otherDirectory}
+
+				allSources.OnConfigUpdate(myMockConfig)
+
+				Expect(allSources.GetFiles()).To(HaveLen(2))
+				Expect(allSources.GetFiles()[0].GameID).To(Equal("0100000000010000"))
+				Expect(replaced).To(HaveLen(1))
+				Expect(replaced[0]).To(HaveLen(2))
+			})
+			It("Drops a removed directory", func() {
+				directories = nil
+
+				allSources.OnConfigUpdate(myMockConfig)
+
+				Expect(allSources.GetFiles()).To(BeEmpty())
+				Expect(replaced).To(Equal([][]repository.FileDesc{{}}))
+
+				// Not watched anymore
+				Expect(os.WriteFile(filepath.Join(gamesDirectory, "Late [0100000000030000][v0].nsp"), []byte("game"), 0o600)).To(Succeed())
+				Consistently(allSources.GetFiles, "200ms").Should(BeEmpty())
+			})
+			It("Keeps the files when the sources did not change", func() {
+				files := allSources.GetFiles()
+
+				allSources.OnConfigUpdate(myMockConfig)
+
+				Expect(allSources.GetFiles()).To(Equal(files))
+			})
+			It("Has nothing to cancel", func() {
+				Expect(allSources.CancelScan()).To(BeFalse())
+			})
+			It("Scans the whole library in background", func() {
+				status, err := allSources.Rescan("", "")
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(status.ID).To(Equal(1))
+				Expect(status.Target).To(Equal(repository.ScanAll))
+				Eventually(func() string { return allSources.ScanStatus().State }).Should(Equal(repository.ScanFinished))
+
+				status = allSources.ScanStatus()
+				Expect(status.Seen).To(Equal(int64(1)))
+				Expect(status.Identified).To(Equal(int64(1)))
+				Expect(status.FinishedAt).NotTo(BeNil())
+				Expect(allSources.GetFiles()).To(HaveLen(1))
+				Expect(replaced).To(HaveLen(1))
+				Expect(replaced[0]).To(HaveLen(1))
+				Expect(replaced[0][0].GameID).To(Equal("0100000000010000"))
+			})
+			It("Scans a single path", func() {
+				subDirectory := filepath.Join(gamesDirectory, "sub")
+				Expect(os.Mkdir(subDirectory, 0o700)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(subDirectory, "Other [0100000000020000][v0].nsp"), []byte("game"), 0o600)).To(Succeed())
+
+				_, err := allSources.Rescan(repository.ScanPath, subDirectory)
+
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(func() string { return allSources.ScanStatus().State }).Should(Equal(repository.ScanFinished))
+				Expect(allSources.ScanStatus().Seen).To(Equal(int64(1)))
+				Expect(allSources.GetFiles()).To(HaveLen(2))
+				Expect(replaced).To(HaveLen(1))
+				Expect(replaced[0]).To(ContainElement(HaveField("GameID", "0100000000020000")))
+			})
+		})
+	})
 })
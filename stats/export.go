@@ -0,0 +1,255 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+// exportVersion is the version of the export format
+const exportVersion = 1
+
+// CSVTables lists the tables available in csv
+var CSVTables = []string{"titles", "devices", "hourly", "daily"} //nolint:gochecknoglobals
+
+// ErrUnknownTable is returned when exporting a table which does not exist
+var ErrUnknownTable = errors.New("unknown table")
+
+// Export returns the whole content of the stats database
+func (s *stat) Export() (repository.StatsExport, error) {
+	export := repository.StatsExport{
+		Version:    exportVersion,
+		ExportedAt: time.Now().UTC(),
+		Titles:     make([]repository.TitleStats, 0),
+		Devices:    make([]repository.DeviceStats, 0),
+		Hourly:     make([]repository.SeriesPoint, 0),
+		Daily:      make([]repository.SeriesPoint, 0),
+	}
+	if s.db == nil {
+		return export, nil
+	}
+	s.flush()
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		global := tx.Bucket([]byte(globalBucket))
+		export.Visit = utils.ByteToUint64(global.Get([]byte(visitKey)))
+		export.DownloadAsked = utils.ByteToUint64(global.Get([]byte(downloadKey)))
+		export.DownloadCompleted = utils.ByteToUint64(global.Get([]byte(completedKey)))
+		export.DownloadAborted = utils.ByteToUint64(global.Get([]byte(abortedKey)))
+		export.BytesServed = utils.ByteToUint64(global.Get([]byte(bytesKey)))
+
+		errTitles := tx.Bucket([]byte(titlesBucket)).ForEach(func(_, v []byte) error {
+			var title repository.TitleStats
+			if err := json.Unmarshal(v, &title); err != nil {
+				return err
+			}
+			export.Titles = append(export.Titles, title)
+			return nil
+		})
+		if errTitles != nil {
+			return errTitles
+		}
+		errDevices := tx.Bucket([]byte(devicesBucket)).ForEach(func(_, v []byte) error {
+			var device repository.DeviceStats
+			if err := json.Unmarshal(v, &device); err != nil {
+				return err
+			}
+			export.Devices = append(export.Devices, device)
+			return nil
+		})
+		if errDevices != nil {
+			return errDevices
+		}
+		var err error
+		if export.Hourly, err = allPoints(tx.Bucket([]byte(hourlyBucket))); err != nil {
+			return err
+		}
+		export.Daily, err = allPoints(tx.Bucket([]byte(dailyBucket)))
+		return err
+	})
+	if err != nil {
+		return repository.StatsExport{}, err
+	}
+	return export, nil
+}
+
+func allPoints(b *bolt.Bucket) ([]repository.SeriesPoint, error) {
+	points := make([]repository.SeriesPoint, 0)
+	err := b.ForEach(func(_, v []byte) error {
+		var point repository.SeriesPoint
+		if err := json.Unmarshal(v, &point); err != nil {
+			return err
+		}
+		points = append(points, point)
+		return nil
+	})
+	return points, err
+}
+
+// Import merges an export into the database: counters are added and timestamps widened
+func (s *stat) Import(export repository.StatsExport) error {
+	if s.db == nil {
+		return errors.New("stats database is not opened")
+	}
+	if export.Version > exportVersion {
+		return fmt.Errorf("unsupported export version %d", export.Version)
+	}
+	s.flush()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		global := tx.Bucket([]byte(globalBucket))
+		counters := map[string]uint64{
+			visitKey:     export.Visit,
+			downloadKey:  export.DownloadAsked,
+			completedKey: export.DownloadCompleted,
+			abortedKey:   export.DownloadAborted,
+			bytesKey:     export.BytesServed,
+		}
+		for key, value := range counters {
+			if err := increment(global, key, value); err != nil {
+				return err
+			}
+		}
+
+		for _, imported := range export.Titles {
+			errTitle := updateTitle(tx, imported.TitleID, time.Time{}, func(title *repository.TitleStats) {
+				title.Asked += imported.Asked
+				title.Completed += imported.Completed
+				title.Aborted += imported.Aborted
+				title.Bytes += imported.Bytes
+				seen(&title.FirstSeen, &title.LastSeen, imported.FirstSeen)
+				seen(&title.FirstSeen, &title.LastSeen, imported.LastSeen)
+			})
+			if errTitle != nil {
+				return errTitle
+			}
+		}
+
+		for _, imported := range export.Devices {
+			errDevice := updateDevice(tx, imported.ID, func(device *repository.DeviceStats) {
+				mergeDevice(device, imported)
+			})
+			if errDevice != nil {
+				return errDevice
+			}
+		}
+
+		for _, point := range export.Hourly {
+			hour := point.Time.UTC().Truncate(time.Hour)
+			if err := putPoint(tx.Bucket([]byte(hourlyBucket)), hour.Format(hourlyLayout), hour, func(total *repository.SeriesPoint) {
+				addPoint(total, point)
+			}); err != nil {
+				return err
+			}
+		}
+		for _, point := range export.Daily {
+			pointDay := day(point.Time)
+			if err := putPoint(tx.Bucket([]byte(dailyBucket)), pointDay.Format(dailyLayout), pointDay, func(total *repository.SeriesPoint) {
+				addPoint(total, point)
+			}); err != nil {
+				return err
+			}
+		}
+
+		hourlyDays, dailyDays := s.retention()
+		return rollup(tx, time.Now().UTC(), hourlyDays, dailyDays)
+	})
+}
+
+// mergeDevice adds the counters of imported, the most recent host keeps its headers
+func mergeDevice(device *repository.DeviceStats, imported repository.DeviceStats) {
+	if imported.LastSeen.After(device.LastSeen) || device.IP == "" {
+		if imported.IP != "" {
+			device.IP = imported.IP
+		}
+		if imported.Theme != "" {
+			device.Theme = imported.Theme
+		}
+		if imported.Version != "" {
+			device.Version = imported.Version
+		}
+		if imported.Language != "" {
+			device.Language = imported.Language
+		}
+	}
+	device.Visits += imported.Visits
+	device.Completed += imported.Completed
+	device.Aborted += imported.Aborted
+	device.Bytes += imported.Bytes
+	for titleID, count := range imported.Titles {
+		if device.Titles == nil {
+			device.Titles = make(map[string]uint64)
+		}
+		device.Titles[titleID] += count
+	}
+	seen(&device.FirstSeen, &device.LastSeen, imported.FirstSeen)
+	seen(&device.FirstSeen, &device.LastSeen, imported.LastSeen)
+}
+
+// WriteCSV writes one table of the export as csv
+func WriteCSV(w io.Writer, export repository.StatsExport, table string) error {
+	var records [][]string
+	switch table {
+	case "titles":
+		records = append(records, []string{"titleId", "asked", "completed", "aborted", "bytes", "firstSeen", "lastSeen"})
+		sort.Slice(export.Titles, func(i, j int) bool { return export.Titles[i].TitleID < export.Titles[j].TitleID })
+		for _, title := range export.Titles {
+			records = append(records, []string{
+				title.TitleID, formatUint(title.Asked), formatUint(title.Completed), formatUint(title.Aborted),
+				formatUint(title.Bytes), formatTime(title.FirstSeen), formatTime(title.LastSeen),
+			})
+		}
+	case "devices":
+		records = append(records, []string{"id", "ip", "theme", "version", "language", "visits", "completed", "aborted", "bytes", "titles", "firstSeen", "lastSeen"})
+		sort.Slice(export.Devices, func(i, j int) bool { return export.Devices[i].ID < export.Devices[j].ID })
+		for _, device := range export.Devices {
+			titles := make([]string, 0, len(device.Titles))
+			for titleID := range device.Titles {
+				titles = append(titles, titleID)
+			}
+			sort.Strings(titles)
+			records = append(records, []string{
+				device.ID, device.IP, device.Theme, device.Version, device.Language,
+				formatUint(device.Visits), formatUint(device.Completed), formatUint(device.Aborted), formatUint(device.Bytes),
+				strings.Join(titles, " "), formatTime(device.FirstSeen), formatTime(device.LastSeen),
+			})
+		}
+	case "hourly", "daily":
+		points := export.Hourly
+		if table == "daily" {
+			points = export.Daily
+		}
+		records = append(records, []string{"time", "visits", "downloads", "completed", "aborted", "bytes"})
+		sort.Slice(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+		for _, point := range points {
+			records = append(records, []string{
+				formatTime(point.Time), formatUint(point.Visits), formatUint(point.Downloads),
+				formatUint(point.Completed), formatUint(point.Aborted), formatUint(point.Bytes),
+			})
+		}
+	default:
+		return fmt.Errorf("%w '%s'", ErrUnknownTable, table)
+	}
+	return csv.NewWriter(w).WriteAll(records)
+}
+
+func formatUint(value uint64) string {
+	return strconv.FormatUint(value, 10)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
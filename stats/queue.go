@@ -0,0 +1,167 @@
+package stats
+
+import (
+	"log"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	queueSize = 1024
+	batchSize = 256
+	// sessionTimeout is the idle time after which a new request of the same title is a new download
+	sessionTimeout = 30 * time.Minute
+)
+
+type eventKind int
+
+const (
+	visitEvent eventKind = iota
+	askedEvent
+	servedEvent
+	rollupEvent
+	flushEvent
+)
+
+type event struct {
+	kind     eventKind
+	time     time.Time
+	console  repository.Switch
+	gameID   string
+	download repository.Download
+	flushed  chan struct{}
+}
+
+// enqueue sends the event to the writer without blocking the request,
+// the events sent once closed are dropped
+func (s *stat) enqueue(e event) {
+	if s.db == nil {
+		return
+	}
+	s.queueMutex.RLock()
+	defer s.queueMutex.RUnlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- e:
+	default:
+		log.Println("[Stats] Queue is full, dropping event")
+	}
+}
+
+// flush waits until every event queued before has been written,
+// the writer keeps reading the queue until it is closed so a full queue only delays it
+func (s *stat) flush() {
+	flushed := make(chan struct{})
+	s.queueMutex.RLock()
+	if s.closed {
+		s.queueMutex.RUnlock()
+		return
+	}
+	s.queue <- event{kind: flushEvent, flushed: flushed}
+	s.queueMutex.RUnlock()
+	<-flushed
+}
+
+// run writes the queued events by batches in a single transaction
+func (s *stat) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		var first event
+		select {
+		case next, ok := <-s.queue:
+			if !ok {
+				return
+			}
+			first = next
+		case now := <-ticker.C:
+			first = event{kind: rollupEvent, time: now.UTC()}
+		}
+		batch := []event{first}
+	collect:
+		for len(batch) < batchSize {
+			select {
+			case next, ok := <-s.queue:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, next)
+			default:
+				break collect
+			}
+		}
+		s.write(batch)
+	}
+}
+
+func (s *stat) write(batch []event) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, e := range batch {
+			if err := s.apply(tx, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("[Stats] Unable to write", len(batch), "events", err)
+	}
+	for _, e := range batch {
+		if e.flushed != nil {
+			close(e.flushed)
+		}
+	}
+}
+
+func (s *stat) apply(tx *bolt.Tx, e event) error {
+	switch e.kind {
+	case visitEvent:
+		return applyVisit(tx, e.console, e.time)
+	case askedEvent:
+		if !s.sessions.ask(e.console.IP+"|"+e.gameID, e.time) {
+			return nil
+		}
+		return applyAsked(tx, e.gameID, e.time)
+	case servedEvent:
+		download := e.download
+		bytes := uint64(0)
+		if download.Bytes > 0 {
+			bytes = uint64(download.Bytes)
+		}
+		current, newSession, wasCompleted := s.sessions.serve(deviceID(download.Console)+"|"+download.TitleID, download, bytes)
+		download.Completed = current.completed
+		return applyServed(tx, download, bytes, newSession, wasCompleted, current.started)
+	case rollupEvent:
+		hourlyDays, dailyDays := s.retention()
+		return rollup(tx, e.time, hourlyDays, dailyDays)
+	}
+	return nil
+}
+
+// DownloadAsked compute stats when we download a game
+func (s *stat) DownloadAsked(IP string, gameID string) error {
+	s.enqueue(event{kind: askedEvent, time: time.Now().UTC(), console: repository.Switch{IP: IP}, gameID: gameID})
+	return nil
+}
+
+// DownloadServed records the bytes sent for a download and if it has been completed
+func (s *stat) DownloadServed(download repository.Download) error {
+	if download.Time.IsZero() {
+		download.Time = time.Now().UTC()
+	}
+	s.enqueue(event{kind: servedEvent, time: download.Time, download: download})
+	return nil
+}
+
+// ListVisit count every visit to the listing page (either root or filter)
+func (s *stat) ListVisit(console *repository.Switch) error {
+	s.enqueue(event{kind: visitEvent, time: time.Now().UTC(), console: *console})
+	return nil
+}
@@ -0,0 +1,202 @@
+package stats
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	hourlyBucket = "hourly"
+	dailyBucket  = "daily"
+
+	hourlyLayout = "2006-01-02T15"
+	dailyLayout  = "2006-01-02"
+
+	defaultHourlyRetention = 7
+	defaultDailyRetention  = 365
+)
+
+// updatePoint changes the hourly point of the time
+func updatePoint(tx *bolt.Tx, now time.Time, update func(*repository.SeriesPoint)) error {
+	if now.IsZero() {
+		return nil
+	}
+	hour := now.UTC().Truncate(time.Hour)
+	return putPoint(tx.Bucket([]byte(hourlyBucket)), hour.Format(hourlyLayout), hour, update)
+}
+
+func putPoint(b *bolt.Bucket, key string, pointTime time.Time, update func(*repository.SeriesPoint)) error {
+	point := repository.SeriesPoint{Time: pointTime}
+	if raw := b.Get([]byte(key)); raw != nil {
+		if err := json.Unmarshal(raw, &point); err != nil {
+			return err
+		}
+	}
+	update(&point)
+	buf, err := json.Marshal(point)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), buf)
+}
+
+func addPoint(total *repository.SeriesPoint, point repository.SeriesPoint) {
+	total.Visits += point.Visits
+	total.Downloads += point.Downloads
+	total.Completed += point.Completed
+	total.Aborted += point.Aborted
+	total.Bytes += point.Bytes
+}
+
+func day(t time.Time) time.Time {
+	year, month, dayOfMonth := t.UTC().Date()
+	return time.Date(year, month, dayOfMonth, 0, 0, 0, 0, time.UTC)
+}
+
+// rollup merges hourly points older than the hourly retention into daily points
+// and removes daily points older than the daily retention (0 keeps them forever)
+func rollup(tx *bolt.Tx, now time.Time, hourlyDays int, dailyDays int) error {
+	hourly := tx.Bucket([]byte(hourlyBucket))
+	daily := tx.Bucket([]byte(dailyBucket))
+
+	// Only complete days are rolled up so a day is never split between both buckets
+	limit := day(now).AddDate(0, 0, -hourlyDays).Format(hourlyLayout)
+	merged := make(map[string]repository.SeriesPoint)
+	oldKeys := make([][]byte, 0)
+	c := hourly.Cursor()
+	for k, v := c.First(); k != nil && string(k) < limit; k, v = c.Next() {
+		var point repository.SeriesPoint
+		if err := json.Unmarshal(v, &point); err != nil {
+			return err
+		}
+		dayKey := day(point.Time).Format(dailyLayout)
+		total := merged[dayKey]
+		total.Time = day(point.Time)
+		addPoint(&total, point)
+		merged[dayKey] = total
+		oldKeys = append(oldKeys, append([]byte{}, k...))
+	}
+	for dayKey, total := range merged {
+		total := total
+		err := putPoint(daily, dayKey, total.Time, func(point *repository.SeriesPoint) {
+			addPoint(point, total)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, k := range oldKeys {
+		if err := hourly.Delete(k); err != nil {
+			return err
+		}
+	}
+	if len(oldKeys) > 0 {
+		log.Println("[Stats] Rolled up", len(oldKeys), "hourly points into", len(merged), "days")
+	}
+
+	if dailyDays <= 0 {
+		return nil
+	}
+	dailyLimit := day(now).AddDate(0, 0, -dailyDays).Format(dailyLayout)
+	expired := make([][]byte, 0)
+	c = daily.Cursor()
+	for k, _ := c.First(); k != nil && string(k) < dailyLimit; k, _ = c.Next() {
+		expired = append(expired, append([]byte{}, k...))
+	}
+	for _, k := range expired {
+		if err := daily.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Series returns the points between from and to, oldest first
+func (s *stat) Series(query repository.SeriesQuery) ([]repository.SeriesPoint, error) {
+	points := make([]repository.SeriesPoint, 0)
+	if s.db == nil {
+		return points, nil
+	}
+	s.flush()
+
+	to := query.To
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+	from := query.From
+	if from.IsZero() {
+		if query.Resolution == repository.SeriesDaily {
+			from = to.AddDate(0, 0, -30)
+		} else {
+			from = to.Add(-24 * time.Hour)
+		}
+	}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		hourly, err := readPoints(tx.Bucket([]byte(hourlyBucket)), from.UTC().Truncate(time.Hour), to)
+		if err != nil {
+			return err
+		}
+		if query.Resolution != repository.SeriesDaily {
+			points = hourly
+			return nil
+		}
+
+		daily, err := readPoints(tx.Bucket([]byte(dailyBucket)), day(from), to)
+		if err != nil {
+			return err
+		}
+		// Recent days are still hourly
+		byDay := make(map[time.Time]repository.SeriesPoint)
+		for _, point := range append(daily, hourly...) {
+			total := byDay[day(point.Time)]
+			total.Time = day(point.Time)
+			addPoint(&total, point)
+			byDay[total.Time] = total
+		}
+		for _, point := range byDay {
+			points = append(points, point)
+		}
+		sort.Slice(points, func(i, j int) bool {
+			return points[i].Time.Before(points[j].Time)
+		})
+		return nil
+	})
+	return points, err
+}
+
+func readPoints(b *bolt.Bucket, from time.Time, to time.Time) ([]repository.SeriesPoint, error) {
+	points := make([]repository.SeriesPoint, 0)
+	err := b.ForEach(func(_, v []byte) error {
+		var point repository.SeriesPoint
+		if err := json.Unmarshal(v, &point); err != nil {
+			return err
+		}
+		if !point.Time.Before(from) && !point.Time.After(to) {
+			points = append(points, point)
+		}
+		return nil
+	})
+	return points, err
+}
+
+// OnConfigUpdate applies the new retention
+func (s *stat) OnConfigUpdate(cfg repository.Config) {
+	s.retentionMutex.Lock()
+	s.hourlyRetention = cfg.StatsHourlyRetention()
+	s.dailyRetention = cfg.StatsDailyRetention()
+	s.retentionMutex.Unlock()
+
+	s.enqueue(event{kind: rollupEvent, time: time.Now().UTC()})
+}
+
+func (s *stat) retention() (int, int) {
+	s.retentionMutex.RLock()
+	defer s.retentionMutex.RUnlock()
+	return s.hourlyRetention, s.dailyRetention
+}
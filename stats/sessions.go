@@ -0,0 +1,81 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+)
+
+// session follows the requests of a switch for a title, tinfoil uses many Range requests for one install
+type session struct {
+	started   time.Time
+	lastSeen  time.Time
+	bytes     uint64
+	completed bool
+}
+
+// sessions is only used by the writer goroutine
+type sessions struct {
+	timeout time.Duration
+	asked   map[string]time.Time
+	served  map[string]*session
+	pruned  time.Time
+}
+
+func newSessions(timeout time.Duration) *sessions {
+	return &sessions{
+		timeout: timeout,
+		asked:   make(map[string]time.Time),
+		served:  make(map[string]*session),
+	}
+}
+
+func (s *sessions) active(lastSeen time.Time, now time.Time) bool {
+	return now.Sub(lastSeen) <= s.timeout && !now.Before(lastSeen.Add(-s.timeout))
+}
+
+// ask tells if the request starts a new download
+func (s *sessions) ask(key string, now time.Time) bool {
+	s.prune(now)
+	lastSeen, found := s.asked[key]
+	s.asked[key] = now
+	return !found || !s.active(lastSeen, now)
+}
+
+// serve adds the download to its session and returns it with if the session is new
+// and if it was already completed before
+func (s *sessions) serve(key string, download repository.Download, bytes uint64) (*session, bool, bool) {
+	s.prune(download.Time)
+	current, found := s.served[key]
+	newSession := !found || !s.active(current.lastSeen, download.Time)
+	if newSession {
+		current = &session{started: download.Time}
+		s.served[key] = current
+	}
+	wasCompleted := current.completed
+
+	current.lastSeen = download.Time
+	current.bytes += bytes
+	if download.Completed || (download.Size > 0 && current.bytes >= uint64(download.Size)) {
+		current.completed = true
+	}
+	return current, newSession, wasCompleted
+}
+
+// prune forgets the sessions idle for too long
+func (s *sessions) prune(now time.Time) {
+	if now.Sub(s.pruned) < s.timeout {
+		return
+	}
+	s.pruned = now
+	for key, lastSeen := range s.asked {
+		if !s.active(lastSeen, now) {
+			delete(s.asked, key)
+		}
+	}
+	for key, current := range s.served {
+		if !s.active(current.lastSeen, now) {
+			delete(s.served, key)
+		}
+	}
+}
@@ -1,151 +1,448 @@
+// @title tinshop Stats
+
+// @BasePath /stats/
+
+// Package stats provides the statistics store of the shop
 package stats
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/ajmandourah/tinshop-ng/repository"
 	"github.com/ajmandourah/tinshop-ng/utils"
 	bolt "go.etcd.io/bbolt"
 )
 
+const (
+	globalBucket  = "global"
+	titlesBucket  = "titles"
+	devicesBucket = "devices"
+
+	visitKey     = "visit"
+	downloadKey  = "download"
+	completedKey = "completed"
+	abortedKey   = "aborted"
+	bytesKey     = "bytes"
+
+	// Keys of the first version, migrated on load
+	legacySwitchKey          = "switch"
+	legacyDownloadDetailsKey = "downloadDetails"
+)
+
 type stat struct {
-	db *bolt.DB
+	path string
+	db   *bolt.DB
+
+	// queueMutex guards the queue against the sends after Close, which closes it
+	queueMutex sync.RWMutex
+	closed     bool
+	queue      chan event
+	done       chan struct{}
+	sessions   *sessions
+
+	retentionMutex  sync.RWMutex
+	hourlyRetention int
+	dailyRetention  int
 }
 
 // New create a new stats object
-func New() repository.Stats {
-	return &stat{}
+func New(path string) repository.Stats {
+	return &stat{
+		path:            path,
+		sessions:        newSessions(sessionTimeout),
+		hourlyRetention: defaultHourlyRetention,
+		dailyRetention:  defaultDailyRetention,
+	}
 }
 
-func (s *stat) initDB() {
-	_ = s.db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte("global"))
-		if err != nil {
-			return fmt.Errorf("create bucket: %s", err)
+func (s *stat) initDB() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range []string{globalBucket, titlesBucket, devicesBucket, hourlyBucket, dailyBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return fmt.Errorf("create bucket: %s", err)
+			}
 		}
-		return nil
+		if err := migrate(tx); err != nil {
+			return err
+		}
+		hourlyDays, dailyDays := s.retention()
+		return rollup(tx, time.Now().UTC(), hourlyDays, dailyDays)
 	})
 }
 
-func (s *stat) Load() {
-	db, err := bolt.Open("stats.db", 0600, nil)
+// Load opens the stats database, statistics are disabled when it fails
+func (s *stat) Load() error {
+	db, err := bolt.Open(s.path, 0600, &bolt.Options{Timeout: time.Second})
 	if err != nil {
-		fmt.Println(err)
+		return fmt.Errorf("open stats database '%s': %w", s.path, err)
 	}
 	s.db = db
 
-	s.initDB()
+	if err := s.initDB(); err != nil {
+		_ = db.Close()
+		s.db = nil
+		return fmt.Errorf("initialize stats database '%s': %w", s.path, err)
+	}
+
+	s.queue = make(chan event, queueSize)
+	s.done = make(chan struct{})
+	go s.run()
+	return nil
 }
 
+// Close writes the pending events and closes the stats database
 func (s *stat) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	s.queueMutex.Lock()
+	if s.closed {
+		s.queueMutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.queueMutex.Unlock()
+
+	<-s.done
 	return s.db.Close()
 }
 
-// Summary return the summary of all stats
-func (s *stat) Summary() (repository.StatsSummary, error) {
-	var visit uint64
-	var uniqueSwitch int
-	var consoles map[string]interface{}
-	var download uint64
-	var downloadDetails map[string]interface{}
+// Ping checks the stats database is open and readable
+func (s *stat) Ping() error {
+	if s.db == nil {
+		return errors.New("stats database not open")
+	}
+	return s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(globalBucket)) == nil {
+			return errors.New("stats database not initialized")
+		}
+		return nil
+	})
+}
 
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte("global"))
-		visit = utils.ByteToUint64(b.Get([]byte("visit")))
+// migrate moves the json blobs of the first version into the dedicated buckets
+func migrate(tx *bolt.Tx) error {
+	global := tx.Bucket([]byte(globalBucket))
 
-		var errConsoles error
-		consoles, errConsoles = utils.ByteToMap(b.Get([]byte("switch")))
-		if errConsoles != nil {
-			return errConsoles
+	if raw := global.Get([]byte(legacySwitchKey)); raw != nil {
+		consoles, err := utils.ByteToMap(raw)
+		if err != nil {
+			return err
 		}
-		uniqueSwitch = len(consoles)
-
-		download = utils.ByteToUint64(b.Get([]byte("download")))
+		for id, visits := range consoles {
+			count, _ := visits.(float64)
+			errDevice := updateDevice(tx, id, func(device *repository.DeviceStats) {
+				device.Visits += uint64(count)
+			})
+			if errDevice != nil {
+				return errDevice
+			}
+		}
+		if err := global.Delete([]byte(legacySwitchKey)); err != nil {
+			return err
+		}
+		log.Println("[Stats] Migrated visits of", len(consoles), "switch")
+	}
 
-		var errDownloadDetails error
-		downloadDetails, errDownloadDetails = utils.ByteToMap(b.Get([]byte("downloadDetails")))
-		if errDownloadDetails != nil {
-			return errDownloadDetails
+	if raw := global.Get([]byte(legacyDownloadDetailsKey)); raw != nil {
+		downloads, err := utils.ByteToMap(raw)
+		if err != nil {
+			return err
 		}
+		for _, games := range downloads {
+			gameIDs, _ := games.([]interface{})
+			for _, gameID := range gameIDs {
+				titleID, ok := gameID.(string)
+				if !ok {
+					continue
+				}
+				errTitle := updateTitle(tx, titleID, time.Time{}, func(title *repository.TitleStats) {
+					title.Asked++
+				})
+				if errTitle != nil {
+					return errTitle
+				}
+			}
+		}
+		if err := global.Delete([]byte(legacyDownloadDetailsKey)); err != nil {
+			return err
+		}
+		log.Println("[Stats] Migrated download details of", len(downloads), "ip")
+	}
+	return nil
+}
 
+func increment(b *bolt.Bucket, key string, value uint64) error {
+	return b.Put([]byte(key), utils.Itob(utils.ByteToUint64(b.Get([]byte(key)))+value))
+}
+
+func decrement(b *bolt.Bucket, key string) error {
+	value := utils.ByteToUint64(b.Get([]byte(key)))
+	if value == 0 {
 		return nil
-	})
+	}
+	return b.Put([]byte(key), utils.Itob(value-1))
+}
+
+// deviceID returns the key of a switch, falling back to its ip when the uid is unknown
+func deviceID(console repository.Switch) string {
+	if console.UID != "" {
+		return console.UID
+	}
+	return "Unknown-" + console.IP
+}
+
+func updateDevice(tx *bolt.Tx, id string, update func(*repository.DeviceStats)) error {
+	b := tx.Bucket([]byte(devicesBucket))
+	device := repository.DeviceStats{ID: id}
+	if raw := b.Get([]byte(id)); raw != nil {
+		if err := json.Unmarshal(raw, &device); err != nil {
+			return err
+		}
+	}
+	update(&device)
+	buf, err := json.Marshal(device)
 	if err != nil {
-		return repository.StatsSummary{}, err
+		return err
 	}
+	return b.Put([]byte(id), buf)
+}
 
-	return repository.StatsSummary{
-		Visit:           visit,
-		UniqueSwitch:    uint64(uniqueSwitch),
-		VisitPerSwitch:  consoles,
-		DownloadAsked:   download,
-		DownloadDetails: downloadDetails,
-	}, nil
+func updateTitle(tx *bolt.Tx, titleID string, now time.Time, update func(*repository.TitleStats)) error {
+	b := tx.Bucket([]byte(titlesBucket))
+	title := repository.TitleStats{TitleID: titleID}
+	if raw := b.Get([]byte(titleID)); raw != nil {
+		if err := json.Unmarshal(raw, &title); err != nil {
+			return err
+		}
+	}
+	update(&title)
+	seen(&title.FirstSeen, &title.LastSeen, now)
+	buf, err := json.Marshal(title)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(titleID), buf)
 }
 
-// DownloadAsked compute stats when we download a game
-func (s *stat) DownloadAsked(IP string, gameID string) error {
-	fmt.Println("[Stats] DownloadAsked", IP, gameID)
-	// TODO: Add in global IP download stats
+// seen updates first and last seen timestamps, zero time is ignored
+func seen(first *time.Time, last *time.Time, now time.Time) {
+	if now.IsZero() {
+		return
+	}
+	if first.IsZero() || now.Before(*first) {
+		*first = now
+	}
+	if now.After(*last) {
+		*last = now
+	}
+}
 
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte("global"))
+// Summary return the summary of all stats with the top most downloaded titles
+func (s *stat) Summary(top int) (repository.StatsSummary, error) {
+	summary := repository.StatsSummary{}
+	if s.db == nil {
+		return summary, nil
+	}
+	s.flush()
 
-		// Handle download
-		download := utils.ByteToUint64(b.Get([]byte("download")))
-		errDownload := b.Put([]byte("download"), utils.Itob(download+1))
-		if errDownload != nil {
-			return errDownload
-		}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		global := tx.Bucket([]byte(globalBucket))
+		summary.Visit = utils.ByteToUint64(global.Get([]byte(visitKey)))
+		summary.DownloadAsked = utils.ByteToUint64(global.Get([]byte(downloadKey)))
+		summary.DownloadCompleted = utils.ByteToUint64(global.Get([]byte(completedKey)))
+		summary.DownloadAborted = utils.ByteToUint64(global.Get([]byte(abortedKey)))
+		summary.BytesServed = utils.ByteToUint64(global.Get([]byte(bytesKey)))
 
-		// Handle download per IP
-		allDownloads, err := utils.ByteToMap(b.Get([]byte("downloadDetails")))
-		if err != nil {
-			return err
+		summary.Devices = make([]repository.DeviceStats, 0)
+		summary.VisitPerSwitch = make(map[string]interface{})
+		errDevices := tx.Bucket([]byte(devicesBucket)).ForEach(func(_, v []byte) error {
+			var device repository.DeviceStats
+			if err := json.Unmarshal(v, &device); err != nil {
+				return err
+			}
+			summary.Devices = append(summary.Devices, device)
+			summary.VisitPerSwitch[device.ID] = device.Visits
+			return nil
+		})
+		if errDevices != nil {
+			return errDevices
 		}
-		if allDownloads[IP] == nil {
-			allDownloads[IP] = make([]interface{}, 0)
+		summary.UniqueSwitch = uint64(len(summary.Devices))
+
+		summary.TopTitles = make([]repository.TitleStats, 0)
+		return tx.Bucket([]byte(titlesBucket)).ForEach(func(_, v []byte) error {
+			var title repository.TitleStats
+			if err := json.Unmarshal(v, &title); err != nil {
+				return err
+			}
+			summary.TopTitles = append(summary.TopTitles, title)
+			return nil
+		})
+	})
+	if err != nil {
+		return repository.StatsSummary{}, err
+	}
+
+	sort.SliceStable(summary.TopTitles, func(i, j int) bool {
+		a, b := summary.TopTitles[i], summary.TopTitles[j]
+		if a.Completed != b.Completed {
+			return a.Completed > b.Completed
 		}
-		allDownloads[IP] = append(allDownloads[IP].([]interface{}), gameID)
-		buf, err := json.Marshal(allDownloads)
-		if err != nil {
-			return err
+		if a.Asked != b.Asked {
+			return a.Asked > b.Asked
 		}
-		return b.Put([]byte("downloadDetails"), buf)
+		return a.TitleID < b.TitleID
 	})
+	if top > 0 && len(summary.TopTitles) > top {
+		summary.TopTitles = summary.TopTitles[:top]
+	}
+	sort.Slice(summary.Devices, func(i, j int) bool {
+		return summary.Devices[i].LastSeen.After(summary.Devices[j].LastSeen)
+	})
+
+	return summary, nil
 }
 
-// ListVisit count every visit to the listing page (either root or filter)
-func (s *stat) ListVisit(console *repository.Switch) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte("global"))
+// applyAsked counts a requested download
+func applyAsked(tx *bolt.Tx, gameID string, now time.Time) error {
+	if err := increment(tx.Bucket([]byte(globalBucket)), downloadKey, 1); err != nil {
+		return err
+	}
+	errPoint := updatePoint(tx, now, func(point *repository.SeriesPoint) {
+		point.Downloads++
+	})
+	if errPoint != nil {
+		return errPoint
+	}
+	return updateTitle(tx, gameID, now, func(title *repository.TitleStats) {
+		title.Asked++
+	})
+}
 
-		// Handle visit
-		visit := utils.ByteToUint64(b.Get([]byte("visit")))
-		errVisit := b.Put([]byte("visit"), utils.Itob(visit+1))
-		if errVisit != nil {
-			return errVisit
-		}
+// applyServed records the bytes sent for a download.
+// A download continuing a session only moves it from aborted to completed once all bytes are sent.
+func applyServed(tx *bolt.Tx, download repository.Download, bytes uint64, newSession bool, wasCompleted bool, sessionStart time.Time) error {
+	global := tx.Bucket([]byte(globalBucket))
+	if err := increment(global, bytesKey, bytes); err != nil {
+		return err
+	}
 
-		// Handle visit per switch
-		consoles, err := utils.ByteToMap(b.Get([]byte("switch")))
-		if err != nil {
+	countNew := newSession
+	countCompletion := !newSession && !wasCompleted && download.Completed
+
+	errPoint := updatePoint(tx, download.Time, func(point *repository.SeriesPoint) {
+		point.Bytes += bytes
+		switch {
+		case countNew && download.Completed:
+			point.Completed++
+		case countNew:
+			point.Aborted++
+		case countCompletion:
+			point.Completed++
+		}
+	})
+	if errPoint != nil {
+		return errPoint
+	}
+	if countCompletion {
+		// The session has been counted as aborted when it started
+		errStart := updatePoint(tx, sessionStart, func(point *repository.SeriesPoint) {
+			if point.Aborted > 0 {
+				point.Aborted--
+			}
+		})
+		if errStart != nil {
+			return errStart
+		}
+	}
+	if countNew {
+		resultKey := abortedKey
+		if download.Completed {
+			resultKey = completedKey
+		}
+		if err := increment(global, resultKey, 1); err != nil {
 			return err
 		}
-		currentID := console.UID
-		if currentID == "" {
-			currentID = "Unknown-" + console.IP
+	}
+	if countCompletion {
+		if err := increment(global, completedKey, 1); err != nil {
+			return err
 		}
+		if err := decrement(global, abortedKey); err != nil {
+			return err
+		}
+	}
 
-		if consoles[currentID] == nil {
-			consoles[currentID] = float64(0)
+	errTitle := updateTitle(tx, download.TitleID, download.Time, func(title *repository.TitleStats) {
+		title.Bytes += bytes
+		switch {
+		case countNew && download.Completed:
+			title.Completed++
+		case countNew:
+			title.Aborted++
+		case countCompletion:
+			title.Completed++
+			if title.Aborted > 0 {
+				title.Aborted--
+			}
 		}
-		consoles[currentID] = uint64(consoles[currentID].(float64)) + 1
-		buf, err := json.Marshal(consoles)
-		if err != nil {
-			return err
+	})
+	if errTitle != nil {
+		return errTitle
+	}
+
+	return updateDevice(tx, deviceID(download.Console), func(device *repository.DeviceStats) {
+		device.IP = download.Console.IP
+		device.Bytes += bytes
+		switch {
+		case countNew && !download.Completed:
+			device.Aborted++
+		case countNew || countCompletion:
+			device.Completed++
+			if countCompletion && device.Aborted > 0 {
+				device.Aborted--
+			}
+			if device.Titles == nil {
+				device.Titles = make(map[string]uint64)
+			}
+			device.Titles[download.TitleID]++
+		}
+		seen(&device.FirstSeen, &device.LastSeen, download.Time)
+	})
+}
+
+// applyVisit counts a visit of the listing page
+func applyVisit(tx *bolt.Tx, console repository.Switch, now time.Time) error {
+	if err := increment(tx.Bucket([]byte(globalBucket)), visitKey, 1); err != nil {
+		return err
+	}
+	errPoint := updatePoint(tx, now, func(point *repository.SeriesPoint) {
+		point.Visits++
+	})
+	if errPoint != nil {
+		return errPoint
+	}
+
+	return updateDevice(tx, deviceID(console), func(device *repository.DeviceStats) {
+		device.IP = console.IP
+		if console.Theme != "" {
+			device.Theme = console.Theme
+		}
+		if console.Version != "" {
+			device.Version = console.Version
+		}
+		if console.Language != "" {
+			device.Language = console.Language
 		}
-		return b.Put([]byte("switch"), buf)
+		device.Visits++
+		seen(&device.FirstSeen, &device.LastSeen, now)
 	})
 }
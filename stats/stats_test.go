@@ -0,0 +1,360 @@
+package stats_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/ajmandourah/tinshop-ng/mock_repository"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/stats"
+	"github.com/ajmandourah/tinshop-ng/utils"
+)
+
+var _ = Describe("Stats", func() {
+	var (
+		path    string
+		myStats repository.Stats
+		start   time.Time
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "stats.db")
+		start = time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	})
+	AfterEach(func() {
+		Expect(myStats.Close()).To(Succeed())
+	})
+
+	Context("Without database", func() {
+		BeforeEach(func() {
+			myStats = stats.New(path)
+		})
+		It("Fails the ping", func() {
+			Expect(myStats.Ping()).NotTo(Succeed())
+		})
+	})
+	Context("With an empty database", func() {
+		BeforeEach(func() {
+			myStats = stats.New(path)
+			myStats.Load()
+		})
+		It("Answers the ping", func() {
+			Expect(myStats.Ping()).To(Succeed())
+		})
+		It("Returns an empty summary", func() {
+			summary, err := myStats.Summary(10)
+			Expect(err).To(BeNil())
+			Expect(summary.Visit).To(BeZero())
+			Expect(summary.TopTitles).To(BeEmpty())
+			Expect(summary.Devices).To(BeEmpty())
+		})
+		It("Counts visits per device", func() {
+			Expect(myStats.ListVisit(&repository.Switch{UID: "UID1", IP: "10.0.0.1", Theme: "THEME"})).To(Succeed())
+			Expect(myStats.ListVisit(&repository.Switch{UID: "UID1", IP: "10.0.0.2"})).To(Succeed())
+			Expect(myStats.ListVisit(&repository.Switch{IP: "10.0.0.3"})).To(Succeed())
+
+			summary, err := myStats.Summary(10)
+			Expect(err).To(BeNil())
+			Expect(summary.Visit).To(Equal(uint64(3)))
+			Expect(summary.UniqueSwitch).To(Equal(uint64(2)))
+			Expect(summary.VisitPerSwitch).To(HaveKeyWithValue("UID1", uint64(2)))
+			Expect(summary.VisitPerSwitch).To(HaveKeyWithValue("Unknown-10.0.0.3", uint64(1)))
+			for _, device := range summary.Devices {
+				if device.ID == "UID1" {
+					Expect(device.IP).To(Equal("10.0.0.2"))
+					Expect(device.Theme).To(Equal("THEME"))
+					Expect(device.FirstSeen).ToNot(BeZero())
+				}
+			}
+		})
+		It("Counts downloads per title and device", func() {
+			console := repository.Switch{UID: "UID1", IP: "10.0.0.1"}
+			Expect(myStats.DownloadAsked("10.0.0.1", "0100000000010000")).To(Succeed())
+			Expect(myStats.DownloadServed(repository.Download{TitleID: "0100000000010000", Console: console, Bytes: 100, Completed: true, Time: start})).To(Succeed())
+			Expect(myStats.DownloadServed(repository.Download{TitleID: "0100000000010000", Console: console, Bytes: 40, Time: start.Add(time.Hour)})).To(Succeed())
+			Expect(myStats.DownloadServed(repository.Download{TitleID: "0100000000020000", Console: console, Bytes: 10, Completed: true, Time: start.Add(-time.Hour)})).To(Succeed())
+			Expect(myStats.DownloadServed(repository.Download{TitleID: "0100000000020000", Console: console, Bytes: 10, Completed: true, Time: start})).To(Succeed())
+			Expect(myStats.DownloadServed(repository.Download{TitleID: "0100000000030000", Console: console, Bytes: 1, Completed: true, Time: start})).To(Succeed())
+
+			summary, err := myStats.Summary(2)
+			Expect(err).To(BeNil())
+			Expect(summary.DownloadAsked).To(Equal(uint64(1)))
+			Expect(summary.DownloadCompleted).To(Equal(uint64(4)))
+			Expect(summary.DownloadAborted).To(Equal(uint64(1)))
+			Expect(summary.BytesServed).To(Equal(uint64(161)))
+
+			Expect(summary.TopTitles).To(HaveLen(2))
+			Expect(summary.TopTitles[0].TitleID).To(Equal("0100000000020000"))
+			Expect(summary.TopTitles[0].Completed).To(Equal(uint64(2)))
+			Expect(summary.TopTitles[0].FirstSeen).To(Equal(start.Add(-time.Hour)))
+			Expect(summary.TopTitles[0].LastSeen).To(Equal(start))
+			Expect(summary.TopTitles[1].TitleID).To(Equal("0100000000010000"))
+			Expect(summary.TopTitles[1].Asked).To(Equal(uint64(1)))
+			Expect(summary.TopTitles[1].Aborted).To(Equal(uint64(1)))
+			Expect(summary.TopTitles[1].Bytes).To(Equal(uint64(140)))
+
+			Expect(summary.Devices).To(HaveLen(1))
+			Expect(summary.Devices[0].Completed).To(Equal(uint64(4)))
+			Expect(summary.Devices[0].Aborted).To(Equal(uint64(1)))
+			Expect(summary.Devices[0].Titles).To(HaveKeyWithValue("0100000000020000", uint64(2)))
+			Expect(summary.Devices[0].LastSeen).To(Equal(start.Add(time.Hour)))
+		})
+	})
+
+	Context("With range requests", func() {
+		var console repository.Switch
+
+		BeforeEach(func() {
+			myStats = stats.New(path)
+			myStats.Load()
+			console = repository.Switch{UID: "UID1", IP: "10.0.0.1"}
+		})
+		It("Counts one asked download per install", func() {
+			for i := 0; i < 5; i++ {
+				Expect(myStats.DownloadAsked("10.0.0.1", "0100000000010000")).To(Succeed())
+			}
+			Expect(myStats.DownloadAsked("10.0.0.2", "0100000000010000")).To(Succeed())
+
+			summary, err := myStats.Summary(10)
+			Expect(err).To(BeNil())
+			Expect(summary.DownloadAsked).To(Equal(uint64(2)))
+			Expect(summary.TopTitles[0].Asked).To(Equal(uint64(2)))
+		})
+		It("Counts a completed download once all ranges are served", func() {
+			for i := 0; i < 4; i++ {
+				download := repository.Download{TitleID: "0100000000010000", Console: console, Bytes: 25, Size: 100, Time: start.Add(time.Duration(i) * time.Minute)}
+				Expect(myStats.DownloadServed(download)).To(Succeed())
+			}
+
+			summary, err := myStats.Summary(10)
+			Expect(err).To(BeNil())
+			Expect(summary.BytesServed).To(Equal(uint64(100)))
+			Expect(summary.DownloadCompleted).To(Equal(uint64(1)))
+			Expect(summary.DownloadAborted).To(BeZero())
+			Expect(summary.TopTitles[0].Completed).To(Equal(uint64(1)))
+			Expect(summary.TopTitles[0].Aborted).To(BeZero())
+			Expect(summary.Devices[0].Completed).To(Equal(uint64(1)))
+			Expect(summary.Devices[0].Titles).To(HaveKeyWithValue("0100000000010000", uint64(1)))
+		})
+		It("Counts an aborted download when ranges stop", func() {
+			for i := 0; i < 2; i++ {
+				download := repository.Download{TitleID: "0100000000010000", Console: console, Bytes: 25, Size: 100, Time: start.Add(time.Duration(i) * time.Minute)}
+				Expect(myStats.DownloadServed(download)).To(Succeed())
+			}
+
+			summary, err := myStats.Summary(10)
+			Expect(err).To(BeNil())
+			Expect(summary.BytesServed).To(Equal(uint64(50)))
+			Expect(summary.DownloadCompleted).To(BeZero())
+			Expect(summary.DownloadAborted).To(Equal(uint64(1)))
+		})
+		It("Writes pending events on close", func() {
+			Expect(myStats.ListVisit(&console)).To(Succeed())
+			Expect(myStats.Close()).To(Succeed())
+
+			myStats = stats.New(path)
+			myStats.Load()
+			summary, err := myStats.Summary(10)
+			Expect(err).To(BeNil())
+			Expect(summary.Visit).To(Equal(uint64(1)))
+		})
+		It("Drops the events sent once closed", func() {
+			Expect(myStats.Close()).To(Succeed())
+
+			// Closed again after each spec
+			Expect(myStats.ListVisit(&console)).To(Succeed())
+			Expect(myStats.DownloadAsked("10.0.0.1", "0000000000000001")).To(Succeed())
+		})
+	})
+
+	Context("Series", func() {
+		var console repository.Switch
+
+		BeforeEach(func() {
+			myStats = stats.New(path)
+			myStats.Load()
+			console = repository.Switch{UID: "UID1", IP: "10.0.0.1"}
+			now := time.Now().UTC()
+			start = time.Date(now.Year(), now.Month(), now.Day(), 10, 0, 0, 0, time.UTC).AddDate(0, 0, -10)
+
+			// Two downloads ten days ago and one today
+			Expect(myStats.DownloadServed(repository.Download{TitleID: "0100000000010000", Console: console, Bytes: 100, Completed: true, Time: start})).To(Succeed())
+			Expect(myStats.DownloadServed(repository.Download{TitleID: "0100000000020000", Console: console, Bytes: 50, Time: start.Add(3 * time.Hour)})).To(Succeed())
+			Expect(myStats.DownloadServed(repository.Download{TitleID: "0100000000010000", Console: console, Bytes: 10, Completed: true, Time: now})).To(Succeed())
+		})
+		It("Returns hourly points", func() {
+			points, err := myStats.Series(repository.SeriesQuery{From: start.Add(-time.Hour), To: start.Add(5 * time.Hour), Resolution: repository.SeriesHourly})
+			Expect(err).To(BeNil())
+			Expect(points).To(HaveLen(2))
+			Expect(points[0].Time).To(Equal(start))
+			Expect(points[0].Completed).To(Equal(uint64(1)))
+			Expect(points[0].Bytes).To(Equal(uint64(100)))
+			Expect(points[1].Aborted).To(Equal(uint64(1)))
+		})
+		It("Returns daily points including recent hours", func() {
+			points, err := myStats.Series(repository.SeriesQuery{From: start.AddDate(0, 0, -1), Resolution: repository.SeriesDaily})
+			Expect(err).To(BeNil())
+			Expect(points).To(HaveLen(2))
+			Expect(points[0].Time).To(Equal(start.Truncate(24 * time.Hour)))
+			Expect(points[0].Bytes).To(Equal(uint64(150)))
+			Expect(points[1].Bytes).To(Equal(uint64(10)))
+		})
+		It("Rolls up old hourly points into days", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			myMockConfig := mock_repository.NewMockConfig(ctrl)
+			myMockConfig.EXPECT().StatsHourlyRetention().Return(2).AnyTimes()
+			myMockConfig.EXPECT().StatsDailyRetention().Return(365).AnyTimes()
+			myStats.OnConfigUpdate(myMockConfig)
+
+			points, err := myStats.Series(repository.SeriesQuery{From: start.AddDate(0, 0, -1), Resolution: repository.SeriesHourly})
+			Expect(err).To(BeNil())
+			Expect(points).To(HaveLen(1))
+			Expect(points[0].Bytes).To(Equal(uint64(10)))
+
+			points, err = myStats.Series(repository.SeriesQuery{From: start.AddDate(0, 0, -1), Resolution: repository.SeriesDaily})
+			Expect(err).To(BeNil())
+			Expect(points).To(HaveLen(2))
+			Expect(points[0].Bytes).To(Equal(uint64(150)))
+			Expect(points[0].Completed).To(Equal(uint64(1)))
+			Expect(points[0].Aborted).To(Equal(uint64(1)))
+		})
+		It("Removes expired days", func() {
+			ctrl := gomock.NewController(GinkgoT())
+			myMockConfig := mock_repository.NewMockConfig(ctrl)
+			myMockConfig.EXPECT().StatsHourlyRetention().Return(2).AnyTimes()
+			myMockConfig.EXPECT().StatsDailyRetention().Return(5).AnyTimes()
+			myStats.OnConfigUpdate(myMockConfig)
+
+			points, err := myStats.Series(repository.SeriesQuery{From: start.AddDate(0, 0, -1), Resolution: repository.SeriesDaily})
+			Expect(err).To(BeNil())
+			Expect(points).To(HaveLen(1))
+			Expect(points[0].Bytes).To(Equal(uint64(10)))
+		})
+	})
+
+	Context("With a database of the first version", func() {
+		BeforeEach(func() {
+			db, err := bolt.Open(path, 0600, nil)
+			Expect(err).To(BeNil())
+			Expect(db.Update(func(tx *bolt.Tx) error {
+				b, errBucket := tx.CreateBucketIfNotExists([]byte("global"))
+				if errBucket != nil {
+					return errBucket
+				}
+				consoles, _ := json.Marshal(map[string]interface{}{"UID1": 3, "Unknown-10.0.0.3": 1})
+				downloads, _ := json.Marshal(map[string]interface{}{"10.0.0.1": []string{"0100000000010000", "0100000000010000"}})
+				_ = b.Put([]byte("visit"), utils.Itob(4))
+				_ = b.Put([]byte("download"), utils.Itob(2))
+				_ = b.Put([]byte("switch"), consoles)
+				return b.Put([]byte("downloadDetails"), downloads)
+			})).To(Succeed())
+			Expect(db.Close()).To(Succeed())
+
+			myStats = stats.New(path)
+			myStats.Load()
+		})
+		It("Migrates the old blobs", func() {
+			summary, err := myStats.Summary(10)
+			Expect(err).To(BeNil())
+			Expect(summary.Visit).To(Equal(uint64(4)))
+			Expect(summary.DownloadAsked).To(Equal(uint64(2)))
+			Expect(summary.UniqueSwitch).To(Equal(uint64(2)))
+			Expect(summary.VisitPerSwitch).To(HaveKeyWithValue("UID1", uint64(3)))
+			Expect(summary.TopTitles).To(HaveLen(1))
+			Expect(summary.TopTitles[0].Asked).To(Equal(uint64(2)))
+		})
+	})
+	Context("Export and import", func() {
+		var console repository.Switch
+
+		BeforeEach(func() {
+			myStats = stats.New(path)
+			Expect(myStats.Load()).To(Succeed())
+			console = repository.Switch{UID: "UID1", IP: "10.0.0.1", Theme: "OLD"}
+			start = time.Now().UTC().Truncate(time.Hour)
+
+			Expect(myStats.ListVisit(&console)).To(Succeed())
+			Expect(myStats.DownloadServed(repository.Download{TitleID: "0100000000010000", Console: console, Bytes: 100, Completed: true, Time: start})).To(Succeed())
+		})
+		It("Exports every table", func() {
+			export, err := myStats.Export()
+			Expect(err).To(BeNil())
+			Expect(export.Version).To(Equal(1))
+			Expect(export.Visit).To(Equal(uint64(1)))
+			Expect(export.BytesServed).To(Equal(uint64(100)))
+			Expect(export.Titles).To(HaveLen(1))
+			Expect(export.Devices).To(HaveLen(1))
+			Expect(export.Hourly).To(HaveLen(1))
+			Expect(export.Daily).To(BeEmpty())
+		})
+		It("Writes csv tables", func() {
+			export, err := myStats.Export()
+			Expect(err).To(BeNil())
+
+			var buf bytes.Buffer
+			Expect(stats.WriteCSV(&buf, export, "titles")).To(Succeed())
+			Expect(buf.String()).To(HavePrefix("titleId,asked,completed,aborted,bytes,firstSeen,lastSeen\n0100000000010000,0,1,0,100,"))
+
+			buf.Reset()
+			Expect(stats.WriteCSV(&buf, export, "devices")).To(Succeed())
+			Expect(strings.Split(buf.String(), "\n")[1]).To(HavePrefix("UID1,10.0.0.1,OLD,,,1,1,0,100,0100000000010000,"))
+
+			Expect(stats.WriteCSV(&buf, export, "unknown")).To(MatchError(stats.ErrUnknownTable))
+		})
+		It("Merges an export of another host", func() {
+			export, err := myStats.Export()
+			Expect(err).To(BeNil())
+			export.Devices[0].Theme = "NEW"
+			export.Devices[0].LastSeen = start.Add(time.Hour)
+			export.Devices = append(export.Devices, repository.DeviceStats{ID: "UID2", Visits: 2})
+			export.Daily = append(export.Daily, repository.SeriesPoint{Time: start.AddDate(0, 0, -30), Visits: 5})
+
+			Expect(myStats.Import(export)).To(Succeed())
+
+			summary, err := myStats.Summary(10)
+			Expect(err).To(BeNil())
+			Expect(summary.Visit).To(Equal(uint64(2)))
+			Expect(summary.DownloadCompleted).To(Equal(uint64(2)))
+			Expect(summary.BytesServed).To(Equal(uint64(200)))
+			Expect(summary.UniqueSwitch).To(Equal(uint64(2)))
+			Expect(summary.TopTitles[0].Completed).To(Equal(uint64(2)))
+			for _, device := range summary.Devices {
+				if device.ID == "UID1" {
+					Expect(device.Theme).To(Equal("NEW"))
+					Expect(device.Titles).To(HaveKeyWithValue("0100000000010000", uint64(2)))
+					Expect(device.LastSeen).To(Equal(start.Add(time.Hour)))
+				}
+			}
+
+			points, err := myStats.Series(repository.SeriesQuery{From: start.Add(-time.Hour), To: start.Add(time.Hour), Resolution: repository.SeriesHourly})
+			Expect(err).To(BeNil())
+			Expect(points).To(HaveLen(1))
+			Expect(points[0].Bytes).To(Equal(uint64(200)))
+			points, err = myStats.Series(repository.SeriesQuery{From: start.AddDate(0, 0, -31), To: start.AddDate(0, 0, -29), Resolution: repository.SeriesDaily})
+			Expect(err).To(BeNil())
+			Expect(points).To(HaveLen(1))
+			Expect(points[0].Visits).To(Equal(uint64(5)))
+		})
+		It("Refuses a newer export format", func() {
+			Expect(myStats.Import(repository.StatsExport{Version: 2})).To(HaveOccurred())
+		})
+	})
+	Context("With a wrong path", func() {
+		It("Returns the open error", func() {
+			myStats = stats.New(filepath.Join(path, "missing", "stats.db"))
+			Expect(myStats.Load()).To(HaveOccurred())
+			summary, err := myStats.Summary(10)
+			Expect(err).To(BeNil())
+			Expect(summary.Visit).To(BeZero())
+			Expect(myStats.Import(repository.StatsExport{})).To(HaveOccurred())
+		})
+	})
+})
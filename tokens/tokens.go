@@ -0,0 +1,236 @@
+// @title tinshop Tokens
+
+// @BasePath /tokens/
+
+// Package tokens provides the admin api tokens store
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// ErrInvalidToken is returned when the token is unknown, revoked or malformed
+	ErrInvalidToken = errors.New("invalid api token")
+	// ErrUnknownScope is returned when creating a token with an unknown scope
+	ErrUnknownScope = errors.New("unknown scope")
+)
+
+const (
+	tokensBucket = "tokens"
+	metaBucket   = "meta"
+	tokenPrefix  = "tsk"
+)
+
+// initializedKey is set once a token has been created, the bootstrap token is only created before
+var initializedKey = []byte("initialized") //nolint:gochecknoglobals
+
+type store struct {
+	path string
+	db   *bolt.DB
+}
+
+// New create a new tokens store
+func New(path string) repository.Tokens {
+	return &store{path: path}
+}
+
+func (s *store) initDB() {
+	_ = s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(tokensBucket))
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		meta, err := tx.CreateBucketIfNotExists([]byte(metaBucket))
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		// Databases of the previous versions have no marker
+		if b.Stats().KeyN > 0 {
+			return meta.Put(initializedKey, []byte("true"))
+		}
+		return nil
+	})
+}
+
+// Load opens the tokens database
+func (s *store) Load() {
+	db, err := bolt.Open(s.path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		log.Println("[Tokens] Unable to open tokens database", err)
+		return
+	}
+	s.db = db
+
+	s.initDB()
+}
+
+// Close closes the tokens database
+func (s *store) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Count returns the number of active tokens
+func (s *store) Count() int {
+	if s.db == nil {
+		return 0
+	}
+	var count int
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket([]byte(tokensBucket)).Stats().KeyN
+		return nil
+	})
+	return count
+}
+
+// List returns all active tokens
+func (s *store) List() ([]repository.APIToken, error) {
+	allTokens := make([]repository.APIToken, 0)
+	if s.db == nil {
+		return allTokens, nil
+	}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(tokensBucket)).ForEach(func(_, v []byte) error {
+			var token repository.APIToken
+			if err := json.Unmarshal(v, &token); err != nil {
+				return err
+			}
+			allTokens = append(allTokens, token)
+			return nil
+		})
+	})
+	return allTokens, err
+}
+
+// Create generates a new token and returns its secret, which is never stored
+func (s *store) Create(name string, scopes []string) (repository.APIToken, string, error) {
+	return s.create(name, scopes, false)
+}
+
+// Bootstrap creates the first token of the database and returns its secret.
+// Once a token has ever been created it returns an empty secret, even when they are all revoked.
+func (s *store) Bootstrap(scopes []string) (repository.APIToken, string, error) {
+	return s.create("bootstrap", scopes, true)
+}
+
+func (s *store) create(name string, scopes []string, bootstrap bool) (repository.APIToken, string, error) {
+	if s.db == nil {
+		return repository.APIToken{}, "", errors.New("tokens database is not opened")
+	}
+	for _, scope := range scopes {
+		if !utils.Contains(repository.AllScopes(), scope) {
+			return repository.APIToken{}, "", fmt.Errorf("%w '%s'", ErrUnknownScope, scope)
+		}
+	}
+
+	id, err := randomHex(6)
+	if err != nil {
+		return repository.APIToken{}, "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return repository.APIToken{}, "", err
+	}
+	fullToken := tokenPrefix + "_" + id + "_" + secret
+
+	token := repository.APIToken{
+		ID:        id,
+		Name:      name,
+		Hash:      hashToken(fullToken),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	buf, err := json.Marshal(token)
+	if err != nil {
+		return repository.APIToken{}, "", err
+	}
+	created := false
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket([]byte(metaBucket))
+		if bootstrap && meta.Get(initializedKey) != nil {
+			return nil
+		}
+		if err := meta.Put(initializedKey, []byte("true")); err != nil {
+			return err
+		}
+		created = true
+		return tx.Bucket([]byte(tokensBucket)).Put([]byte(id), buf)
+	})
+	if err != nil || !created {
+		return repository.APIToken{}, "", err
+	}
+	return token, fullToken, nil
+}
+
+// Revoke deletes a token
+func (s *store) Revoke(id string) error {
+	if s.db == nil {
+		return ErrInvalidToken
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(tokensBucket))
+		if b.Get([]byte(id)) == nil {
+			return ErrInvalidToken
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// Verify returns the token matching the secret
+func (s *store) Verify(fullToken string) (repository.APIToken, error) {
+	parts := strings.Split(fullToken, "_")
+	if s.db == nil || len(parts) != 3 || parts[0] != tokenPrefix {
+		return repository.APIToken{}, ErrInvalidToken
+	}
+
+	var token repository.APIToken
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(tokensBucket)).Get([]byte(parts[1]))
+		if v == nil {
+			return ErrInvalidToken
+		}
+		return json.Unmarshal(v, &token)
+	})
+	if err != nil {
+		return repository.APIToken{}, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hashToken(fullToken))) != 1 {
+		return repository.APIToken{}, ErrInvalidToken
+	}
+	return token, nil
+}
+
+// HasScope tells if the token has been granted the scope
+func HasScope(token repository.APIToken, scope string) bool {
+	return utils.Contains(token.Scopes, scope)
+}
+
+func hashToken(fullToken string) string {
+	sum := sha256.Sum256([]byte(fullToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,75 @@
+package tokens_test
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/tokens"
+)
+
+var _ = Describe("Tokens", func() {
+	var store repository.Tokens
+
+	BeforeEach(func() {
+		store = tokens.New(filepath.Join(GinkgoT().TempDir(), "tokens.db"))
+		store.Load()
+	})
+	AfterEach(func() {
+		Expect(store.Close()).To(Succeed())
+	})
+
+	It("Create and verify a token", func() {
+		token, secret, err := store.Create("backup script", []string{repository.ScopeReadStats})
+		Expect(err).To(BeNil())
+		Expect(secret).To(HavePrefix("tsk_" + token.ID + "_"))
+		Expect(token.Hash).NotTo(ContainSubstring(secret))
+
+		verified, err := store.Verify(secret)
+		Expect(err).To(BeNil())
+		Expect(verified.Name).To(Equal("backup script"))
+		Expect(tokens.HasScope(verified, repository.ScopeReadStats)).To(BeTrue())
+		Expect(tokens.HasScope(verified, repository.ScopeReload)).To(BeFalse())
+	})
+	It("Bootstrap only the first token", func() {
+		token, secret, err := store.Bootstrap(repository.AllScopes())
+		Expect(err).To(BeNil())
+		Expect(secret).NotTo(BeEmpty())
+		Expect(store.Revoke(token.ID)).To(Succeed())
+
+		_, secret, err = store.Bootstrap(repository.AllScopes())
+		Expect(err).To(BeNil())
+		Expect(secret).To(BeEmpty())
+		Expect(store.Count()).To(Equal(0))
+	})
+	It("Does not bootstrap once a token has been created", func() {
+		token, _, err := store.Create("backup script", []string{repository.ScopeReadStats})
+		Expect(err).To(BeNil())
+		Expect(store.Revoke(token.ID)).To(Succeed())
+
+		_, secret, err := store.Bootstrap(repository.AllScopes())
+		Expect(err).To(BeNil())
+		Expect(secret).To(BeEmpty())
+	})
+	It("Refuse unknown scopes", func() {
+		_, _, err := store.Create("bad", []string{"everything"})
+		Expect(err).To(MatchError(tokens.ErrUnknownScope))
+		Expect(store.Count()).To(Equal(0))
+	})
+	It("Refuse forged or revoked tokens", func() {
+		token, secret, err := store.Create("tmp", repository.AllScopes())
+		Expect(err).To(BeNil())
+
+		_, err = store.Verify("tsk_" + token.ID + "_deadbeef")
+		Expect(err).To(Equal(tokens.ErrInvalidToken))
+		_, err = store.Verify("not a token")
+		Expect(err).To(Equal(tokens.ErrInvalidToken))
+
+		Expect(store.Revoke(token.ID)).To(Succeed())
+		_, err = store.Verify(secret)
+		Expect(err).To(Equal(tokens.ErrInvalidToken))
+		Expect(store.Revoke(token.ID)).To(Equal(tokens.ErrInvalidToken))
+	})
+})
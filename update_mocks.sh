@@ -1,9 +1,15 @@
 #!/bin/bash
 
 mkdir -p mock_repository
-mockgen github.com/DblK/tinshop/repository Config > mock_repository/mock_config.go 
-mockgen github.com/DblK/tinshop/repository Source > mock_repository/mock_source.go 
-mockgen github.com/DblK/tinshop/repository Collection > mock_repository/mock_collection.go 
-mockgen github.com/DblK/tinshop/repository Sources > mock_repository/mock_sources.go 
-mockgen github.com/DblK/tinshop/repository Stats > mock_repository/mock_stats.go 
-mockgen github.com/DblK/tinshop/repository API > mock_repository/mock_api.go 
\ No newline at end of file
+mockgen github.com/ajmandourah/tinshop-ng/repository Config > mock_repository/mock_config.go 
+mockgen github.com/ajmandourah/tinshop-ng/repository Source > mock_repository/mock_source.go 
+mockgen github.com/ajmandourah/tinshop-ng/repository Collection > mock_repository/mock_collection.go 
+mockgen github.com/ajmandourah/tinshop-ng/repository Sources > mock_repository/mock_sources.go 
+mockgen github.com/ajmandourah/tinshop-ng/repository Stats > mock_repository/mock_stats.go 
+mockgen github.com/ajmandourah/tinshop-ng/repository API > mock_repository/mock_api.go
+mockgen github.com/ajmandourah/tinshop-ng/repository Users > mock_repository/mock_users.go 
+mockgen github.com/ajmandourah/tinshop-ng/repository Tokens > mock_repository/mock_tokens.go 
+mockgen github.com/ajmandourah/tinshop-ng/repository Audit > mock_repository/mock_audit.go 
+mockgen github.com/ajmandourah/tinshop-ng/repository IPFilter > mock_repository/mock_ipfilter.go
+mockgen github.com/ajmandourah/tinshop-ng/repository Devices > mock_repository/mock_devices.go 
+mockgen github.com/ajmandourah/tinshop-ng/repository Webhooks > mock_repository/mock_webhooks.go 
@@ -0,0 +1,42 @@
+package users
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// verified holds the digests of the passwords already checked against their bcrypt hash,
+// tinfoil sends the credentials with every request including each chunk of a download
+var (
+	verified    sync.Map   //nolint:gochecknoglobals
+	verifiedKey = newKey() //nolint:gochecknoglobals
+)
+
+func newKey() []byte {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return key
+}
+
+// ComparePassword checks the password against its bcrypt hash, a successful check is remembered.
+// The digest covers the hash so changing the password forgets it.
+func ComparePassword(hash string, password string) bool {
+	mac := hmac.New(sha256.New, verifiedKey)
+	mac.Write([]byte(hash))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	digest := string(mac.Sum(nil))
+
+	if _, ok := verified.Load(digest); ok {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+	verified.Store(digest, struct{}{})
+	return true
+}
@@ -0,0 +1,283 @@
+// @title tinshop Users
+
+// @BasePath /users/
+
+// Package users provides the account store used to authenticate switches
+package users
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// ErrUnknownUser is returned when the account does not exist
+	ErrUnknownUser = errors.New("unknown user")
+	// ErrWrongPassword is returned when the password does not match
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrDisabled is returned when the account is disabled
+	ErrDisabled = errors.New("account disabled")
+	// ErrExpired is returned when the account expiry date is over
+	ErrExpired = errors.New("account expired")
+	// ErrMissingDevice is returned when the account needs a device and none was sent
+	ErrMissingDevice = errors.New("missing device uid")
+	// ErrTooManyDevices is returned when all device slots of the account are bound
+	ErrTooManyDevices = errors.New("too many devices")
+)
+
+const usersBucket = "users"
+
+type store struct {
+	path string
+	db   *bolt.DB
+}
+
+// New create a new users store
+func New(path string) repository.Users {
+	return &store{path: path}
+}
+
+func (s *store) initDB() {
+	_ = s.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(usersBucket))
+		if err != nil {
+			return fmt.Errorf("create bucket: %s", err)
+		}
+		return nil
+	})
+}
+
+// Load opens the users database
+func (s *store) Load() {
+	db, err := bolt.Open(s.path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		log.Println("[Users] Unable to open users database", err)
+		return
+	}
+	s.db = db
+
+	s.initDB()
+}
+
+// Close closes the users database
+func (s *store) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Count returns the number of accounts
+func (s *store) Count() int {
+	if s.db == nil {
+		return 0
+	}
+	var count int
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket([]byte(usersBucket)).Stats().KeyN
+		return nil
+	})
+	return count
+}
+
+// List returns all accounts sorted by name
+func (s *store) List() ([]repository.User, error) {
+	allUsers := make([]repository.User, 0)
+	if s.db == nil {
+		return allUsers, nil
+	}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(usersBucket)).ForEach(func(_, v []byte) error {
+			var user repository.User
+			if err := json.Unmarshal(v, &user); err != nil {
+				return err
+			}
+			allUsers = append(allUsers, user)
+			return nil
+		})
+	})
+	return allUsers, err
+}
+
+// Get returns a single account
+func (s *store) Get(name string) (repository.User, error) {
+	var user repository.User
+	if s.db == nil {
+		return user, ErrUnknownUser
+	}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(usersBucket)).Get([]byte(name))
+		if v == nil {
+			return ErrUnknownUser
+		}
+		return json.Unmarshal(v, &user)
+	})
+	return user, err
+}
+
+// Save creates or replaces an account
+func (s *store) Save(user repository.User) error {
+	if user.Name == "" || strings.Contains(user.Name, ":") {
+		return errors.New("invalid user name '" + user.Name + "'")
+	}
+	if s.db == nil {
+		return errors.New("users database is not opened")
+	}
+	buf, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(usersBucket)).Put([]byte(user.Name), buf)
+	})
+}
+
+// Delete removes an account
+func (s *store) Delete(name string) error {
+	if _, err := s.Get(name); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(usersBucket)).Delete([]byte(name))
+	})
+}
+
+// Check verifies the credentials and the account without any device,
+// for the pages browsed outside of tinfoil which sends no Uid
+func (s *store) Check(name, password string) (repository.User, error) {
+	user, err := s.Get(name)
+	if err != nil {
+		return repository.User{}, err
+	}
+	if err := checkCredentials(user, password); err != nil {
+		return repository.User{}, err
+	}
+	return user, nil
+}
+
+// Authenticate verifies the credentials and binds the device if a slot is free.
+// The database is only written when a device is bound.
+func (s *store) Authenticate(name, password, uid string) (repository.User, error) {
+	user, err := s.Get(name)
+	if err != nil {
+		return repository.User{}, err
+	}
+	if err := checkAccount(user, password, uid); err != nil {
+		return repository.User{}, err
+	}
+	if user.MaxDevices == 0 || utils.Contains(user.Devices, uid) {
+		return user, nil
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		// The account may have changed since it was read
+		b := tx.Bucket([]byte(usersBucket))
+		v := b.Get([]byte(name))
+		if v == nil {
+			return ErrUnknownUser
+		}
+		if err := json.Unmarshal(v, &user); err != nil {
+			return err
+		}
+		if err := checkAccount(user, password, uid); err != nil {
+			return err
+		}
+		if utils.Contains(user.Devices, uid) {
+			return nil
+		}
+		if len(user.Devices) >= user.MaxDevices {
+			return ErrTooManyDevices
+		}
+
+		log.Println("[Users] Binding switch", uid, "to", user.Name)
+		user.Devices = append(user.Devices, uid)
+		buf, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(user.Name), buf)
+	})
+	if err != nil {
+		return repository.User{}, err
+	}
+	return user, nil
+}
+
+// checkAccount verifies the password and the state of the account, the device slots are not checked
+func checkAccount(user repository.User, password, uid string) error {
+	if err := checkCredentials(user, password); err != nil {
+		return err
+	}
+	if user.MaxDevices != 0 && uid == "" {
+		return ErrMissingDevice
+	}
+	return nil
+}
+
+// checkCredentials checks the password and that the account can be used
+func checkCredentials(user repository.User, password string) error {
+	if !ComparePassword(user.Password, password) {
+		return ErrWrongPassword
+	}
+	if !user.Enabled {
+		return ErrDisabled
+	}
+	if user.ExpiresAt != nil && time.Now().After(*user.ExpiresAt) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// UnbindDevice frees the device slot used by uid
+func (s *store) UnbindDevice(name, uid string) error {
+	if s.db == nil {
+		return ErrUnknownUser
+	}
+	// Read and written in the same transaction as a device may be bound meanwhile
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(usersBucket))
+		v := b.Get([]byte(name))
+		if v == nil {
+			return ErrUnknownUser
+		}
+		var user repository.User
+		if err := json.Unmarshal(v, &user); err != nil {
+			return err
+		}
+
+		idx := utils.Search(len(user.Devices), func(index int) bool {
+			return user.Devices[index] == uid
+		})
+		if idx == -1 {
+			return errors.New("device '" + uid + "' is not bound to " + name)
+		}
+		user.Devices = append(user.Devices[:idx], user.Devices[idx+1:]...)
+
+		buf, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(user.Name), buf)
+	})
+}
+
+// CanAccess tells if the account is allowed to browse the collection
+func CanAccess(user repository.User, collection string) bool {
+	if len(user.Collections) == 0 {
+		return true
+	}
+	for _, allowed := range user.Collections {
+		if strings.EqualFold(allowed, collection) {
+			return true
+		}
+	}
+	return false
+}
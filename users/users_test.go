@@ -0,0 +1,126 @@
+package users_test
+
+import (
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/users"
+)
+
+var _ = Describe("Users", func() {
+	var store repository.Users
+
+	BeforeEach(func() {
+		store = users.New(filepath.Join(GinkgoT().TempDir(), "users.db"))
+		store.Load()
+
+		hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+		Expect(err).To(BeNil())
+		Expect(store.Save(repository.User{
+			Name:       "kid",
+			Password:   string(hash),
+			Enabled:    true,
+			MaxDevices: 1,
+		})).To(Succeed())
+	})
+	AfterEach(func() {
+		Expect(store.Close()).To(Succeed())
+	})
+
+	It("Count and list accounts", func() {
+		Expect(store.Count()).To(Equal(1))
+		allUsers, err := store.List()
+		Expect(err).To(BeNil())
+		Expect(allUsers).To(HaveLen(1))
+		Expect(allUsers[0].Name).To(Equal("kid"))
+	})
+	It("Refuse invalid names", func() {
+		Expect(store.Save(repository.User{Name: "bad:name"})).NotTo(Succeed())
+		Expect(store.Save(repository.User{})).NotTo(Succeed())
+	})
+	Describe("Authenticate", func() {
+		It("Refuse unknown user or wrong password", func() {
+			_, err := store.Authenticate("nobody", "secret", "UID1")
+			Expect(err).To(Equal(users.ErrUnknownUser))
+			_, err = store.Authenticate("kid", "wrong", "UID1")
+			Expect(err).To(Equal(users.ErrWrongPassword))
+		})
+		It("Refuse disabled or expired accounts", func() {
+			user, _ := store.Get("kid")
+			user.Enabled = false
+			Expect(store.Save(user)).To(Succeed())
+			_, err := store.Authenticate("kid", "secret", "UID1")
+			Expect(err).To(Equal(users.ErrDisabled))
+
+			expired := time.Now().Add(-time.Hour)
+			user.Enabled = true
+			user.ExpiresAt = &expired
+			Expect(store.Save(user)).To(Succeed())
+			_, err = store.Authenticate("kid", "secret", "UID1")
+			Expect(err).To(Equal(users.ErrExpired))
+		})
+		It("Bind the first devices and refuse the others", func() {
+			user, err := store.Authenticate("kid", "secret", "UID1")
+			Expect(err).To(BeNil())
+			Expect(user.Devices).To(Equal([]string{"UID1"}))
+
+			_, err = store.Authenticate("kid", "secret", "UID1")
+			Expect(err).To(BeNil())
+			_, err = store.Authenticate("kid", "secret", "UID2")
+			Expect(err).To(Equal(users.ErrTooManyDevices))
+			_, err = store.Authenticate("kid", "secret", "")
+			Expect(err).To(Equal(users.ErrMissingDevice))
+		})
+		It("Check an account without binding a device", func() {
+			user, err := store.Check("kid", "secret")
+			Expect(err).To(BeNil())
+			Expect(user.Devices).To(BeEmpty())
+			_, err = store.Check("kid", "wrong")
+			Expect(err).To(Equal(users.ErrWrongPassword))
+
+			user.Enabled = false
+			Expect(store.Save(user)).To(Succeed())
+			_, err = store.Check("kid", "secret")
+			Expect(err).To(Equal(users.ErrDisabled))
+		})
+		It("Refuse the old password once changed", func() {
+			_, err := store.Authenticate("kid", "secret", "UID1")
+			Expect(err).To(BeNil())
+
+			user, _ := store.Get("kid")
+			hash, err := bcrypt.GenerateFromPassword([]byte("changed"), bcrypt.MinCost)
+			Expect(err).To(BeNil())
+			user.Password = string(hash)
+			Expect(store.Save(user)).To(Succeed())
+
+			_, err = store.Authenticate("kid", "secret", "UID1")
+			Expect(err).To(Equal(users.ErrWrongPassword))
+			_, err = store.Authenticate("kid", "changed", "UID1")
+			Expect(err).To(BeNil())
+		})
+		It("Unbinding a device frees the slot immediately", func() {
+			_, err := store.Authenticate("kid", "secret", "UID1")
+			Expect(err).To(BeNil())
+			Expect(store.UnbindDevice("kid", "UID1")).To(Succeed())
+
+			user, err := store.Authenticate("kid", "secret", "UID2")
+			Expect(err).To(BeNil())
+			Expect(user.Devices).To(Equal([]string{"UID2"}))
+		})
+	})
+	Describe("CanAccess", func() {
+		It("Allow everything without restriction", func() {
+			Expect(users.CanAccess(repository.User{}, "fr")).To(BeTrue())
+		})
+		It("Only allow listed collections", func() {
+			user := repository.User{Collections: []string{"world", "FR"}}
+			Expect(users.CanAccess(user, "fr")).To(BeTrue())
+			Expect(users.CanAccess(user, "multi")).To(BeFalse())
+		})
+	})
+})
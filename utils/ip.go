@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	trustedProxiesMutex sync.RWMutex //nolint:gochecknoglobals
+	trustedProxies      []*net.IPNet //nolint:gochecknoglobals
+)
+
+// ParseNetwork parses an ip or a network in CIDR notation, an ip is a network of one address
+func ParseNetwork(entry string) (*net.IPNet, error) {
+	cidr := strings.TrimSpace(entry)
+	if !strings.Contains(cidr, "/") {
+		ip := net.ParseIP(cidr)
+		if ip == nil {
+			return nil, fmt.Errorf("'%s' is not an ip", entry)
+		}
+		if ip.To4() != nil {
+			return &net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not a network in CIDR notation", entry)
+	}
+	return network, nil
+}
+
+// ParseNetworks parses the ips and networks, the error lists the invalid entries
+func ParseNetworks(entries []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(entries))
+	var invalid []string
+	for _, entry := range entries {
+		network, err := ParseNetwork(entry)
+		if err != nil {
+			invalid = append(invalid, entry)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	if len(invalid) > 0 {
+		return networks, fmt.Errorf("invalid ips or networks %v", invalid)
+	}
+	return networks, nil
+}
+
+// SetTrustedProxies sets the networks allowed to give the client ip through headers
+func SetTrustedProxies(cidrs []string) error {
+	networks, err := ParseNetworks(cidrs)
+
+	trustedProxiesMutex.Lock()
+	trustedProxies = networks
+	trustedProxiesMutex.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	return nil
+}
+
+// IsTrustedProxy tells if the ip belongs to a trusted proxy
+func IsTrustedProxy(ip net.IP) bool {
+	trustedProxiesMutex.RLock()
+	defer trustedProxiesMutex.RUnlock()
+
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetIPFromRequest returns the client ip of the request.
+// Forwarding headers are only used when sent by a trusted proxy.
+func GetIPFromRequest(r *http.Request) string {
+	remote := parseIP(r.RemoteAddr)
+	if remote == nil {
+		return r.RemoteAddr
+	}
+	if !IsTrustedProxy(remote) {
+		return remote.String()
+	}
+
+	hops := forwardedHops(r)
+	if len(hops) == 0 {
+		if realIP := parseIP(r.Header.Get("X-Real-Ip")); realIP != nil {
+			return realIP.String()
+		}
+		return remote.String()
+	}
+
+	// Walk the chain from the closest hop and stop at the first untrusted one
+	client := remote
+	for index := len(hops) - 1; index >= 0; index-- {
+		hop := parseIP(hops[index])
+		if hop == nil {
+			break
+		}
+		client = hop
+		if !IsTrustedProxy(hop) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// forwardedHops returns the addresses listed in Forwarded or X-Forwarded-For, client first
+func forwardedHops(r *http.Request) []string {
+	hops := make([]string, 0)
+	for _, header := range r.Header.Values("Forwarded") {
+		for _, element := range strings.Split(header, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "for") {
+					hops = append(hops, strings.Trim(value, `"`))
+				}
+			}
+		}
+	}
+	if len(hops) > 0 {
+		return hops
+	}
+
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// parseIP parses an address with or without port, ipv6 may be enclosed in brackets
+func parseIP(address string) net.IP {
+	address = strings.TrimSpace(address)
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	address = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+	if zone := strings.Index(address, "%"); zone >= 0 {
+		address = address[:zone]
+	}
+	return net.ParseIP(address)
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+)
+
+// ResponseCounter wraps a http.ResponseWriter to know what has been served
+type ResponseCounter struct {
+	http.ResponseWriter
+	Status int
+	Bytes  int64
+}
+
+// NewResponseCounter returns a new ResponseCounter around w
+func NewResponseCounter(w http.ResponseWriter) *ResponseCounter {
+	return &ResponseCounter{ResponseWriter: w}
+}
+
+// WriteHeader records the status code sent
+func (rc *ResponseCounter) WriteHeader(statusCode int) {
+	if rc.Status == 0 {
+		rc.Status = statusCode
+	}
+	rc.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write counts the bytes written
+func (rc *ResponseCounter) Write(b []byte) (int, error) {
+	if rc.Status == 0 {
+		rc.Status = http.StatusOK
+	}
+	n, err := rc.ResponseWriter.Write(b)
+	rc.Bytes += int64(n)
+	return n, err
+}
+
+// ReadFrom keeps the sendfile optimization of the wrapped writer
+func (rc *ResponseCounter) ReadFrom(src io.Reader) (int64, error) {
+	if rc.Status == 0 {
+		rc.Status = http.StatusOK
+	}
+	var n int64
+	var err error
+	if rf, ok := rc.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		n, err = io.Copy(rc.ResponseWriter, src)
+	}
+	rc.Bytes += n
+	return n, err
+}
+
+// Flush sends any buffered data to the client
+func (rc *ResponseCounter) Flush() {
+	if f, ok := rc.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -9,7 +9,7 @@ import (
 	"fmt"
 	"log"
 	"math/big"
-	"net/http"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -18,6 +18,7 @@ import (
 	"github.com/ajmandourah/tinshop-ng/fileio"
 	"github.com/ajmandourah/tinshop-ng/gameid"
 	"github.com/ajmandourah/tinshop-ng/keys"
+	"github.com/ajmandourah/tinshop-ng/metrics"
 	"github.com/ajmandourah/tinshop-ng/repository"
 )
 
@@ -47,6 +48,7 @@ func ExtractGameID(fileName string) (repository.GameID, bool) {
 		if keys.UseKey {
 			metadata, err := fileio.DecryptMetadata(fileName)
 			if err != nil {
+				metrics.DecryptionFailures.WithLabelValues().Inc()
 				return gameid.New("", "", ""), false
 			}
 			info := "[" + strings.ToUpper(metadata.TitleId) + "][v" + strconv.Itoa(metadata.Version) + "]." + ext[len(ext)-1]
@@ -101,6 +103,13 @@ func GetTitleMeta(titleID string) (string, bool, bool) {
 	return baseID, update, dlc
 }
 
+// IsInPath tells if path is dir or is inside dir
+func IsInPath(dir string, path string) bool {
+	dir = filepath.Clean(dir)
+	path = filepath.Clean(path)
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
 // Search returns the index in an object
 func Search(length int, f func(index int) bool) int {
 	for index := 0; index < length; index++ {
@@ -147,12 +156,3 @@ func Contains(list interface{}, elem interface{}) bool {
 	}
 	return false
 }
-
-// GetIPFromRequest returns ip from the request
-func GetIPFromRequest(r *http.Request) string {
-	ip := strings.Split(r.RemoteAddr, ":")[0]
-	if r.Header.Get("X-Forwarded-For") != "" {
-		ip = r.Header.Get("X-Forwarded-For")
-	}
-	return ip
-}
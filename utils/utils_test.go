@@ -7,6 +7,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	"github.com/ajmandourah/tinshop-ng/keys"
 	"github.com/ajmandourah/tinshop-ng/repository"
 	"github.com/ajmandourah/tinshop-ng/utils"
 )
@@ -15,63 +16,63 @@ var _ = Describe("Utils", func() {
 	Describe("ExtractGameId", func() {
 		Context("Should succeed", func() {
 			It("Nicely separated groups", func() {
-				game := utils.ExtractGameID("Paw Patrol Mighty Pups Save Adventure Bay [01001F201121E800][v131072] (1.58 GB).nsz")
+				game, _ := utils.ExtractGameID("Paw Patrol Mighty Pups Save Adventure Bay [01001F201121E800][v131072] (1.58 GB).nsz")
 
 				Expect(game.Extension()).To(Equal("nsz"))
 				Expect(game.ShortID()).To(Equal("01001F201121E800"))
 				Expect(game.FullID()).To(Equal("[01001F201121E800][v131072].nsz"))
 			})
 			It("Make upper of Game Id", func() {
-				game := utils.ExtractGameID("Game [01001f201121e800][v131072] (1.58 GB).nsz")
+				game, _ := utils.ExtractGameID("Game [01001f201121e800][v131072] (1.58 GB).nsz")
 
 				Expect(game.Extension()).To(Equal("nsz"))
 				Expect(game.ShortID()).To(Equal("01001F201121E800"))
 				Expect(game.FullID()).To(Equal("[01001F201121E800][v131072].nsz"))
 			})
 			It("Should only take interesting part", func() {
-				game := utils.ExtractGameID("Luigi’s Mansion 3 [Luigi’s Mansion 3 Multiplayer Pack 1][0100DCA0064A7001][US][v131072].nsp")
+				game, _ := utils.ExtractGameID("Luigi’s Mansion 3 [Luigi’s Mansion 3 Multiplayer Pack 1][0100DCA0064A7001][US][v131072].nsp")
 
 				Expect(game.Extension()).To(Equal("nsp"))
 				Expect(game.ShortID()).To(Equal("0100DCA0064A7001"))
 				Expect(game.FullID()).To(Equal("[0100DCA0064A7001][v131072].nsp"))
 			})
 			It("Group tied with parenthesis group", func() {
-				game := utils.ExtractGameID("Paw Patrol Mighty Pups Save Adventure Bay [01001F201121E800][v131072](1.58 GB).nsz")
+				game, _ := utils.ExtractGameID("Paw Patrol Mighty Pups Save Adventure Bay [01001F201121E800][v131072](1.58 GB).nsz")
 
 				Expect(game.Extension()).To(Equal("nsz"))
 				Expect(game.ShortID()).To(Equal("01001F201121E800"))
 				Expect(game.FullID()).To(Equal("[01001F201121E800][v131072].nsz"))
 			})
 			It("Nice filename with nsp file", func() {
-				game := utils.ExtractGameID("Super Mario Odyssey [0100000000010000][v0].nsp")
+				game, _ := utils.ExtractGameID("Super Mario Odyssey [0100000000010000][v0].nsp")
 
 				Expect(game.Extension()).To(Equal("nsp"))
 				Expect(game.ShortID()).To(Equal("0100000000010000"))
 				Expect(game.FullID()).To(Equal("[0100000000010000][v0].nsp"))
 			})
 			It("Nice separated DLC information", func() {
-				game := utils.ExtractGameID("The Legend of Zelda Breath of the Wild [DLC Pack 1 The Master Trials] [01007EF00011F001][v196608].nsp")
+				game, _ := utils.ExtractGameID("The Legend of Zelda Breath of the Wild [DLC Pack 1 The Master Trials] [01007EF00011F001][v196608].nsp")
 
 				Expect(game.Extension()).To(Equal("nsp"))
 				Expect(game.ShortID()).To(Equal("01007EF00011F001"))
 				Expect(game.FullID()).To(Equal("[01007EF00011F001][v196608].nsp"))
 			})
 			It("Tied DLC info to game id and version", func() {
-				game := utils.ExtractGameID("Fake - The Legend of Zelda Breath of the Wild [DLC Pack 1 The Master Trials][01007EF00011F001][v196608].nsp")
+				game, _ := utils.ExtractGameID("Fake - The Legend of Zelda Breath of the Wild [DLC Pack 1 The Master Trials][01007EF00011F001][v196608].nsp")
 
 				Expect(game.Extension()).To(Equal("nsp"))
 				Expect(game.ShortID()).To(Equal("01007EF00011F001"))
 				Expect(game.FullID()).To(Equal("[01007EF00011F001][v196608].nsp"))
 			})
 			It("Tied DLC info with no space to game id and version", func() {
-				game := utils.ExtractGameID("Fake - The Legend of Zelda Breath of the Wild [DLCPack1TheMasterTrials][01007EF00011F001][v196608].nsp")
+				game, _ := utils.ExtractGameID("Fake - The Legend of Zelda Breath of the Wild [DLCPack1TheMasterTrials][01007EF00011F001][v196608].nsp")
 
 				Expect(game.Extension()).To(Equal("nsp"))
 				Expect(game.ShortID()).To(Equal("01007EF00011F001"))
 				Expect(game.FullID()).To(Equal("[01007EF00011F001][v196608].nsp"))
 			})
 			It("Game inside sub directory", func() {
-				game := utils.ExtractGameID("Fake - My Directory/Fake - [0100152000022800][v655360].nsz")
+				game, _ := utils.ExtractGameID("Fake - My Directory/Fake - [0100152000022800][v655360].nsz")
 
 				Expect(game.Extension()).To(Equal("nsz"))
 				Expect(game.ShortID()).To(Equal("0100152000022800"))
@@ -79,29 +80,37 @@ var _ = Describe("Utils", func() {
 			})
 		})
 		Context("Should Fail", func() {
+			BeforeEach(func() {
+				// The names are only parsed, the files do not exist to be decrypted
+				keys.UseKey = false
+			})
+			AfterEach(func() {
+				keys.UseKey = true
+			})
+
 			It("Test with not size valid game id", func() {
-				game := utils.ExtractGameID("Fake - My Game [NSP]/Fake - My Own Game [1231231][v0].nsz")
+				game, _ := utils.ExtractGameID("Fake - My Game [NSP]/Fake - My Own Game [1231231][v0].nsz")
 
 				Expect(game.Extension()).To(BeEmpty())
 				Expect(game.ShortID()).To(BeEmpty())
 				Expect(game.FullID()).To(BeEmpty())
 			})
 			It("Test with bad number of version", func() {
-				game := utils.ExtractGameID("Fake - My Game [NSP]/Fake - My Own Game [0100152000022800][0].nsz")
+				game, _ := utils.ExtractGameID("Fake - My Game [NSP]/Fake - My Own Game [0100152000022800][0].nsz")
 
 				Expect(game.Extension()).To(BeEmpty())
 				Expect(game.ShortID()).To(BeEmpty())
 				Expect(game.FullID()).To(BeEmpty())
 			})
 			It("Test with no game id no version", func() {
-				game := utils.ExtractGameID("Fake - Bad name.txt")
+				game, _ := utils.ExtractGameID("Fake - Bad name.txt")
 
 				Expect(game.Extension()).To(BeEmpty())
 				Expect(game.ShortID()).To(BeEmpty())
 				Expect(game.FullID()).To(BeEmpty())
 			})
 			It("Test with double extension", func() {
-				game := utils.ExtractGameID("Fake - Bad name.old.txt")
+				game, _ := utils.ExtractGameID("Fake - Bad name.old.txt")
 
 				Expect(game.Extension()).To(BeEmpty())
 				Expect(game.ShortID()).To(BeEmpty())
@@ -229,17 +238,68 @@ var _ = Describe("Utils", func() {
 		})
 	})
 	Describe("GetIPFromRequest", func() {
+		BeforeEach(func() {
+			Expect(utils.SetTrustedProxies([]string{"10.0.0.0/8", "fd00::/8"})).To(Succeed())
+		})
+		AfterEach(func() {
+			Expect(utils.SetTrustedProxies([]string{})).To(Succeed())
+		})
 		It("Test with ip", func() {
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
 			req.RemoteAddr = "10.0.0.10"
 			Expect(utils.GetIPFromRequest(req)).To(Equal("10.0.0.10"))
 		})
+		It("Test with ip and port", func() {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "10.0.0.10:51234"
+			Expect(utils.GetIPFromRequest(req)).To(Equal("10.0.0.10"))
+		})
+		It("Test with ipv6 and port", func() {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "[2001:db8::1]:51234"
+			Expect(utils.GetIPFromRequest(req)).To(Equal("2001:db8::1"))
+		})
 		It("Test with ip and X-Forwarded-For", func() {
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
 			req.RemoteAddr = "10.0.0.10"
 			req.Header.Set("X-Forwarded-For", "1.1.1.1")
 			Expect(utils.GetIPFromRequest(req)).To(Equal("1.1.1.1"))
 		})
+		It("Test with X-Forwarded-For from an untrusted client", func() {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "2.2.2.2:51234"
+			req.Header.Set("X-Forwarded-For", "1.1.1.1")
+			Expect(utils.GetIPFromRequest(req)).To(Equal("2.2.2.2"))
+		})
+		It("Test with a spoofed X-Forwarded-For chain", func() {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "10.0.0.10:51234"
+			req.Header.Set("X-Forwarded-For", "6.6.6.6, 1.1.1.1, 10.0.0.2")
+			Expect(utils.GetIPFromRequest(req)).To(Equal("1.1.1.1"))
+		})
+		It("Test with X-Real-Ip", func() {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "10.0.0.10:51234"
+			req.Header.Set("X-Real-Ip", "1.1.1.1")
+			Expect(utils.GetIPFromRequest(req)).To(Equal("1.1.1.1"))
+		})
+		It("Test with Forwarded", func() {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "[fd00::1]:51234"
+			req.Header.Set("Forwarded", `for="[2001:db8::2]:4711";proto=https, for=10.0.0.2`)
+			Expect(utils.GetIPFromRequest(req)).To(Equal("2001:db8::2"))
+		})
+		It("Test parsing networks", func() {
+			networks, err := utils.ParseNetworks([]string{" 10.0.0.1 ", "fd00::/8", "notanip", "10.0.0.0/33"})
+			Expect(err).To(MatchError(ContainSubstring("notanip")))
+			Expect(err).To(MatchError(ContainSubstring("10.0.0.0/33")))
+			Expect(networks).To(HaveLen(2))
+			Expect(networks[0].String()).To(Equal("10.0.0.1/32"))
+			Expect(networks[1].String()).To(Equal("fd00::/8"))
+		})
+		It("Test with invalid trusted proxies", func() {
+			Expect(utils.SetTrustedProxies([]string{"10.0.0.1", "notanip"})).ToNot(Succeed())
+		})
 	})
 	Describe("Search", func() {
 		It("Test with not found value", func() {
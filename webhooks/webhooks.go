@@ -0,0 +1,326 @@
+// @title tinshop Webhooks
+
+// @BasePath /webhooks/
+
+// Package webhooks provides the notification of urls on shop events
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ajmandourah/tinshop-ng/events"
+	"github.com/ajmandourah/tinshop-ng/library"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/utils"
+	"github.com/avast/retry-go"
+)
+
+const (
+	// attempts is the number of deliveries tried before giving up
+	attempts = 5
+	// retryDelay is the delay before the first retry, doubled after each attempt
+	retryDelay = time.Second
+	// failedLoginBurst is the number of failed logins from an ip sent as a burst
+	failedLoginBurst = 5
+	// failedLoginWindow is the time in which the failed logins must happen
+	failedLoginWindow = time.Minute
+	// deviceBlockedInterval avoids a notification for each request of a blocked switch
+	deviceBlockedInterval = time.Hour
+)
+
+type failedLogins struct {
+	count int
+	since time.Time
+}
+
+type webhooks struct {
+	mutex      sync.Mutex
+	webhooks   []repository.Webhook
+	sources    repository.Sources
+	collection repository.Collection
+	client     *http.Client
+
+	// known holds the files already in the library to only notify new ones
+	known         map[string]bool
+	failedLogins  map[string]*failedLogins
+	blockedNotify map[string]time.Time
+	lastPrune     time.Time
+	lastID        uint64
+
+	unsubscribe func()
+	done        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// New create the webhooks following the events of the shop
+func New(sources repository.Sources, collection repository.Collection) repository.Webhooks {
+	hooks := &webhooks{
+		sources:       sources,
+		collection:    collection,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		known:         make(map[string]bool),
+		failedLogins:  make(map[string]*failedLogins),
+		blockedNotify: make(map[string]time.Time),
+		done:          make(chan struct{}),
+	}
+	for _, file := range sources.GetFiles() {
+		hooks.known[fileKey(file)] = true
+	}
+
+	// Each new file is an event, they must not be dropped when a scan adds many at once
+	stream, unsubscribe := events.Default.SubscribeQueue(repository.EventTitleAdded, repository.EventSecurityBlocked, repository.EventScanFinished)
+	hooks.unsubscribe = unsubscribe
+	go func() {
+		defer close(hooks.done)
+		for event := range stream {
+			hooks.handle(event)
+		}
+	}()
+	return hooks
+}
+
+// OnConfigUpdate keeps the valid webhooks of the configuration
+func (h *webhooks) OnConfigUpdate(cfg repository.Config) {
+	validWebhooks := make([]repository.Webhook, 0)
+	for _, webhook := range cfg.Webhooks() {
+		target, err := url.Parse(webhook.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			log.Printf("[Webhooks] Ignoring webhook with invalid url '%s'\n", webhook.URL)
+			continue
+		}
+		for _, event := range webhook.Events {
+			if !utils.Contains(repository.WebhookEvents(), event) {
+				log.Printf("[Webhooks] Unknown event '%s' for webhook '%s'\n", event, target.Host)
+			}
+		}
+		validWebhooks = append(validWebhooks, webhook)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.webhooks = validWebhooks
+}
+
+// Close stops following the events and waits for the pending deliveries
+func (h *webhooks) Close() {
+	h.unsubscribe()
+	<-h.done
+	h.wg.Wait()
+}
+
+// handle turns a shop event into a webhook event
+func (h *webhooks) handle(event repository.Event) {
+	switch data := event.Data.(type) {
+	case repository.TitleEvent:
+		if event.Type == repository.EventTitleAdded {
+			h.titleAdded(data)
+		}
+	case repository.AuditEvent:
+		switch data.Type {
+		case repository.AuditBlockedDevice:
+			h.deviceBlocked(data)
+		case repository.AuditFailedLogin:
+			h.failedLogin(data)
+		}
+	case repository.ScanStatus:
+		if event.Type == repository.EventScanFinished && data.Failed > 0 {
+			h.notify(repository.WebhookScanError, data)
+		}
+	}
+}
+
+func (h *webhooks) titleAdded(title repository.TitleEvent) {
+	file := repository.FileDesc{GameID: title.ID, Path: title.Path, Size: title.Size, HostType: title.Source}
+	for _, sourceFile := range h.sources.GetFiles() {
+		if sourceFile.GameID == title.ID && sourceFile.Path == title.Path {
+			file = sourceFile
+			break
+		}
+	}
+
+	h.mutex.Lock()
+	key := fileKey(file)
+	known := h.known[key]
+	h.known[key] = true
+	h.mutex.Unlock()
+	if known {
+		return
+	}
+
+	libraryFile := library.NewFile(file, h.collection.Library())
+	switch libraryFile.Type {
+	case repository.ContentBase:
+		h.notify(repository.WebhookTitleAdded, libraryFile)
+	case repository.ContentUpdate:
+		if h.sources.HasGame(libraryFile.BaseID) {
+			h.notify(repository.WebhookUpdateAdded, libraryFile)
+		}
+	}
+}
+
+func (h *webhooks) deviceBlocked(event repository.AuditEvent) {
+	h.mutex.Lock()
+	h.prune(event.Time)
+	last, notified := h.blockedNotify[event.UID]
+	if notified && event.Time.Sub(last) < deviceBlockedInterval {
+		h.mutex.Unlock()
+		return
+	}
+	h.blockedNotify[event.UID] = event.Time
+	h.mutex.Unlock()
+
+	h.notify(repository.WebhookDeviceBlocked, event)
+}
+
+func (h *webhooks) failedLogin(event repository.AuditEvent) {
+	h.mutex.Lock()
+	h.prune(event.Time)
+	logins, ok := h.failedLogins[event.IP]
+	if !ok || event.Time.Sub(logins.since) > failedLoginWindow {
+		logins = &failedLogins{since: event.Time}
+		h.failedLogins[event.IP] = logins
+	}
+	logins.count++
+	burst := repository.FailedLoginBurst{
+		IP:       event.IP,
+		Attempts: logins.count,
+		Since:    logins.since,
+	}
+	h.mutex.Unlock()
+
+	// Notified once per window
+	if burst.Attempts == failedLoginBurst {
+		h.notify(repository.WebhookFailedLoginBurst, burst)
+	}
+}
+
+// prune forgets the ips and switches whose window is over, at most once per failedLoginWindow.
+// It is called with the mutex held
+func (h *webhooks) prune(now time.Time) {
+	if now.Sub(h.lastPrune) < failedLoginWindow {
+		return
+	}
+	h.lastPrune = now
+	for ip, logins := range h.failedLogins {
+		if now.Sub(logins.since) > failedLoginWindow {
+			delete(h.failedLogins, ip)
+		}
+	}
+	for uid, last := range h.blockedNotify {
+		if now.Sub(last) >= deviceBlockedInterval {
+			delete(h.blockedNotify, uid)
+		}
+	}
+}
+
+// notify sends the event to all webhooks following it
+func (h *webhooks) notify(event string, data interface{}) {
+	h.mutex.Lock()
+	h.lastID++
+	payload := repository.WebhookPayload{
+		ID:    strconv.FormatInt(time.Now().Unix(), 10) + "-" + strconv.FormatUint(h.lastID, 10),
+		Event: event,
+		Time:  time.Now().UTC(),
+		Data:  data,
+	}
+	targets := make([]repository.Webhook, 0)
+	for _, webhook := range h.webhooks {
+		if len(webhook.Events) == 0 || utils.Contains(webhook.Events, event) {
+			targets = append(targets, webhook)
+		}
+	}
+	h.mutex.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Println("[Webhooks] Unable to encode", event, err)
+		return
+	}
+	for _, webhook := range targets {
+		h.wg.Add(1)
+		go func(webhook repository.Webhook) {
+			defer h.wg.Done()
+			h.deliver(webhook, payload, body)
+		}(webhook)
+	}
+}
+
+// deliver posts the payload, retrying with backoff when the webhook is unavailable
+func (h *webhooks) deliver(webhook repository.Webhook, payload repository.WebhookPayload, body []byte) {
+	err := retry.Do(
+		func() error {
+			return h.post(webhook, payload, body)
+		},
+		retry.Attempts(attempts),
+		retry.Delay(retryDelay),
+		retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true),
+		retry.OnRetry(func(n uint, err error) {
+			log.Printf("[Webhooks] Delivery %s of %s failed (attempt %d): %s\n", payload.ID, payload.Event, n+1, err)
+		}),
+	)
+	if err != nil {
+		log.Printf("[Webhooks] Unable to deliver %s of %s: %s\n", payload.ID, payload.Event, err)
+		return
+	}
+	log.Printf("[Webhooks] Delivered %s of %s\n", payload.ID, payload.Event)
+}
+
+func (h *webhooks) post(webhook repository.Webhook, payload repository.WebhookPayload, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return retry.Unrecoverable(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TinShop-Webhooks")
+	req.Header.Set("X-Tinshop-Event", payload.Event)
+	req.Header.Set("X-Tinshop-Delivery", payload.ID)
+	if webhook.Secret != "" {
+		req.Header.Set("X-Tinshop-Signature", Sign(webhook.Secret, body))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+	// A refused payload will not be accepted later
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return retry.Unrecoverable(err)
+	}
+	return err
+}
+
+// Sign returns the signature of the body sent in the X-Tinshop-Signature header
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// fileKey identifies a file with its version, a new version of an update is a new file
+func fileKey(file repository.FileDesc) string {
+	if file.GameInfo != "" {
+		return file.GameInfo
+	}
+	return file.GameID
+}
@@ -0,0 +1,177 @@
+package webhooks_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/ajmandourah/tinshop-ng/events"
+	"github.com/ajmandourah/tinshop-ng/mock_repository"
+	"github.com/ajmandourah/tinshop-ng/repository"
+	"github.com/ajmandourah/tinshop-ng/webhooks"
+)
+
+type delivery struct {
+	header  http.Header
+	body    []byte
+	payload map[string]interface{}
+}
+
+var _ = Describe("Webhooks", func() {
+	var (
+		ctrl          *gomock.Controller
+		myMockConfig  *mock_repository.MockConfig
+		myMockSources *mock_repository.MockSources
+		myMockCollect *mock_repository.MockCollection
+		server        *httptest.Server
+		received      chan delivery
+		status        int32
+		files         []repository.FileDesc
+		hooks         repository.Webhooks
+		configured    []repository.Webhook
+	)
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		received = make(chan delivery, 10)
+		atomic.StoreInt32(&status, http.StatusOK)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			var payload map[string]interface{}
+			_ = json.Unmarshal(body, &payload)
+			received <- delivery{header: r.Header, body: body, payload: payload}
+			w.WriteHeader(int(atomic.LoadInt32(&status)))
+		}))
+		files = nil
+		configured = []repository.Webhook{{URL: server.URL, Secret: "secret"}}
+
+		myMockConfig = mock_repository.NewMockConfig(ctrl)
+		myMockConfig.EXPECT().Webhooks().DoAndReturn(func() []repository.Webhook { return configured }).AnyTimes()
+		myMockSources = mock_repository.NewMockSources(ctrl)
+		myMockSources.EXPECT().GetFiles().DoAndReturn(func() []repository.FileDesc { return files }).AnyTimes()
+		myMockCollect = mock_repository.NewMockCollection(ctrl)
+		myMockCollect.EXPECT().Library().Return(map[string]repository.TitleDBEntry{
+			"0100000000010000": {ID: "0100000000010000", Name: "My Game"},
+		}).AnyTimes()
+	})
+	JustBeforeEach(func() {
+		hooks = webhooks.New(myMockSources, myMockCollect)
+		hooks.OnConfigUpdate(myMockConfig)
+	})
+	AfterEach(func() {
+		hooks.Close()
+		server.Close()
+	})
+	It("Sends a signed notification for a new title", func() {
+		events.Publish(repository.EventTitleAdded, repository.TitleEvent{ID: "0100000000010000", Path: "/games/My Game.nsp", Size: 42})
+
+		var sent delivery
+		Eventually(received).Should(Receive(&sent))
+		Expect(sent.header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(sent.header.Get("X-Tinshop-Event")).To(Equal(repository.WebhookTitleAdded))
+		Expect(sent.header.Get("X-Tinshop-Signature")).To(Equal(webhooks.Sign("secret", sent.body)))
+		Expect(sent.payload["event"]).To(Equal(repository.WebhookTitleAdded))
+		Expect(sent.payload["data"]).To(HaveKeyWithValue("name", "My Game"))
+		Expect(sent.payload["data"]).To(HaveKeyWithValue("path", "/games/My Game.nsp"))
+	})
+	It("Notifies every title of a large scan", func() {
+		for i := 0; i < 200; i++ {
+			events.Publish(repository.EventTitleAdded, repository.TitleEvent{ID: fmt.Sprintf("%013X000", 0x0100000000010+i), Path: fmt.Sprintf("/games/%d.nsp", i)})
+		}
+
+		for i := 0; i < 200; i++ {
+			Eventually(received).Should(Receive())
+		}
+	})
+	Context("With files already in the library", func() {
+		BeforeEach(func() {
+			files = []repository.FileDesc{{GameID: "0100000000010000", GameInfo: "[0100000000010000][v0].nsp", Path: "/games/My Game.nsp"}}
+		})
+		It("Does not notify them again", func() {
+			events.Publish(repository.EventTitleAdded, repository.TitleEvent{ID: "0100000000010000", Path: "/games/My Game.nsp"})
+
+			Consistently(received, 200*time.Millisecond).ShouldNot(Receive())
+		})
+		It("Notifies a new update of an owned game", func() {
+			myMockSources.EXPECT().HasGame("0100000000010000").Return(true)
+			files = append(files, repository.FileDesc{GameID: "0100000000010800", GameInfo: "[0100000000010800][v65536].nsp", Path: "/games/My Game Update.nsp"})
+
+			events.Publish(repository.EventTitleAdded, repository.TitleEvent{ID: "0100000000010800", Path: "/games/My Game Update.nsp"})
+
+			var sent delivery
+			Eventually(received).Should(Receive(&sent))
+			Expect(sent.payload["event"]).To(Equal(repository.WebhookUpdateAdded))
+			Expect(sent.payload["data"]).To(HaveKeyWithValue("version", BeNumerically("==", 65536)))
+		})
+	})
+	It("Notifies a burst of failed logins once", func() {
+		now := time.Now()
+		for i := 0; i < 6; i++ {
+			events.Publish(repository.EventSecurityBlocked, repository.AuditEvent{Time: now, Type: repository.AuditFailedLogin, IP: "10.0.0.1"})
+		}
+
+		var sent delivery
+		Eventually(received).Should(Receive(&sent))
+		Expect(sent.payload["event"]).To(Equal(repository.WebhookFailedLoginBurst))
+		Expect(sent.payload["data"]).To(HaveKeyWithValue("attempts", BeNumerically("==", 5)))
+		Consistently(received, 200*time.Millisecond).ShouldNot(Receive())
+	})
+	It("Notifies a blocked device once", func() {
+		now := time.Now()
+		events.Publish(repository.EventSecurityBlocked, repository.AuditEvent{Time: now, Type: repository.AuditBlockedDevice, UID: "abc"})
+		events.Publish(repository.EventSecurityBlocked, repository.AuditEvent{Time: now.Add(time.Minute), Type: repository.AuditBlockedDevice, UID: "abc"})
+
+		var sent delivery
+		Eventually(received).Should(Receive(&sent))
+		Expect(sent.payload["event"]).To(Equal(repository.WebhookDeviceBlocked))
+		Consistently(received, 200*time.Millisecond).ShouldNot(Receive())
+	})
+	It("Notifies a blocked device again once the interval is over", func() {
+		now := time.Now()
+		events.Publish(repository.EventSecurityBlocked, repository.AuditEvent{Time: now, Type: repository.AuditBlockedDevice, UID: "abc"})
+		events.Publish(repository.EventSecurityBlocked, repository.AuditEvent{Time: now.Add(2 * time.Hour), Type: repository.AuditBlockedDevice, UID: "abc"})
+
+		Eventually(received).Should(Receive())
+		Eventually(received).Should(Receive())
+	})
+	Context("With an event filter", func() {
+		BeforeEach(func() {
+			configured = []repository.Webhook{{URL: server.URL, Events: []string{repository.WebhookScanError}}}
+		})
+		It("Only sends the events followed", func() {
+			events.Publish(repository.EventTitleAdded, repository.TitleEvent{ID: "0100000000010000", Path: "/games/My Game.nsp"})
+			events.Publish(repository.EventScanFinished, repository.ScanStatus{ID: 1, Failed: 2})
+
+			var sent delivery
+			Eventually(received).Should(Receive(&sent))
+			Expect(sent.payload["event"]).To(Equal(repository.WebhookScanError))
+			Expect(sent.header.Get("X-Tinshop-Signature")).To(BeEmpty())
+			Consistently(received, 200*time.Millisecond).ShouldNot(Receive())
+		})
+	})
+	It("Retries when the webhook is unavailable", func() {
+		atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+		events.Publish(repository.EventScanFinished, repository.ScanStatus{ID: 1, Failed: 1})
+
+		var first, second delivery
+		Eventually(received).Should(Receive(&first))
+		atomic.StoreInt32(&status, http.StatusOK)
+		Eventually(received, 3*time.Second).Should(Receive(&second))
+		Expect(second.header.Get("X-Tinshop-Delivery")).To(Equal(first.header.Get("X-Tinshop-Delivery")))
+	})
+	It("Ignores invalid urls", func() {
+		configured = []repository.Webhook{{URL: "ftp://example.com"}}
+		hooks.OnConfigUpdate(myMockConfig)
+
+		events.Publish(repository.EventScanFinished, repository.ScanStatus{ID: 1, Failed: 1})
+
+		Consistently(received, 200*time.Millisecond).ShouldNot(Receive())
+	})
+})